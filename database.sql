@@ -73,3 +73,209 @@ CREATE TABLE IF NOT EXISTS `cart_products` (
 
 ALTER TABLE `orders` ADD COLUMN `status` ENUM('created', 'payment', 'complete') NOT NULL DEFAULT 'created';
 ALTER TABLE `orders` ADD COLUMN `paypal_id` CHAR(128) DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS `suppliers` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `name` VARCHAR(200) NOT NULL,
+    `email` VARCHAR(255) DEFAULT NULL,
+    `phone` VARCHAR(32) DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `supplier_products` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `supplier_id` BIGINT NOT NULL,
+    `product_id` BIGINT NOT NULL,
+    `cost_price` DECIMAL(10, 2) NOT NULL,
+    UNIQUE (`supplier_id`, `product_id`),
+    FOREIGN KEY (`supplier_id`) REFERENCES `suppliers` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `purchase_orders` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `supplier_id` BIGINT NOT NULL,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    `status` ENUM('draft', 'sent', 'partially_received', 'received') NOT NULL DEFAULT 'draft',
+    FOREIGN KEY (`supplier_id`) REFERENCES `suppliers` (`id`) ON DELETE RESTRICT
+);
+
+CREATE TABLE IF NOT EXISTS `purchase_order_items` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `purchase_order_id` BIGINT NOT NULL,
+    `product_id` BIGINT NOT NULL,
+    `quantity` INT NOT NULL,
+    `quantity_received` INT NOT NULL DEFAULT 0,
+    `cost_per_item` DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (`purchase_order_id`) REFERENCES `purchase_orders` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `product_variants` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `product_id` BIGINT NOT NULL,
+    `sku` VARCHAR(64) NOT NULL UNIQUE,
+    `price` DECIMAL(10, 2) DEFAULT NULL,
+    `quantity` INT NOT NULL,
+    `image_url` TEXT DEFAULT NULL,
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `product_variant_values` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `variant_id` BIGINT NOT NULL,
+    `characteristic_id` BIGINT NOT NULL,
+    `value` VARCHAR(255) NOT NULL,
+    UNIQUE (`variant_id`, `characteristic_id`),
+    FOREIGN KEY (`variant_id`) REFERENCES `product_variants` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`characteristic_id`) REFERENCES `characteristics` (`id`) ON DELETE CASCADE
+);
+
+ALTER TABLE `cart_products` ADD COLUMN `variant_id` BIGINT DEFAULT NULL,
+    ADD FOREIGN KEY (`variant_id`) REFERENCES `product_variants` (`id`) ON DELETE CASCADE;
+ALTER TABLE `order_items` ADD COLUMN `variant_id` BIGINT DEFAULT NULL,
+    ADD FOREIGN KEY (`variant_id`) REFERENCES `product_variants` (`id`) ON DELETE SET NULL;
+
+ALTER TABLE `products` ADD COLUMN `sku` VARCHAR(64) DEFAULT NULL UNIQUE;
+ALTER TABLE `products` ADD COLUMN `barcode` CHAR(13) DEFAULT NULL UNIQUE;
+
+CREATE TABLE IF NOT EXISTS `product_images` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `product_id` BIGINT NOT NULL,
+    `file_name` VARCHAR(128) NOT NULL UNIQUE,
+    `position` INT NOT NULL DEFAULT 0,
+    `is_primary` BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE
+);
+
+ALTER TABLE `characteristics`
+    ADD COLUMN `value_type` ENUM('text', 'number', 'boolean', 'enum') NOT NULL DEFAULT 'text',
+    ADD COLUMN `allowed_values` TEXT DEFAULT NULL;
+ALTER TABLE `product_characteristics`
+    ADD COLUMN `value_number` DOUBLE DEFAULT NULL,
+    ADD INDEX `product_characteristics_number` (`characteristic_id`, `value_number`);
+
+CREATE TABLE IF NOT EXISTS `search_queries` (
+    `query` VARCHAR(255) PRIMARY KEY,
+    `count` INT NOT NULL DEFAULT 0,
+    `last_searched_at` DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+ALTER TABLE `categories` ADD COLUMN `parent_id` BIGINT DEFAULT NULL,
+    ADD FOREIGN KEY (`parent_id`) REFERENCES `categories` (`id`) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS `category_characteristics` (
+    `category_id` BIGINT NOT NULL,
+    `characteristic_id` BIGINT NOT NULL,
+    `required` BOOL NOT NULL DEFAULT FALSE,
+    `display_order` INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (`category_id`, `characteristic_id`),
+    FOREIGN KEY (`category_id`) REFERENCES `categories` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`characteristic_id`) REFERENCES `characteristics` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `product_reviews` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `product_id` BIGINT NOT NULL,
+    `customer_id` BIGINT NOT NULL,
+    `order_id` BIGINT NOT NULL,
+    `rating` TINYINT NOT NULL,
+    `title` VARCHAR(200) NOT NULL,
+    `text` TEXT,
+    `status` ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending',
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (`product_id`, `customer_id`),
+    INDEX `product_reviews_status` (`status`, `created_at`),
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`order_id`) REFERENCES `orders` (`id`) ON DELETE CASCADE
+);
+
+ALTER TABLE `products`
+    ADD COLUMN `rating` DOUBLE NOT NULL DEFAULT 0,
+    ADD COLUMN `reviews_count` INT NOT NULL DEFAULT 0,
+    ADD INDEX `products_rating` (`rating`);
+
+CREATE TABLE IF NOT EXISTS `product_recommendations` (
+    `product_id` BIGINT NOT NULL,
+    `related_product_id` BIGINT NOT NULL,
+    `together_count` INT NOT NULL,
+    `score` DOUBLE NOT NULL,
+    PRIMARY KEY (`product_id`, `related_product_id`),
+    INDEX `product_recommendations_score` (`product_id`, `score`),
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`related_product_id`) REFERENCES `products` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `report_schedules` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `name` VARCHAR(200) NOT NULL,
+    `frequency` ENUM('daily', 'weekly', 'monthly') NOT NULL DEFAULT 'daily',
+    `recipients` TEXT NOT NULL,
+    `hour` TINYINT NOT NULL DEFAULT 8,
+    `enabled` BOOL NOT NULL DEFAULT TRUE,
+    `last_sent_at` DATETIME DEFAULT NULL,
+    `next_run_at` DATETIME NOT NULL,
+    INDEX `report_schedules_next_run` (`enabled`, `next_run_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `report_deliveries` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `schedule_id` BIGINT DEFAULT NULL,
+    `schedule_name` VARCHAR(200) NOT NULL,
+    `recipients` TEXT NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `status` ENUM('sent', 'failed') NOT NULL,
+    `error` TEXT,
+    `sent_at` DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    INDEX `report_deliveries_sent_at` (`sent_at`),
+    FOREIGN KEY (`schedule_id`) REFERENCES `report_schedules` (`id`) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS `daily_sales` (
+    `day` DATE NOT NULL,
+    `status` VARCHAR(16) NOT NULL,
+    `category_id` BIGINT NOT NULL,
+    `orders_count` INT NOT NULL,
+    `customers_count` INT NOT NULL,
+    `items_sold` INT NOT NULL,
+    `revenue` DOUBLE NOT NULL,
+    `median_total` DOUBLE NOT NULL,
+    PRIMARY KEY (`day`, `status`, `category_id`),
+    INDEX `daily_sales_filter` (`status`, `category_id`, `day`)
+);
+
+CREATE TABLE IF NOT EXISTS `daily_product_sales` (
+    `day` DATE NOT NULL,
+    `status` VARCHAR(16) NOT NULL,
+    `product_id` BIGINT NOT NULL,
+    `orders_count` INT NOT NULL,
+    `quantity` INT NOT NULL,
+    `revenue` DOUBLE NOT NULL,
+    PRIMARY KEY (`day`, `status`, `product_id`),
+    INDEX `daily_product_sales_filter` (`status`, `day`, `product_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `daily_product_pairs` (
+    `day` DATE NOT NULL,
+    `status` VARCHAR(16) NOT NULL,
+    `product_id` BIGINT NOT NULL,
+    `related_product_id` BIGINT NOT NULL,
+    `together_count` INT NOT NULL,
+    PRIMARY KEY (`day`, `status`, `product_id`, `related_product_id`),
+    INDEX `daily_product_pairs_filter` (`status`, `day`)
+);
+
+CREATE TABLE IF NOT EXISTS `funnel_events` (
+    `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+    `session_id` CHAR(36) NOT NULL,
+    `event` ENUM('catalog_view', 'product_view', 'add_to_cart', 'checkout_started', 'payment_started', 'payment_completed') NOT NULL,
+    `product_id` BIGINT DEFAULT NULL,
+    `order_id` BIGINT DEFAULT NULL,
+    `quantity` INT NOT NULL DEFAULT 0,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    INDEX `funnel_events_event` (`event`, `created_at`),
+    INDEX `funnel_events_session` (`session_id`, `event`, `created_at`),
+    INDEX `funnel_events_order` (`order_id`),
+    FOREIGN KEY (`product_id`) REFERENCES `products` (`id`) ON DELETE SET NULL,
+    FOREIGN KEY (`order_id`) REFERENCES `orders` (`id`) ON DELETE SET NULL
+);
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 )
 
@@ -22,39 +23,101 @@ func getMostLeastOrderedProduct(row *sql.Row) (Product, int64, error) {
 	return product, count, nil
 }
 
-func GetMostOrderedProduct() (Product, int64, error) {
+type AnalysisFilter struct {
+	From       time.Time
+	To         time.Time
+	CategoryId int64
+	Status     string
+}
+
+func (filter AnalysisFilter) Days() int {
+	return int(filter.To.Sub(filter.From).Hours()/24) + 1
+}
+
+func (filter AnalysisFilter) Previous() AnalysisFilter {
+	previous := filter
+	previous.To = filter.From.AddDate(0, 0, -1)
+	previous.From = filter.From.AddDate(0, 0, -filter.Days())
+	return previous
+}
+
+func (filter AnalysisFilter) where(orderAlias string, itemAliases ...string) (string, []any) {
+	conditions := []string{orderAlias + ".created_at >= ?", orderAlias + ".created_at < ?"}
+	args := []any{filter.From, filter.To.AddDate(0, 0, 1)}
+
+	if filter.Status != "" {
+		conditions = append(conditions, orderAlias+".status = ?")
+		args = append(args, filter.Status)
+	}
+
+	if filter.CategoryId != 0 {
+		categoryProducts := "SELECT cp.id FROM products cp WHERE cp.category_id IN (" + categoryDescendantsQuery + ")"
+		if len(itemAliases) == 0 {
+			conditions = append(conditions, "EXISTS (SELECT 1 FROM order_items ci WHERE ci.order_id = "+orderAlias+".id AND ci.product_id IN ("+categoryProducts+"))")
+			args = append(args, filter.CategoryId)
+		}
+		for _, itemAlias := range itemAliases {
+			conditions = append(conditions, itemAlias+".product_id IN ("+categoryProducts+")")
+			args = append(args, filter.CategoryId)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// rollupWhere filters the daily rollups. Without product columns it selects the category rows of daily_sales,
+// otherwise it keeps only products of the category in the given columns.
+func (filter AnalysisFilter) rollupWhere(alias string, productColumns ...string) (string, []any) {
+	conditions := []string{alias + ".day >= ?", alias + ".day <= ?", alias + ".status = ?"}
+	args := []any{filter.From, filter.To, filter.Status}
+
+	if len(productColumns) == 0 {
+		conditions = append(conditions, alias+".category_id = ?")
+		args = append(args, filter.CategoryId)
+	} else if filter.CategoryId != 0 {
+		for _, column := range productColumns {
+			conditions = append(conditions, column+" IN (SELECT cp.id FROM products cp WHERE cp.category_id IN ("+categoryDescendantsQuery+"))")
+			args = append(args, filter.CategoryId)
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func GetMostOrderedProduct(filter AnalysisFilter) (Product, int64, error) {
+	where, args := filter.rollupWhere("s", "s.product_id")
 	return getMostLeastOrderedProduct(database.QueryRow(
-		`SELECT p.id, SUM(oi.quantity) AS total_bought
-		FROM order_items oi
-			JOIN products p ON p.id = oi.product_id
-			JOIN orders o ON oi.order_id = o.id
-		WHERE o.created_at >= NOW() - INTERVAL 30 DAY
-		GROUP BY p.id
+		`SELECT s.product_id, SUM(s.quantity) AS total_bought
+		FROM daily_product_sales s
+		WHERE `+where+`
+		GROUP BY s.product_id
 		ORDER BY total_bought DESC
 		LIMIT 1;`,
+		args...,
 	))
 }
 
-func GetLeastOrderedProduct() (Product, int64, error) {
+func GetLeastOrderedProduct(filter AnalysisFilter) (Product, int64, error) {
+	where, args := filter.rollupWhere("s", "s.product_id")
 	return getMostLeastOrderedProduct(database.QueryRow(
-		`SELECT p.id, sum(i.quantity) AS total_bought
-		FROM products p
-			INNER JOIN order_items i ON p.id = i.product_id
-		GROUP BY p.id
+		`SELECT s.product_id, SUM(s.quantity) AS total_bought
+		FROM daily_product_sales s
+		WHERE `+where+`
+		GROUP BY s.product_id
 		HAVING total_bought > 0
 		ORDER BY total_bought
 		LIMIT 1;`,
+		args...,
 	))
 }
 
-func GetOrdersAverageTotal() (float64, error) {
+func GetOrdersAverageTotal(filter AnalysisFilter) (float64, error) {
+	where, args := filter.rollupWhere("s")
 	row := database.QueryRow(
-		`SELECT AVG(totals.total) FROM (
-			SELECT SUM(i.quantity * i.price_per_item) AS total
-			FROM orders o
-				INNER JOIN order_items i ON o.id = i.order_id
-			GROUP BY o.id
-		) AS totals;`,
+		`SELECT COALESCE(SUM(s.revenue) / SUM(s.orders_count), 0)
+		FROM daily_sales s
+		WHERE `+where+`;`,
+		args...,
 	)
 
 	var averageTotal float64
@@ -67,13 +130,49 @@ func GetOrdersAverageTotal() (float64, error) {
 	return averageTotal, nil
 }
 
-func GetCustomersCountPerDay() ([]FloatStatPerDay, error) {
-	rows, err := database.Query(
-		`SELECT DATE(created_at) as date, COUNT(DISTINCT customer_id) as num_customers
-		FROM orders
-		WHERE created_at >= CURDATE() - INTERVAL 30 DAY
-		GROUP BY date;`,
-	)
+type AnalysisSummary struct {
+	Orders       int
+	Customers    int
+	ItemsSold    int
+	Revenue      float64
+	AverageTotal float64
+}
+
+func GetAnalysisSummary(filter AnalysisFilter) (AnalysisSummary, error) {
+	var summary AnalysisSummary
+
+	where, args := filter.rollupWhere("s")
+	err := database.QueryRow(
+		`SELECT COALESCE(SUM(s.orders_count), 0), COALESCE(SUM(s.items_sold), 0), COALESCE(SUM(s.revenue), 0)
+		FROM daily_sales s
+		WHERE `+where+`;`,
+		args...,
+	).Scan(&summary.Orders, &summary.ItemsSold, &summary.Revenue)
+	if err != nil {
+		return summary, err
+	}
+
+	// Customers are counted from the orders themselves, daily counts can't be summed without counting returning customers twice.
+	where, args = filter.where("o")
+	err = database.QueryRow(
+		`SELECT COUNT(DISTINCT o.customer_id)
+		FROM orders o
+		WHERE `+where+`;`,
+		args...,
+	).Scan(&summary.Customers)
+	if err != nil {
+		return summary, err
+	}
+
+	if summary.Orders > 0 {
+		summary.AverageTotal = summary.Revenue / float64(summary.Orders)
+	}
+
+	return summary, nil
+}
+
+func getStatsPerDay(query string, args ...any) ([]FloatStatPerDay, error) {
+	rows, err := database.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,65 +190,48 @@ func GetCustomersCountPerDay() ([]FloatStatPerDay, error) {
 		result = append(result, row)
 	}
 
-	return result, nil
+	return result, rows.Err()
 }
 
-func GetDayWithMinOrderCount() (time.Time, int, error) {
-	row := database.QueryRow(
-		`SELECT day, order_count
-		FROM (
-			SELECT DATE(created_at) AS day, COUNT(*) AS order_count
-			FROM orders
-			WHERE created_at >= NOW() - INTERVAL 30 DAY
-			GROUP BY day
-		) t
-		WHERE order_count = (SELECT MIN(order_count) FROM (
-			SELECT COUNT(*) AS order_count
-			FROM orders
-			WHERE created_at >= NOW() - INTERVAL 30 DAY
-			GROUP BY DATE(created_at)
-		) x)
-		LIMIT 1;`,
+func GetCustomersCountPerDay(filter AnalysisFilter) ([]FloatStatPerDay, error) {
+	where, args := filter.rollupWhere("s")
+	return getStatsPerDay(
+		`SELECT s.day, s.customers_count
+		FROM daily_sales s
+		WHERE `+where+`
+		ORDER BY s.day;`,
+		args...,
 	)
-
-	var minDay time.Time
-	var count int
-
-	err := row.Scan(&minDay, &count)
-	if err != nil {
-		return minDay, 0, err
-	}
-
-	return minDay, count, nil
 }
 
-func GetDayWithMaxOrderCount() (time.Time, int, error) {
+func getDayWithMinMaxOrderCount(filter AnalysisFilter, order string) (time.Time, int, error) {
+	where, args := filter.rollupWhere("s")
 	row := database.QueryRow(
-		`SELECT day, order_count
-		FROM (
-			SELECT DATE(created_at) AS day, COUNT(*) AS order_count
-			FROM orders
-			WHERE created_at >= NOW() - INTERVAL 30 DAY
-			GROUP BY day
-		) t
-		WHERE order_count = (SELECT MAX(order_count) FROM (
-			SELECT COUNT(*) AS order_count
-			FROM orders
-			WHERE created_at >= NOW() - INTERVAL 30 DAY
-			GROUP BY DATE(created_at)
-		) x)
+		`SELECT s.day, s.orders_count
+		FROM daily_sales s
+		WHERE `+where+`
+		ORDER BY s.orders_count `+order+`, s.day
 		LIMIT 1;`,
+		args...,
 	)
 
-	var maxDay time.Time
+	var day time.Time
 	var count int
 
-	err := row.Scan(&maxDay, &count)
+	err := row.Scan(&day, &count)
 	if err != nil {
-		return maxDay, 0, err
+		return day, 0, err
 	}
 
-	return maxDay, count, nil
+	return day, count, nil
+}
+
+func GetDayWithMinOrderCount(filter AnalysisFilter) (time.Time, int, error) {
+	return getDayWithMinMaxOrderCount(filter, "ASC")
+}
+
+func GetDayWithMaxOrderCount(filter AnalysisFilter) (time.Time, int, error) {
+	return getDayWithMinMaxOrderCount(filter, "DESC")
 }
 
 type FloatStatPerDay struct {
@@ -157,84 +239,82 @@ type FloatStatPerDay struct {
 	Value float64
 }
 
-func GetAverageOrderTotalPerDay() ([]FloatStatPerDay, error) {
-	rows, err := database.Query(
-		`SELECT DATE(o.created_at) AS day, AVG(order_total) AS avg_order_total
-		FROM (
-			SELECT oi.order_id, SUM(oi.price_per_item * oi.quantity) AS order_total
-			FROM order_items oi
-			GROUP BY oi.order_id
-		) totals
-			JOIN orders o ON o.id = totals.order_id
-		WHERE o.created_at >= NOW() - INTERVAL 30 DAY
-		GROUP BY day
-		ORDER BY day;`,
+func GetAverageOrderTotalPerDay(filter AnalysisFilter) ([]FloatStatPerDay, error) {
+	where, args := filter.rollupWhere("s")
+	return getStatsPerDay(
+		`SELECT s.day, s.revenue / s.orders_count
+		FROM daily_sales s
+		WHERE `+where+`
+		ORDER BY s.day;`,
+		args...,
 	)
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
+}
 
-	var result []FloatStatPerDay
+func GetMedianOrderTotalPerDay(filter AnalysisFilter) ([]FloatStatPerDay, error) {
+	where, args := filter.rollupWhere("s")
+	return getStatsPerDay(
+		`SELECT s.day, s.median_total
+		FROM daily_sales s
+		WHERE `+where+`
+		ORDER BY s.day;`,
+		args...,
+	)
+}
 
-	for rows.Next() {
-		var row FloatStatPerDay
-		err = rows.Scan(&row.Day, &row.Value)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, row)
-	}
+func GetMostOrderedProductWithThis(product Product, filter AnalysisFilter) (Product, int64, error) {
+	where, args := filter.rollupWhere("s", "s.other_product_id")
+	return getMostLeastOrderedProduct(database.QueryRow(
+		`SELECT s.other_product_id, SUM(s.together_count) AS together_count
+		FROM (
+			SELECT p.day, p.status, IF(p.product_id = ?, p.related_product_id, p.product_id) AS other_product_id, p.together_count
+			FROM daily_product_pairs p
+			WHERE p.product_id = ? OR p.related_product_id = ?
+		) s
+		WHERE `+where+`
+		GROUP BY s.other_product_id
+		ORDER BY together_count DESC
+		LIMIT 1;`,
+		append([]any{product.Id, product.Id, product.Id}, args...)...,
+	))
+}
 
-	return result, nil
+func GetRevenuePerDay(filter AnalysisFilter) ([]FloatStatPerDay, error) {
+	where, args := filter.rollupWhere("s")
+	return getStatsPerDay(
+		`SELECT s.day, s.revenue
+		FROM daily_sales s
+		WHERE `+where+`
+		ORDER BY s.day;`,
+		args...,
+	)
 }
 
-func GetMedianOrderTotalPerDay() ([]FloatStatPerDay, error) {
+// GetProductUnitsPerDay returns units sold per day of every product sold within the filter, days without sales are omitted.
+func GetProductUnitsPerDay(filter AnalysisFilter) (map[int64][]FloatStatPerDay, error) {
+	where, args := filter.rollupWhere("s", "s.product_id")
 	rows, err := database.Query(
-		`SELECT day, MEDIAN(order_total) OVER (PARTITION BY day) AS median_order_total
-		FROM (
-			SELECT DATE(o.created_at) AS day, SUM(oi.price_per_item * oi.quantity) AS order_total
-			FROM order_items oi
-				JOIN orders o ON o.id = oi.order_id
-			WHERE o.created_at >= NOW() - INTERVAL 30 DAY
-			GROUP BY oi.order_id, day
-		) t
-		GROUP BY day
-		ORDER BY day;`,
+		`SELECT s.product_id, s.day, s.quantity
+		FROM daily_product_sales s
+		WHERE `+where+`
+		ORDER BY s.product_id, s.day;`,
+		args...,
 	)
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	var result []FloatStatPerDay
-
+	result := make(map[int64][]FloatStatPerDay)
 	for rows.Next() {
+		var productId int64
 		var row FloatStatPerDay
-		err = rows.Scan(&row.Day, &row.Value)
-		if err != nil {
+		if err = rows.Scan(&productId, &row.Day, &row.Value); err != nil {
 			return nil, err
 		}
-		result = append(result, row)
+		result[productId] = append(result[productId], row)
 	}
 
-	return result, nil
-}
-
-func GetMostOrderedProductWithThis(product Product) (Product, int64, error) {
-	return getMostLeastOrderedProduct(database.QueryRow(
-		`SELECT oi2.product_id, COUNT(oi2.product_id) AS together_count
-		FROM order_items oi1
-			JOIN order_items oi2 ON oi1.order_id = oi2.order_id AND oi1.product_id <> oi2.product_id
-			JOIN orders o ON o.id = oi2.order_id
-		WHERE oi1.product_id = ? AND o.created_at >= NOW() - INTERVAL 30 DAY
-		GROUP BY oi2.product_id
-		ORDER BY together_count DESC
-		LIMIT 1;`,
-		product.Id,
-	))
+	return result, rows.Err()
 }
 
 type OrderedProductPair struct {
@@ -246,6 +326,7 @@ func getMostLeastOrderedProductPairs(rows *sql.Rows) ([]OrderedProductPair, erro
 	var err error
 
 	var result []OrderedProductPair
+	var productIds []int64
 
 	for rows.Next() {
 		var row OrderedProductPair
@@ -254,34 +335,48 @@ func getMostLeastOrderedProductPairs(rows *sql.Rows) ([]OrderedProductPair, erro
 			return nil, err
 		}
 
-		row.Products[0], err = GetProduct(row.Products[0].Id)
-		if err != nil {
-			return nil, err
-		}
+		productIds = append(productIds, row.Products[0].Id, row.Products[1].Id)
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
-		row.Products[1], err = GetProduct(row.Products[1].Id)
-		if err != nil {
-			return nil, err
-		}
+	products, err := GetProductsByIds(productIds)
+	if err != nil {
+		return nil, err
+	}
 
-		result = append(result, row)
+	productsById := make(map[int64]Product, len(products))
+	for _, product := range products {
+		productsById[product.Id] = product
 	}
 
-	return result, nil
+	// Pairs of products deleted since the last rebuild are skipped
+	pairs := result[:0]
+	for _, pair := range result {
+		first, ok1 := productsById[pair.Products[0].Id]
+		second, ok2 := productsById[pair.Products[1].Id]
+		if ok1 && ok2 {
+			pair.Products = [2]Product{first, second}
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs, nil
 }
 
-func GetMostOrderedProductPairs(limit int) ([]OrderedProductPair, error) {
+func GetMostOrderedProductPairs(limit int, filter AnalysisFilter) ([]OrderedProductPair, error) {
+	where, args := filter.rollupWhere("s", "s.product_id", "s.related_product_id")
 	rows, err := database.Query(
-		`SELECT oi1.product_id, oi2.product_id, COUNT(oi2.product_id) AS together_count
-		FROM order_items oi1
-			JOIN order_items oi2 ON oi1.order_id = oi2.order_id AND oi1.product_id < oi2.product_id
-			JOIN orders o ON o.id = oi2.order_id
-		WHERE o.created_at >= NOW() - INTERVAL 30 DAY
-		GROUP BY oi1.product_id, oi2.product_id
+		`SELECT s.product_id, s.related_product_id, SUM(s.together_count) AS together_count
+		FROM daily_product_pairs s
+		WHERE `+where+`
+		GROUP BY s.product_id, s.related_product_id
 		HAVING together_count > 0
 		ORDER BY together_count DESC
 		LIMIT ?;`,
-		limit,
+		append(args, limit)...,
 	)
 	if err != nil {
 		return nil, err
@@ -291,18 +386,17 @@ func GetMostOrderedProductPairs(limit int) ([]OrderedProductPair, error) {
 	return getMostLeastOrderedProductPairs(rows)
 }
 
-func GetLeastOrderedProductPairs(limit int) ([]OrderedProductPair, error) {
+func GetLeastOrderedProductPairs(limit int, filter AnalysisFilter) ([]OrderedProductPair, error) {
+	where, args := filter.rollupWhere("s", "s.product_id", "s.related_product_id")
 	rows, err := database.Query(
-		`SELECT oi1.product_id, oi2.product_id, COUNT(oi2.product_id) AS together_count
-		FROM order_items oi1
-			JOIN order_items oi2 ON oi1.order_id = oi2.order_id AND oi1.product_id < oi2.product_id
-			JOIN orders o ON o.id = oi2.order_id
-		WHERE o.created_at >= NOW() - INTERVAL 30 DAY
-		GROUP BY oi1.product_id, oi2.product_id
+		`SELECT s.product_id, s.related_product_id, SUM(s.together_count) AS together_count
+		FROM daily_product_pairs s
+		WHERE `+where+`
+		GROUP BY s.product_id, s.related_product_id
 		HAVING together_count > 0
 		ORDER BY together_count
 		LIMIT ?;`,
-		limit,
+		append(args, limit)...,
 	)
 	if err != nil {
 		return nil, err
@@ -311,3 +405,36 @@ func GetLeastOrderedProductPairs(limit int) ([]OrderedProductPair, error) {
 
 	return getMostLeastOrderedProductPairs(rows)
 }
+
+func GetOrderBaskets(filter AnalysisFilter) ([][]int64, error) {
+	where, args := filter.where("o", "oi")
+	rows, err := database.Query(
+		`SELECT DISTINCT oi.order_id, oi.product_id
+		FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+		WHERE `+where+`
+		ORDER BY oi.order_id, oi.product_id;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baskets [][]int64
+	lastOrderId := int64(-1)
+	for rows.Next() {
+		var orderId, productId int64
+		if err = rows.Scan(&orderId, &productId); err != nil {
+			return nil, err
+		}
+
+		if orderId != lastOrderId {
+			baskets = append(baskets, nil)
+			lastOrderId = orderId
+		}
+		baskets[len(baskets)-1] = append(baskets[len(baskets)-1], productId)
+	}
+
+	return baskets, rows.Err()
+}
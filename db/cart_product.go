@@ -11,9 +11,21 @@ type CartProduct struct {
 	Id       int64
 	CartId   uuid.UUID
 	Product  Product
+	Variant  ProductVariant
 	Quantity int
 }
 
+func (item CartProduct) Price() float64 {
+	return item.Variant.EffectivePrice(item.Product)
+}
+
+func (item CartProduct) AvailableQuantity() int {
+	if item.Variant.Id > 0 {
+		return item.Variant.Quantity
+	}
+	return item.Product.Quantity
+}
+
 func GetCartProducts(cartId uuid.UUID) ([]CartProduct, int, error) {
 	return getRowsAndCount(
 		1,
@@ -22,9 +34,11 @@ func GetCartProducts(cartId uuid.UUID) ([]CartProduct, int, error) {
 			return database.Query(
 				`SELECT 
     				i.id, i.cart_id, i.quantity,
-    				p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, ''),
+    				COALESCE(v.id, 0), COALESCE(v.sku, ''), COALESCE(v.price, 0), COALESCE(v.quantity, 0), COALESCE(v.image_url, ''), `+variantNameQuery+`
 				FROM cart_products i 
 				LEFT OUTER JOIN products p ON i.product_id = p.id
+		LEFT OUTER JOIN product_variants v ON i.variant_id = v.id
 				WHERE i.cart_id = ?
 				ORDER BY i.id;`,
 				cartId,
@@ -35,6 +49,7 @@ func GetCartProducts(cartId uuid.UUID) ([]CartProduct, int, error) {
 			err := rows.Scan(
 				&item.Id, &item.CartId, &item.Quantity,
 				&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+				&item.Variant.Id, &item.Variant.Sku, &item.Variant.Price, &item.Variant.Quantity, &item.Variant.ImageUrl, &item.Variant.Name,
 			)
 			return item, err
 		},
@@ -69,45 +84,58 @@ func GetCartProduct(itemId int64, cartId uuid.UUID) (CartProduct, error) {
 	row := database.QueryRow(
 		`SELECT 
     		i.id, i.cart_id, i.quantity,
-    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, ''),
+    		COALESCE(v.id, 0), COALESCE(v.sku, ''), COALESCE(v.price, 0), COALESCE(v.quantity, 0), COALESCE(v.image_url, ''), `+variantNameQuery+`
 		FROM cart_products i
 		LEFT OUTER JOIN products p ON i.product_id = p.id
+		LEFT OUTER JOIN product_variants v ON i.variant_id = v.id
 		WHERE i.id = ? AND i.cart_id = ?;`,
 		itemId, cartId,
 	)
 	err := row.Scan(
 		&item.Id, &item.CartId, &item.Quantity,
 		&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+		&item.Variant.Id, &item.Variant.Sku, &item.Variant.Price, &item.Variant.Quantity, &item.Variant.ImageUrl, &item.Variant.Name,
 	)
 
 	return item, err
 }
 
-func GetCartProductByProductId(productId int64, cartId uuid.UUID) (CartProduct, error) {
+func GetCartProductByProductId(productId, variantId int64, cartId uuid.UUID) (CartProduct, error) {
 	var item CartProduct
 
 	row := database.QueryRow(
 		`SELECT 
     		i.id, i.cart_id, i.quantity,
-    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, ''),
+    		COALESCE(v.id, 0), COALESCE(v.sku, ''), COALESCE(v.price, 0), COALESCE(v.quantity, 0), COALESCE(v.image_url, ''), `+variantNameQuery+`
 		FROM cart_products i
 		LEFT OUTER JOIN products p ON i.product_id = p.id
-		WHERE i.product_id = ? AND i.cart_id = ?;`,
-		productId, cartId,
+		LEFT OUTER JOIN product_variants v ON i.variant_id = v.id
+		WHERE i.product_id = ? AND COALESCE(i.variant_id, 0) = ? AND i.cart_id = ?;`,
+		productId, variantId, cartId,
 	)
 	err := row.Scan(
 		&item.Id, &item.CartId, &item.Quantity,
 		&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+		&item.Variant.Id, &item.Variant.Sku, &item.Variant.Price, &item.Variant.Quantity, &item.Variant.ImageUrl, &item.Variant.Name,
 	)
 
 	return item, err
 }
 
 func CreateCartProduct(item CartProduct) error {
+	var variantId sql.NullInt64
+	if item.Variant.Id == 0 {
+		variantId = sql.NullInt64{}
+	} else {
+		variantId = sql.NullInt64{Int64: item.Variant.Id, Valid: true}
+	}
+
 	_, err := database.Exec(
-		`INSERT INTO cart_products (cart_id, product_id, quantity) 
-		VALUES (?, ?, ?);`,
-		item.CartId, item.Product.Id, item.Quantity,
+		`INSERT INTO cart_products (cart_id, product_id, variant_id, quantity) 
+		VALUES (?, ?, ?, ?);`,
+		item.CartId, item.Product.Id, variantId, item.Quantity,
 	)
 	return err
 }
@@ -0,0 +1,348 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	catalogFacetManufacturer   = "manufacturer"
+	catalogFacetPrice          = "price"
+	catalogFacetStock          = "stock"
+	catalogFacetCharacteristic = "characteristic"
+	catalogFacetRating         = "rating"
+)
+
+// CatalogSearchLimit is the number of best full-text search hits shown in the catalog, their ids are passed to every catalog query.
+const CatalogSearchLimit = 500
+
+// catalogInStockCondition also checks variants, products with variants keep their stock there
+const catalogInStockCondition = "(p.quantity > 0 OR EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.quantity > 0))"
+
+type CatalogCharacteristicFilter struct {
+	CharacteristicId int64
+	Values           []string
+	Min              sql.NullFloat64
+	Max              sql.NullFloat64
+}
+
+func (charFilter CatalogCharacteristicFilter) IsActive() bool {
+	return len(charFilter.Values) > 0 || charFilter.Min.Valid || charFilter.Max.Valid
+}
+
+type CatalogFilter struct {
+	Category        Category
+	Query           string
+	FullText        bool
+	SearchIds       []int64
+	Sort            string
+	MinPrice        float64
+	MaxPrice        float64
+	InStock         bool
+	MinRating       int
+	Manufacturers   []string
+	Characteristics []CatalogCharacteristicFilter
+}
+
+type CatalogFacetValue struct {
+	Value    string
+	Count    int
+	Selected bool
+}
+
+type CatalogCharacteristicFacet struct {
+	Characteristic Characteristic
+	Values         []CatalogFacetValue
+	MinValue       float64
+	MaxValue       float64
+	Filter         CatalogCharacteristicFilter
+}
+
+type CatalogFacets struct {
+	Manufacturers   []CatalogFacetValue
+	Characteristics []CatalogCharacteristicFacet
+	Ratings         []CatalogFacetValue
+	InStockCount    int
+	MinPrice        float64
+	MaxPrice        float64
+}
+
+func sqlPlaceholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+func (filter *CatalogFilter) searchIds() []int64 {
+	return filter.SearchIds[:min(len(filter.SearchIds), CatalogSearchLimit)]
+}
+
+func (filter *CatalogFilter) where(skipFacet string, skipCharacteristicId int64) (string, []any) {
+	conditions := []string{missingRequiredCharacteristicsCondition}
+	var args []any
+
+	if filter.FullText {
+		searchIds := filter.searchIds()
+		if len(searchIds) == 0 {
+			conditions = append(conditions, "1 = 0")
+		} else {
+			conditions = append(conditions, "p.id IN ("+sqlPlaceholders(len(searchIds))+")")
+			for _, id := range searchIds {
+				args = append(args, id)
+			}
+		}
+	} else {
+		conditions = append(conditions, "(LOWER(p.model) LIKE CONCAT(?, '%') OR LOWER(p.manufacturer) LIKE CONCAT(?, '%'))")
+		args = append(args, filter.Query, filter.Query)
+	}
+
+	if filter.Category.Id != 0 {
+		conditions = append(conditions, "p.category_id IN ("+categoryDescendantsQuery+")")
+		args = append(args, filter.Category.Id)
+	}
+
+	if skipFacet != catalogFacetPrice {
+		if filter.MinPrice > 0 {
+			conditions = append(conditions, "p.price >= ?")
+			args = append(args, filter.MinPrice)
+		}
+		if filter.MaxPrice > 0 {
+			conditions = append(conditions, "p.price <= ?")
+			args = append(args, filter.MaxPrice)
+		}
+	}
+
+	if skipFacet != catalogFacetRating && filter.MinRating > 0 {
+		conditions = append(conditions, "p.reviews_count > 0 AND p.rating >= ?")
+		args = append(args, filter.MinRating)
+	}
+
+	if skipFacet != catalogFacetStock && filter.InStock {
+		conditions = append(conditions, catalogInStockCondition)
+	}
+
+	if skipFacet != catalogFacetManufacturer && len(filter.Manufacturers) > 0 {
+		conditions = append(conditions, "p.manufacturer IN ("+sqlPlaceholders(len(filter.Manufacturers))+")")
+		for _, manufacturer := range filter.Manufacturers {
+			args = append(args, manufacturer)
+		}
+	}
+
+	for _, charFilter := range filter.Characteristics {
+		if !charFilter.IsActive() {
+			continue
+		}
+		if skipFacet == catalogFacetCharacteristic && charFilter.CharacteristicId == skipCharacteristicId {
+			continue
+		}
+
+		charConditions := "fc.product_id = p.id AND fc.characteristic_id = ?"
+		args = append(args, charFilter.CharacteristicId)
+
+		if len(charFilter.Values) > 0 {
+			charConditions += " AND fc.value IN (" + sqlPlaceholders(len(charFilter.Values)) + ")"
+			for _, value := range charFilter.Values {
+				args = append(args, value)
+			}
+		}
+		if charFilter.Min.Valid {
+			charConditions += " AND fc.value_number >= ?"
+			args = append(args, charFilter.Min.Float64)
+		}
+		if charFilter.Max.Valid {
+			charConditions += " AND fc.value_number <= ?"
+			args = append(args, charFilter.Max.Float64)
+		}
+
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM product_characteristics fc WHERE "+charConditions+")")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func (filter *CatalogFilter) sortKey() SortKey {
+	if filter.Sort != ProductSortDefault || !filter.FullText || len(filter.SearchIds) == 0 {
+		return productSortKey(filter.Sort)
+	}
+
+	// Relevance order of the search hits, the id list is bounded by CatalogSearchLimit like the where condition
+	searchIds := filter.searchIds()
+	ids := make([]string, 0, len(searchIds))
+	for _, id := range searchIds {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return SortKey{Expr: "FIELD(p.id, " + strings.Join(ids, ", ") + ")", IdExpr: "p.id"}
+}
+
+func addMissingSelectedValues(values []CatalogFacetValue, selected []string) []CatalogFacetValue {
+	for _, selectedValue := range selected {
+		found := false
+		for idx := range values {
+			if values[idx].Value == selectedValue {
+				values[idx].Selected = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			values = append(values, CatalogFacetValue{Value: selectedValue, Selected: true})
+		}
+	}
+
+	return values
+}
+
+func getCatalogCharacteristicFacets(filter *CatalogFilter, characteristicId int64) ([]CatalogCharacteristicFacet, error) {
+	where, args := filter.where(catalogFacetCharacteristic, characteristicId)
+
+	var characteristicCondition string
+	if characteristicId != 0 {
+		characteristicCondition = "pc.characteristic_id = ?"
+		args = append(args, characteristicId)
+	} else {
+		characteristicCondition = "1 = 1"
+		for _, charFilter := range filter.Characteristics {
+			if charFilter.IsActive() {
+				characteristicCondition += " AND pc.characteristic_id != ?"
+				args = append(args, charFilter.CharacteristicId)
+			}
+		}
+	}
+
+	rows, err := database.Query(
+		`SELECT
+    		c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type, pc.value, MIN(pc.value_number), COUNT(DISTINCT p.id)
+		FROM products p
+		INNER JOIN product_characteristics pc ON pc.product_id = p.id
+		INNER JOIN characteristics c ON pc.characteristic_id = c.id
+		WHERE `+where+` AND `+characteristicCondition+` AND pc.value IS NOT NULL AND pc.value != ''
+		GROUP BY c.id, c.name, c.measurement_unit, c.value_type, pc.value
+		ORDER BY c.name, c.id, pc.value;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facets []CatalogCharacteristicFacet
+	for rows.Next() {
+		var char Characteristic
+		var value CatalogFacetValue
+		var number sql.NullFloat64
+		if err = rows.Scan(&char.Id, &char.Name, &char.Unit, &char.ValueType, &value.Value, &number, &value.Count); err != nil {
+			return nil, err
+		}
+
+		if len(facets) == 0 || facets[len(facets)-1].Characteristic.Id != char.Id {
+			facets = append(facets, CatalogCharacteristicFacet{Characteristic: char, MinValue: number.Float64, MaxValue: number.Float64})
+		}
+
+		facet := &facets[len(facets)-1]
+		facet.Values = append(facet.Values, value)
+		if number.Valid {
+			facet.MinValue = min(facet.MinValue, number.Float64)
+			facet.MaxValue = max(facet.MaxValue, number.Float64)
+		}
+	}
+
+	return facets, rows.Err()
+}
+
+func GetCatalogFacets(filter CatalogFilter) (CatalogFacets, error) {
+	var facets CatalogFacets
+
+	where, args := filter.where(catalogFacetManufacturer, 0)
+	rows, err := database.Query(
+		`SELECT p.manufacturer, COUNT(*)
+		FROM products p
+		WHERE `+where+`
+		GROUP BY p.manufacturer
+		ORDER BY COUNT(*) DESC, p.manufacturer;`,
+		args...,
+	)
+	if err != nil {
+		return facets, err
+	}
+	for rows.Next() {
+		var value CatalogFacetValue
+		if err = rows.Scan(&value.Value, &value.Count); err != nil {
+			rows.Close()
+			return facets, err
+		}
+		facets.Manufacturers = append(facets.Manufacturers, value)
+	}
+	rows.Close()
+	facets.Manufacturers = addMissingSelectedValues(facets.Manufacturers, filter.Manufacturers)
+
+	where, args = filter.where(catalogFacetStock, 0)
+	err = database.QueryRow("SELECT COUNT(*) FROM products p WHERE "+where+" AND "+catalogInStockCondition+";", args...).Scan(&facets.InStockCount)
+	if err != nil {
+		return facets, err
+	}
+
+	where, args = filter.where(catalogFacetRating, 0)
+	ratingCounts := make([]int, 4)
+	err = database.QueryRow(
+		`SELECT
+    		COALESCE(SUM(p.reviews_count > 0 AND p.rating >= 4), 0), COALESCE(SUM(p.reviews_count > 0 AND p.rating >= 3), 0),
+    		COALESCE(SUM(p.reviews_count > 0 AND p.rating >= 2), 0), COALESCE(SUM(p.reviews_count > 0 AND p.rating >= 1), 0)
+		FROM products p WHERE `+where+";",
+		args...,
+	).Scan(&ratingCounts[0], &ratingCounts[1], &ratingCounts[2], &ratingCounts[3])
+	if err != nil {
+		return facets, err
+	}
+	for idx, count := range ratingCounts {
+		rating := 4 - idx
+		facets.Ratings = append(facets.Ratings, CatalogFacetValue{
+			Value:    strconv.Itoa(rating),
+			Count:    count,
+			Selected: filter.MinRating == rating,
+		})
+	}
+
+	var minPrice, maxPrice sql.NullFloat64
+	where, args = filter.where(catalogFacetPrice, 0)
+	err = database.QueryRow("SELECT MIN(p.price), MAX(p.price) FROM products p WHERE "+where+";", args...).Scan(&minPrice, &maxPrice)
+	if err != nil {
+		return facets, err
+	}
+	facets.MinPrice = minPrice.Float64
+	facets.MaxPrice = maxPrice.Float64
+
+	facets.Characteristics, err = getCatalogCharacteristicFacets(&filter, 0)
+	if err != nil {
+		return facets, err
+	}
+
+	for _, charFilter := range filter.Characteristics {
+		if !charFilter.IsActive() {
+			continue
+		}
+
+		selectedFacets, err := getCatalogCharacteristicFacets(&filter, charFilter.CharacteristicId)
+		if err != nil {
+			return facets, err
+		}
+
+		if len(selectedFacets) == 0 {
+			char, err := GetCharacteristic(charFilter.CharacteristicId)
+			if err != nil {
+				continue
+			}
+			selectedFacets = []CatalogCharacteristicFacet{{Characteristic: char}}
+		}
+
+		selectedFacets[0].Values = addMissingSelectedValues(selectedFacets[0].Values, charFilter.Values)
+		selectedFacets[0].Filter = charFilter
+		facets.Characteristics = append(facets.Characteristics, selectedFacets[0])
+	}
+
+	sort.SliceStable(facets.Characteristics, func(i, j int) bool {
+		return facets.Characteristics[i].Characteristic.Name < facets.Characteristics[j].Characteristic.Name
+	})
+
+	return facets, nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"strings"
 )
 
@@ -9,27 +10,68 @@ type Category struct {
 	Id          int64
 	Name        string
 	Description string
+	ParentId    int64
+	ParentName  string
 }
 
-func GetCategories(page, pageSize int) ([]Category, int, error) {
-	return getRowsAndCount(
-		page,
-		pageSize,
-		func(page, pageSize int) (*sql.Rows, error) {
+type CategoryNode struct {
+	Category Category
+	Children []CategoryNode
+	Selected bool
+	Expanded bool
+}
+
+var CategoryCycle = errors.New("category cannot be a subcategory of itself")
+
+// maxCategoryDepth stops walking up the tree in case the parents were ever saved with a cycle
+const maxCategoryDepth = 32
+
+const categoryDescendantsQuery = `WITH RECURSIVE descendants AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION
+	SELECT dc.id FROM categories dc INNER JOIN descendants d ON dc.parent_id = d.id
+) SELECT id FROM descendants`
+
+const (
+	CategorySortDefault = ""
+	CategorySortName    = "name"
+)
+
+func categorySortKey(sort string) SortKey {
+	if sort == CategorySortName {
+		return SortKey{Expr: "c.name", IdExpr: "c.id"}
+	}
+
+	return SortKey{Expr: "c.id", IdExpr: "c.id"}
+}
+
+func GetCategories(cursorPage *CursorPage, sort string) ([]Category, int, error) {
+	key := categorySortKey(sort)
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				c.id, c.name, COALESCE(c.description, '')
+    				CAST(`+key.Expr+` AS CHAR),
+    				c.id, c.name, COALESCE(c.description, ''), COALESCE(c.parent_id, 0), COALESCE(pc.name, '')
 				FROM categories c
-				ORDER BY c.id LIMIT ? OFFSET ?;`,
-				pageSize, (page-1)*pageSize,
+				LEFT OUTER JOIN categories pc ON c.parent_id = pc.id
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
 			)
 		},
-		func(rows *sql.Rows) (Category, error) {
+		func(rows *sql.Rows) (Category, keysetCursor, error) {
 			category := Category{}
+			cursor := keysetCursor{}
 			err := rows.Scan(
-				&category.Id, &category.Name, &category.Description,
+				&cursor.Value,
+				&category.Id, &category.Name, &category.Description, &category.ParentId, &category.ParentName,
 			)
-			return category, err
+			cursor.Id = category.Id
+			return category, cursor, err
 		},
 		func() *sql.Row {
 			return database.QueryRow("SELECT COUNT(*) FROM `categories`;")
@@ -45,9 +87,16 @@ func CreateCategory(category Category) error {
 		description = sql.NullString{String: category.Description, Valid: true}
 	}
 
+	var parentId sql.NullInt64
+	if category.ParentId == 0 {
+		parentId = sql.NullInt64{}
+	} else {
+		parentId = sql.NullInt64{Int64: category.ParentId, Valid: true}
+	}
+
 	_, err := database.Exec(
-		"INSERT INTO categories (name, description) VALUES (?, ?);",
-		category.Name, description,
+		"INSERT INTO categories (name, description, parent_id) VALUES (?, ?, ?);",
+		category.Name, description, parentId,
 	)
 	return err
 }
@@ -56,11 +105,34 @@ func GetCategory(categoryId int64) (Category, error) {
 	var category Category
 
 	row := database.QueryRow(
-		"SELECT c.id, c.name, COALESCE(c.description, '') FROM categories c WHERE c.id = ?;",
+		`SELECT 
+    		c.id, c.name, COALESCE(c.description, ''), COALESCE(c.parent_id, 0), COALESCE(pc.name, '')
+		FROM categories c
+		LEFT OUTER JOIN categories pc ON c.parent_id = pc.id
+		WHERE c.id = ?;`,
 		categoryId,
 	)
 	err := row.Scan(
-		&category.Id, &category.Name, &category.Description,
+		&category.Id, &category.Name, &category.Description, &category.ParentId, &category.ParentName,
+	)
+
+	return category, err
+}
+
+func GetCategoryByName(name string) (Category, error) {
+	var category Category
+
+	row := database.QueryRow(
+		`SELECT 
+    		c.id, c.name, COALESCE(c.description, ''), COALESCE(c.parent_id, 0), COALESCE(pc.name, '')
+		FROM categories c
+		LEFT OUTER JOIN categories pc ON c.parent_id = pc.id
+		WHERE LOWER(c.name) = LOWER(?)
+		ORDER BY c.id LIMIT 1;`,
+		name,
+	)
+	err := row.Scan(
+		&category.Id, &category.Name, &category.Description, &category.ParentId, &category.ParentName,
 	)
 
 	return category, err
@@ -73,8 +145,9 @@ func SearchCategories(namePart string, limit int) ([]Category, error) {
 		func(page, pageSize int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				c.id, c.name, COALESCE(c.description, '')
+    				c.id, c.name, COALESCE(c.description, ''), COALESCE(c.parent_id, 0), COALESCE(pc.name, '')
 				FROM categories c
+				LEFT OUTER JOIN categories pc ON c.parent_id = pc.id
 				WHERE LOWER(c.name) like ?
 				ORDER BY c.id LIMIT ?;`,
 				"%"+strings.ToLower(namePart)+"%", pageSize,
@@ -82,7 +155,7 @@ func SearchCategories(namePart string, limit int) ([]Category, error) {
 		},
 		func(rows *sql.Rows) (Category, error) {
 			category := Category{}
-			err := rows.Scan(&category.Id, &category.Name, &category.Description)
+			err := rows.Scan(&category.Id, &category.Name, &category.Description, &category.ParentId, &category.ParentName)
 			return category, err
 		},
 		func() *sql.Row {
@@ -102,16 +175,138 @@ func (category *Category) DbSave() error {
 			description = sql.NullString{String: category.Description, Valid: true}
 		}
 
-		_, err := database.Exec(
-			"UPDATE categories SET name=?, description=? WHERE id=?;",
-			category.Name, description, category.Id,
+		tx, err := database.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var parentId sql.NullInt64
+		if category.ParentId == 0 {
+			parentId = sql.NullInt64{}
+		} else {
+			// Concurrent re-parenting could create a cycle between the check and the update,
+			// so the tree is locked until the transaction ends
+			if _, err = tx.Exec("SELECT id FROM categories FOR UPDATE;"); err != nil {
+				return err
+			}
+
+			var isDescendant bool
+			err = tx.QueryRow(
+				"SELECT ? IN ("+categoryDescendantsQuery+");",
+				category.ParentId, category.Id,
+			).Scan(&isDescendant)
+			if err != nil {
+				return err
+			}
+			if isDescendant {
+				return CategoryCycle
+			}
+
+			parentId = sql.NullInt64{Int64: category.ParentId, Valid: true}
+		}
+
+		_, err = tx.Exec(
+			"UPDATE categories SET name=?, description=?, parent_id=? WHERE id=?;",
+			category.Name, description, parentId, category.Id,
 		)
-		return err
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
 	}
 
 	return CreateCategory(*category)
 }
 
+func GetCategoryPath(categoryId int64) ([]Category, error) {
+	categories, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`WITH RECURSIVE path AS (
+					SELECT c.id, c.name, c.description, c.parent_id, 0 AS depth
+					FROM categories c WHERE c.id = ?
+					UNION ALL
+					SELECT c.id, c.name, c.description, c.parent_id, path.depth + 1
+					FROM categories c INNER JOIN path ON c.id = path.parent_id
+					WHERE path.depth < ?
+				)
+				SELECT id, name, COALESCE(description, ''), COALESCE(parent_id, 0)
+				FROM path
+				ORDER BY depth DESC;`,
+				categoryId, maxCategoryDepth,
+			)
+		},
+		func(rows *sql.Rows) (Category, error) {
+			category := Category{}
+			err := rows.Scan(&category.Id, &category.Name, &category.Description, &category.ParentId)
+			return category, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+
+	for idx := 1; idx < len(categories); idx++ {
+		categories[idx].ParentName = categories[idx-1].Name
+	}
+
+	return categories, err
+}
+
+func buildCategoryTree(children map[int64][]Category, parentId int64, visited map[int64]bool) []CategoryNode {
+	var nodes []CategoryNode
+	for _, category := range children[parentId] {
+		if visited[category.Id] {
+			continue
+		}
+		visited[category.Id] = true
+
+		nodes = append(nodes, CategoryNode{
+			Category: category,
+			Children: buildCategoryTree(children, category.Id, visited),
+		})
+	}
+	return nodes
+}
+
+func GetCategoryTree() ([]CategoryNode, error) {
+	categories, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				c.id, c.name, COALESCE(c.description, ''), COALESCE(c.parent_id, 0), COALESCE(pc.name, '')
+				FROM categories c
+				LEFT OUTER JOIN categories pc ON c.parent_id = pc.id
+				ORDER BY c.name, c.id;`,
+			)
+		},
+		func(rows *sql.Rows) (Category, error) {
+			category := Category{}
+			err := rows.Scan(&category.Id, &category.Name, &category.Description, &category.ParentId, &category.ParentName)
+			return category, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]Category)
+	for _, category := range categories {
+		children[category.ParentId] = append(children[category.ParentId], category)
+	}
+
+	return buildCategoryTree(children, 0, make(map[int64]bool)), nil
+}
+
 func (category *Category) DbDelete() error {
 	_, err := database.Exec("DELETE FROM `categories` WHERE `id`=?;", category.Id)
 	return err
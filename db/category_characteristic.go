@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type CategoryCharacteristic struct {
+	CategoryId     int64
+	Characteristic Characteristic
+	Required       bool
+	DisplayOrder   int
+}
+
+const missingRequiredCharacteristicsCondition = `NOT EXISTS (
+	SELECT 1 FROM category_characteristics rcc
+	WHERE rcc.category_id = p.category_id AND rcc.required AND NOT EXISTS (
+		SELECT 1 FROM product_characteristics rpc
+		WHERE rpc.product_id = p.id AND rpc.characteristic_id = rcc.characteristic_id AND rpc.value IS NOT NULL AND rpc.value != ''
+	)
+)`
+
+func GetCategoryCharacteristics(categoryId int64) ([]CategoryCharacteristic, error) {
+	chars, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT
+    				cc.category_id, cc.required, cc.display_order,
+    				c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type, COALESCE(c.allowed_values, '')
+				FROM category_characteristics cc
+				INNER JOIN characteristics c ON cc.characteristic_id = c.id
+				WHERE cc.category_id = ?
+				ORDER BY cc.display_order, c.name;`,
+				categoryId,
+			)
+		},
+		func(rows *sql.Rows) (CategoryCharacteristic, error) {
+			char := CategoryCharacteristic{}
+			var allowedValues string
+			err := rows.Scan(
+				&char.CategoryId, &char.Required, &char.DisplayOrder,
+				&char.Characteristic.Id, &char.Characteristic.Name, &char.Characteristic.Unit,
+				&char.Characteristic.ValueType, &allowedValues,
+			)
+			char.Characteristic.AllowedValues = ParseAllowedValues(allowedValues)
+			return char, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return chars, err
+}
+
+func (char *CategoryCharacteristic) DbSave() error {
+	_, err := database.Exec(
+		`INSERT INTO category_characteristics (category_id, characteristic_id, required, display_order)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE required = VALUES(required), display_order = VALUES(display_order);`,
+		char.CategoryId, char.Characteristic.Id, char.Required, char.DisplayOrder,
+	)
+	return err
+}
+
+func (char *CategoryCharacteristic) DbDelete() error {
+	_, err := database.Exec(
+		"DELETE FROM `category_characteristics` WHERE `category_id`=? AND `characteristic_id`=?;",
+		char.CategoryId, char.Characteristic.Id,
+	)
+	return err
+}
+
+func PrefillProductCharacteristics(ctx context.Context, productId int64, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
+	_, err := dbExec(
+		ctx,
+		`INSERT INTO product_characteristics (product_id, characteristic_id, value)
+		SELECT p.id, cc.characteristic_id, ''
+		FROM products p
+		INNER JOIN category_characteristics cc ON cc.category_id = p.category_id
+		WHERE p.id = ? AND NOT EXISTS (
+			SELECT 1 FROM product_characteristics pc WHERE pc.product_id = p.id AND pc.characteristic_id = cc.characteristic_id
+		)
+		ORDER BY cc.display_order;`,
+		productId,
+	)
+	return err
+}
+
+func PrefillCategoryProductsCharacteristics(categoryId int64) (int64, error) {
+	result, err := database.Exec(
+		`INSERT INTO product_characteristics (product_id, characteristic_id, value)
+		SELECT p.id, cc.characteristic_id, ''
+		FROM products p
+		INNER JOIN category_characteristics cc ON cc.category_id = p.category_id
+		WHERE p.category_id = ? AND NOT EXISTS (
+			SELECT 1 FROM product_characteristics pc WHERE pc.product_id = p.id AND pc.characteristic_id = cc.characteristic_id
+		)
+		ORDER BY p.id, cc.display_order;`,
+		categoryId,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func GetMissingRequiredCharacteristics(productId int64) ([]Characteristic, error) {
+	chars, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT c.id, c.name, COALESCE(c.measurement_unit, '')
+				FROM products p
+				INNER JOIN category_characteristics cc ON cc.category_id = p.category_id
+				INNER JOIN characteristics c ON cc.characteristic_id = c.id
+				WHERE p.id = ? AND cc.required AND NOT EXISTS (
+					SELECT 1 FROM product_characteristics pc
+					WHERE pc.product_id = p.id AND pc.characteristic_id = cc.characteristic_id AND pc.value IS NOT NULL AND pc.value != ''
+				)
+				ORDER BY cc.display_order, c.name;`,
+				productId,
+			)
+		},
+		func(rows *sql.Rows) (Characteristic, error) {
+			char := Characteristic{}
+			err := rows.Scan(&char.Id, &char.Name, &char.Unit)
+			return char, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return chars, err
+}
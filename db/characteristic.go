@@ -2,34 +2,142 @@ package db
 
 import (
 	"database/sql"
+	"errors"
+	"math"
+	"strconv"
 	"strings"
 )
 
+const (
+	CharacteristicText    = "text"
+	CharacteristicNumber  = "number"
+	CharacteristicBoolean = "boolean"
+	CharacteristicEnum    = "enum"
+)
+
+var CharacteristicTypes = []string{CharacteristicText, CharacteristicNumber, CharacteristicBoolean, CharacteristicEnum}
+
 type Characteristic struct {
-	Id   int64
-	Name string
-	Unit string
+	Id            int64
+	Name          string
+	Unit          string
+	ValueType     string
+	AllowedValues []string
 }
 
-func GetCharacteristics(page, pageSize int) ([]Characteristic, int, error) {
-	return getRowsAndCount(
-		page,
-		pageSize,
-		func(page, pageSize int) (*sql.Rows, error) {
+var InvalidNumberValue = errors.New("value must be a number")
+var InvalidBooleanValue = errors.New("value must be yes or no")
+var InvalidEnumValue = errors.New("value is not one of the allowed values")
+
+func ParseAllowedValues(text string) []string {
+	var values []string
+	for _, value := range strings.Split(text, "\n") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (characteristic Characteristic) AllowedValuesText() string {
+	return strings.Join(characteristic.AllowedValues, "\n")
+}
+
+func (characteristic Characteristic) IsNumeric() bool {
+	return characteristic.ValueType == CharacteristicNumber || characteristic.ValueType == CharacteristicBoolean
+}
+
+func (characteristic Characteristic) NormalizeValue(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	switch characteristic.ValueType {
+	case CharacteristicNumber:
+		number, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return value, InvalidNumberValue
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case CharacteristicBoolean:
+		switch strings.ToLower(value) {
+		case "yes", "true", "1", "y":
+			return "Yes", nil
+		case "no", "false", "0", "n":
+			return "No", nil
+		}
+		return value, InvalidBooleanValue
+	case CharacteristicEnum:
+		for _, allowed := range characteristic.AllowedValues {
+			if strings.EqualFold(allowed, value) {
+				return allowed, nil
+			}
+		}
+		return value, InvalidEnumValue
+	}
+
+	return value, nil
+}
+
+func (characteristic Characteristic) numberValue(value string) sql.NullFloat64 {
+	switch characteristic.ValueType {
+	case CharacteristicNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return sql.NullFloat64{}
+		}
+		return sql.NullFloat64{Float64: number, Valid: true}
+	case CharacteristicBoolean:
+		if value == "Yes" {
+			return sql.NullFloat64{Float64: 1, Valid: true}
+		} else if value == "No" {
+			return sql.NullFloat64{Float64: 0, Valid: true}
+		}
+	}
+
+	return sql.NullFloat64{}
+}
+
+const (
+	CharacteristicSortDefault = ""
+	CharacteristicSortName    = "name"
+)
+
+func characteristicSortKey(sort string) SortKey {
+	if sort == CharacteristicSortName {
+		return SortKey{Expr: "c.name", IdExpr: "c.id"}
+	}
+
+	return SortKey{Expr: "c.id", IdExpr: "c.id"}
+}
+
+func GetCharacteristics(cursorPage *CursorPage, sort string) ([]Characteristic, int, error) {
+	key := characteristicSortKey(sort)
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				c.id, c.name, COALESCE(c.measurement_unit, '')
+    				CAST(`+key.Expr+` AS CHAR),
+    				c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type, COALESCE(c.allowed_values, '')
 				FROM characteristics c
-				ORDER BY c.id LIMIT ? OFFSET ?;`,
-				pageSize, (page-1)*pageSize,
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
 			)
 		},
-		func(rows *sql.Rows) (Characteristic, error) {
+		func(rows *sql.Rows) (Characteristic, keysetCursor, error) {
 			characteristic := Characteristic{}
+			cursor := keysetCursor{}
+			var allowedValues string
 			err := rows.Scan(
-				&characteristic.Id, &characteristic.Name, &characteristic.Unit,
+				&cursor.Value,
+				&characteristic.Id, &characteristic.Name, &characteristic.Unit, &characteristic.ValueType, &allowedValues,
 			)
-			return characteristic, err
+			characteristic.AllowedValues = ParseAllowedValues(allowedValues)
+			cursor.Id = characteristic.Id
+			return characteristic, cursor, err
 		},
 		func() *sql.Row {
 			return database.QueryRow("SELECT COUNT(*) FROM `characteristics`;")
@@ -44,7 +152,7 @@ func SearchCharacteristics(namePart string, limit int) ([]Characteristic, error)
 		func(page, pageSize int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				c.id, c.name, COALESCE(c.measurement_unit, '')
+    				c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type, COALESCE(c.allowed_values, '')
 				FROM characteristics c
 				WHERE LOWER(c.name) LIKE ?
 				ORDER BY c.id LIMIT ?;`,
@@ -53,9 +161,11 @@ func SearchCharacteristics(namePart string, limit int) ([]Characteristic, error)
 		},
 		func(rows *sql.Rows) (Characteristic, error) {
 			characteristic := Characteristic{}
+			var allowedValues string
 			err := rows.Scan(
-				&characteristic.Id, &characteristic.Name, &characteristic.Unit,
+				&characteristic.Id, &characteristic.Name, &characteristic.Unit, &characteristic.ValueType, &allowedValues,
 			)
+			characteristic.AllowedValues = ParseAllowedValues(allowedValues)
 			return characteristic, err
 		},
 		func() *sql.Row {
@@ -74,23 +184,52 @@ func CreateCharacteristic(characteristic Characteristic) error {
 		unit = sql.NullString{String: characteristic.Unit, Valid: true}
 	}
 
+	var allowedValues sql.NullString
+	if len(characteristic.AllowedValues) == 0 {
+		allowedValues = sql.NullString{}
+	} else {
+		allowedValues = sql.NullString{String: characteristic.AllowedValuesText(), Valid: true}
+	}
+
+	if characteristic.ValueType == "" {
+		characteristic.ValueType = CharacteristicText
+	}
+
 	_, err := database.Exec(
-		"INSERT INTO characteristics (name, measurement_unit) VALUES (?, ?);",
-		characteristic.Name, unit,
+		"INSERT INTO characteristics (name, measurement_unit, value_type, allowed_values) VALUES (?, ?, ?, ?);",
+		characteristic.Name, unit, characteristic.ValueType, allowedValues,
 	)
 	return err
 }
 
 func GetCharacteristic(characteristicId int64) (Characteristic, error) {
 	var characteristic Characteristic
+	var allowedValues string
 
 	row := database.QueryRow(
-		"SELECT c.id, c.name, COALESCE(c.measurement_unit, '') FROM characteristics c WHERE c.id = ?;",
+		"SELECT c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type, COALESCE(c.allowed_values, '') FROM characteristics c WHERE c.id = ?;",
 		characteristicId,
 	)
 	err := row.Scan(
-		&characteristic.Id, &characteristic.Name, &characteristic.Unit,
+		&characteristic.Id, &characteristic.Name, &characteristic.Unit, &characteristic.ValueType, &allowedValues,
+	)
+	characteristic.AllowedValues = ParseAllowedValues(allowedValues)
+
+	return characteristic, err
+}
+
+func GetCharacteristicByName(name string) (Characteristic, error) {
+	var characteristic Characteristic
+	var allowedValues string
+
+	row := database.QueryRow(
+		"SELECT c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type, COALESCE(c.allowed_values, '') FROM characteristics c WHERE LOWER(c.name) = LOWER(?) ORDER BY c.id LIMIT 1;",
+		name,
+	)
+	err := row.Scan(
+		&characteristic.Id, &characteristic.Name, &characteristic.Unit, &characteristic.ValueType, &allowedValues,
 	)
+	characteristic.AllowedValues = ParseAllowedValues(allowedValues)
 
 	return characteristic, err
 }
@@ -104,11 +243,32 @@ func (characteristic *Characteristic) DbSave() error {
 			unit = sql.NullString{String: characteristic.Unit, Valid: true}
 		}
 
-		_, err := database.Exec(
-			"UPDATE characteristics SET name=?, measurement_unit=? WHERE id=?;",
-			characteristic.Name, unit, characteristic.Id,
+		var allowedValues sql.NullString
+		if len(characteristic.AllowedValues) == 0 {
+			allowedValues = sql.NullString{}
+		} else {
+			allowedValues = sql.NullString{String: characteristic.AllowedValuesText(), Valid: true}
+		}
+
+		tx, err := database.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(
+			"UPDATE characteristics SET name=?, measurement_unit=?, value_type=?, allowed_values=? WHERE id=?;",
+			characteristic.Name, unit, characteristic.ValueType, allowedValues, characteristic.Id,
 		)
-		return err
+		if err != nil {
+			return err
+		}
+
+		if err = characteristic.updateNumberValues(tx); err != nil {
+			return err
+		}
+
+		return tx.Commit()
 	}
 
 	return CreateCharacteristic(*characteristic)
@@ -118,3 +278,20 @@ func (characteristic *Characteristic) DbDelete() error {
 	_, err := database.Exec("DELETE FROM `characteristics` WHERE `id`=?;", characteristic.Id)
 	return err
 }
+
+// updateNumberValues recomputes value_number of all products after the value type has changed,
+// the same way numberValue does for a single value.
+func (characteristic *Characteristic) updateNumberValues(tx *sql.Tx) error {
+	var number string
+	switch characteristic.ValueType {
+	case CharacteristicNumber:
+		number = "IF(value REGEXP '^-?[0-9]+([.][0-9]+)?$', CAST(value AS DOUBLE), NULL)"
+	case CharacteristicBoolean:
+		number = "CASE value WHEN 'Yes' THEN 1 WHEN 'No' THEN 0 END"
+	default:
+		number = "NULL"
+	}
+
+	_, err := tx.Exec("UPDATE product_characteristics SET value_number = "+number+" WHERE characteristic_id=?;", characteristic.Id)
+	return err
+}
@@ -14,23 +14,46 @@ type Customer struct {
 	Email     string
 }
 
-func GetCustomers(page, pageSize int) ([]Customer, int, error) {
-	return getRowsAndCount(
-		page,
-		pageSize,
-		func(page, pageSize int) (*sql.Rows, error) {
+const (
+	CustomerSortDefault = ""
+	CustomerSortName    = "name"
+	CustomerSortEmail   = "email"
+)
+
+func customerSortKey(sort string) SortKey {
+	switch sort {
+	case CustomerSortName:
+		return SortKey{Expr: "CONCAT(c.last_name, ' ', c.first_name)", IdExpr: "c.id"}
+	case CustomerSortEmail:
+		return SortKey{Expr: "c.email", IdExpr: "c.id"}
+	}
+
+	return SortKey{Expr: "c.id", IdExpr: "c.id"}
+}
+
+func GetCustomers(cursorPage *CursorPage, sort string) ([]Customer, int, error) {
+	key := customerSortKey(sort)
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
+    				CAST(`+key.Expr+` AS CHAR),
     				c.id, c.first_name, c.last_name, c.email
 				FROM customers c
-				ORDER BY c.id LIMIT ? OFFSET ?;`,
-				pageSize, (page-1)*pageSize,
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
 			)
 		},
-		func(rows *sql.Rows) (Customer, error) {
+		func(rows *sql.Rows) (Customer, keysetCursor, error) {
 			customer := Customer{}
-			err := rows.Scan(&customer.Id, &customer.FirstName, &customer.LastName, &customer.Email)
-			return customer, err
+			cursor := keysetCursor{}
+			err := rows.Scan(&cursor.Value, &customer.Id, &customer.FirstName, &customer.LastName, &customer.Email)
+			cursor.Id = customer.Id
+			return customer, cursor, err
 		},
 		func() *sql.Row {
 			return database.QueryRow("SELECT COUNT(*) FROM `customers`;")
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	RfmChampions          = "Champions"
+	RfmLoyal              = "Loyal customers"
+	RfmNew                = "New customers"
+	RfmPotentialLoyalists = "Potential loyalists"
+	RfmCantLose           = "Can't lose them"
+	RfmAtRisk             = "At risk"
+	RfmAboutToSleep       = "About to sleep"
+	RfmHibernating        = "Hibernating"
+)
+
+var RfmSegments = []string{
+	RfmChampions, RfmLoyal, RfmNew, RfmPotentialLoyalists, RfmCantLose, RfmAtRisk, RfmAboutToSleep, RfmHibernating,
+}
+
+type CustomerRfm struct {
+	Customer    Customer
+	Orders      int
+	Revenue     float64
+	LastOrderAt time.Time
+	RecencyDays int
+	Recency     int
+	Frequency   int
+	Monetary    int
+	Segment     string
+}
+
+// RfmSegment maps recency and frequency scores (1 to 5, higher is better) to a segment name.
+func RfmSegment(recency, frequency int) string {
+	switch {
+	case recency >= 4 && frequency >= 4:
+		return RfmChampions
+	case recency >= 3 && frequency >= 3:
+		return RfmLoyal
+	case recency >= 4 && frequency <= 1:
+		return RfmNew
+	case recency >= 3:
+		return RfmPotentialLoyalists
+	case frequency >= 4:
+		return RfmCantLose
+	case frequency >= 3:
+		return RfmAtRisk
+	case recency == 2:
+		return RfmAboutToSleep
+	default:
+		return RfmHibernating
+	}
+}
+
+// customerTotalsQuery returns a derived table with orders count, revenue, first and last order time
+// of every customer who ordered within the filter.
+func customerTotalsQuery(filter AnalysisFilter) (string, []any) {
+	where, args := filter.where("o", "oi")
+	return `(
+		SELECT t.customer_id, COUNT(*) AS orders_count, SUM(t.total) AS revenue, MIN(t.created_at) AS first_order_at, MAX(t.created_at) AS last_order_at
+		FROM (
+			SELECT o.id, o.customer_id, o.created_at, SUM(oi.quantity * oi.price_per_item) AS total
+			FROM orders o
+				INNER JOIN order_items oi ON oi.order_id = o.id
+			WHERE o.customer_id IS NOT NULL AND ` + where + `
+			GROUP BY o.id
+		) t
+		GROUP BY t.customer_id
+	)`, args
+}
+
+// rfmScore splits customers into 5 groups by PERCENT_RANK of the value, so equal values always get the same score.
+func rfmScore(expr string) string {
+	return "CAST(LEAST(5, FLOOR(PERCENT_RANK() OVER (ORDER BY " + expr + ") * 5) + 1) AS SIGNED)"
+}
+
+func GetCustomersRfm(filter AnalysisFilter) ([]CustomerRfm, error) {
+	totals, args := customerTotalsQuery(filter)
+	customers, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT
+					c.id, c.first_name, c.last_name, c.email, t.orders_count, t.revenue, t.last_order_at,
+					`+rfmScore("t.last_order_at")+`,
+					`+rfmScore("t.orders_count")+`,
+					`+rfmScore("t.revenue")+`
+				FROM `+totals+` t
+					INNER JOIN customers c ON c.id = t.customer_id
+				ORDER BY t.revenue DESC;`,
+				args...,
+			)
+		},
+		func(rows *sql.Rows) (CustomerRfm, error) {
+			rfm := CustomerRfm{}
+			err := rows.Scan(
+				&rfm.Customer.Id, &rfm.Customer.FirstName, &rfm.Customer.LastName, &rfm.Customer.Email, &rfm.Orders, &rfm.Revenue, &rfm.LastOrderAt,
+				&rfm.Recency, &rfm.Frequency, &rfm.Monetary,
+			)
+			rfm.RecencyDays = int(filter.To.AddDate(0, 0, 1).Sub(rfm.LastOrderAt).Hours() / 24)
+			rfm.Segment = RfmSegment(rfm.Recency, rfm.Frequency)
+			return rfm, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return customers, err
+}
+
+type CustomerValueSummary struct {
+	Customers           int
+	RepeatCustomers     int
+	Orders              int
+	Revenue             float64
+	AverageLifespanDays float64
+	AverageDaysBetween  float64
+}
+
+func (summary CustomerValueSummary) RepeatRate() float64 {
+	if summary.Customers == 0 {
+		return 0
+	}
+	return float64(summary.RepeatCustomers) / float64(summary.Customers)
+}
+
+func (summary CustomerValueSummary) AverageOrderValue() float64 {
+	if summary.Orders == 0 {
+		return 0
+	}
+	return summary.Revenue / float64(summary.Orders)
+}
+
+func (summary CustomerValueSummary) PurchaseFrequency() float64 {
+	if summary.Customers == 0 {
+		return 0
+	}
+	return float64(summary.Orders) / float64(summary.Customers)
+}
+
+// LifetimeValue is the average revenue per customer over the period, i.e. average order value times purchase frequency.
+func (summary CustomerValueSummary) LifetimeValue() float64 {
+	return summary.AverageOrderValue() * summary.PurchaseFrequency()
+}
+
+func GetCustomerValueSummary(filter AnalysisFilter) (CustomerValueSummary, error) {
+	var summary CustomerValueSummary
+
+	totals, args := customerTotalsQuery(filter)
+	err := database.QueryRow(
+		`SELECT
+			COUNT(*), COALESCE(SUM(t.orders_count >= 2), 0), COALESCE(SUM(t.orders_count), 0), COALESCE(SUM(t.revenue), 0),
+			COALESCE(AVG(DATEDIFF(t.last_order_at, t.first_order_at)), 0),
+			COALESCE(AVG(IF(t.orders_count >= 2, DATEDIFF(t.last_order_at, t.first_order_at) / (t.orders_count - 1), NULL)), 0)
+		FROM `+totals+` t;`,
+		args...,
+	).Scan(&summary.Customers, &summary.RepeatCustomers, &summary.Orders, &summary.Revenue, &summary.AverageLifespanDays, &summary.AverageDaysBetween)
+
+	return summary, err
+}
+
+type Cohort struct {
+	Month     time.Time
+	Customers int
+	// Active holds the number of the cohort's customers who ordered in each month since acquisition
+	Active []int
+}
+
+// GetCohorts groups customers by the month of their first order ever and counts how many of them
+// ordered again in the following months. Only cohorts acquired and orders made within the filter are included.
+func GetCohorts(filter AnalysisFilter) ([]Cohort, error) {
+	allTime := filter
+	allTime.From = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	firstWhere, firstArgs := allTime.where("fo")
+	where, args := filter.where("o")
+
+	rows, err := database.Query(
+		`SELECT f.first_month, PERIOD_DIFF(DATE_FORMAT(o.created_at, '%Y%m'), DATE_FORMAT(f.first_month, '%Y%m')) AS month_offset, COUNT(DISTINCT o.customer_id)
+		FROM orders o
+			INNER JOIN (
+				SELECT fo.customer_id, MIN(fo.created_at) AS first_order_at, DATE(DATE_FORMAT(MIN(fo.created_at), '%Y-%m-01')) AS first_month
+				FROM orders fo
+				WHERE fo.customer_id IS NOT NULL AND `+firstWhere+`
+				GROUP BY fo.customer_id
+			) f ON f.customer_id = o.customer_id
+		WHERE f.first_order_at >= ? AND `+where+`
+		GROUP BY f.first_month, month_offset
+		ORDER BY f.first_month, month_offset;`,
+		append(append(firstArgs, filter.From), args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cohorts []Cohort
+	for rows.Next() {
+		var month time.Time
+		var offset, count int
+		if err = rows.Scan(&month, &offset, &count); err != nil {
+			return nil, err
+		}
+
+		if len(cohorts) == 0 || !cohorts[len(cohorts)-1].Month.Equal(month) {
+			cohorts = append(cohorts, Cohort{Month: month})
+		}
+		cohort := &cohorts[len(cohorts)-1]
+		for len(cohort.Active) <= offset {
+			cohort.Active = append(cohort.Active, 0)
+		}
+		cohort.Active[offset] = count
+		if offset == 0 {
+			cohort.Customers = count
+		}
+	}
+
+	return cohorts, rows.Err()
+}
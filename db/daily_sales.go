@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// Rollup rows with an empty status cover orders of all statuses and rows with category 0 cover all categories,
+// so distinct counts and medians are exact for every filter instead of being summed from parts.
+const rollupStatuses = `(SELECT 0 AS all_statuses UNION ALL SELECT 1) st`
+
+const rollupStatus = `IF(st.all_statuses, '', o.status)`
+
+const categoryAncestorsQuery = `WITH RECURSIVE ancestors (category_id, ancestor_id) AS (
+		SELECT id, id FROM categories
+		UNION
+		SELECT a.category_id, c.parent_id FROM ancestors a INNER JOIN categories c ON c.id = a.ancestor_id WHERE c.parent_id IS NOT NULL
+	)
+	SELECT category_id, ancestor_id FROM ancestors`
+
+func rollupDailySales(ctx context.Context, dayCondition string, args []any, tx *sql.Tx) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO daily_sales (day, status, category_id, orders_count, customers_count, items_sold, revenue, median_total)
+		SELECT day, status, category_id, COUNT(*), COUNT(DISTINCT customer_id), SUM(items), SUM(total), MAX(median_total)
+		FROM (
+			SELECT
+				t.day, IF(st.all_statuses, '', t.status) AS status, t.category_id, t.customer_id, t.items, t.total,
+				MEDIAN(t.total) OVER (PARTITION BY t.day, IF(st.all_statuses, '', t.status), t.category_id) AS median_total
+			FROM (
+				SELECT
+					DATE(o.created_at) AS day, o.status, o.customer_id, pc.category_id,
+					SUM(oi.quantity) AS items, SUM(oi.quantity * oi.price_per_item) AS total
+				FROM orders o
+					INNER JOIN order_items oi ON oi.order_id = o.id
+					INNER JOIN (
+						SELECT p.id AS product_id, 0 AS category_id FROM products p
+						UNION ALL
+						SELECT p.id, a.ancestor_id FROM products p INNER JOIN (`+categoryAncestorsQuery+`) a ON a.category_id = p.category_id
+					) pc ON pc.product_id = oi.product_id
+				WHERE `+dayCondition+`
+				GROUP BY o.id, pc.category_id
+			) t
+			CROSS JOIN `+rollupStatuses+`
+		) x
+		GROUP BY day, status, category_id;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO daily_product_sales (day, status, product_id, orders_count, quantity, revenue)
+		SELECT DATE(o.created_at), `+rollupStatus+`, oi.product_id, COUNT(DISTINCT o.id), SUM(oi.quantity), SUM(oi.quantity * oi.price_per_item)
+		FROM orders o
+			INNER JOIN order_items oi ON oi.order_id = o.id
+			CROSS JOIN `+rollupStatuses+`
+		WHERE `+dayCondition+`
+		GROUP BY DATE(o.created_at), `+rollupStatus+`, oi.product_id;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO daily_product_pairs (day, status, product_id, related_product_id, together_count)
+		SELECT DATE(o.created_at), `+rollupStatus+`, oi1.product_id, oi2.product_id, COUNT(DISTINCT o.id)
+		FROM orders o
+			INNER JOIN order_items oi1 ON oi1.order_id = o.id
+			INNER JOIN order_items oi2 ON oi2.order_id = o.id AND oi1.product_id < oi2.product_id
+			CROSS JOIN `+rollupStatuses+`
+		WHERE `+dayCondition+`
+		GROUP BY DATE(o.created_at), `+rollupStatus+`, oi1.product_id, oi2.product_id;`,
+		args...,
+	)
+	return err
+}
+
+func RebuildDailySales(ctx context.Context) error {
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"daily_sales", "daily_product_sales", "daily_product_pairs"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+";"); err != nil {
+			return err
+		}
+	}
+
+	if err = rollupDailySales(ctx, "1 = 1", nil, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshDailySales recomputes the rollups of a single day, which keeps them exact after any change of that day's orders.
+func RefreshDailySales(ctx context.Context, day time.Time) error {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"daily_sales", "daily_product_sales", "daily_product_pairs"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE day = ?;", day); err != nil {
+			return err
+		}
+	}
+
+	err = rollupDailySales(ctx, "o.created_at >= ? AND o.created_at < ?", []any{day, day.AddDate(0, 0, 1)}, tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func dailySalesEmpty() (bool, error) {
+	var exists bool
+	err := database.QueryRow("SELECT EXISTS (SELECT 1 FROM daily_sales);").Scan(&exists)
+	return !exists, err
+}
+
+func nextDailySalesRebuild(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// DailySalesLoop rebuilds the rollups every night at rebuildHour, and right away if they were never built.
+func DailySalesLoop(rebuildHour int) {
+	timer := time.NewTimer(time.Until(nextDailySalesRebuild(time.Now(), rebuildHour)))
+	if empty, err := dailySalesEmpty(); err == nil && empty {
+		timer.Reset(0)
+	}
+
+	for {
+		<-timer.C
+		log.Println("Rebuilding daily sales because of timer")
+
+		timer.Reset(time.Until(nextDailySalesRebuild(time.Now(), rebuildHour)))
+		if err := RebuildDailySales(context.Background()); err != nil {
+			log.Printf("Failed to rebuild daily sales: %s\n", err)
+		}
+	}
+}
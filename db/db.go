@@ -3,8 +3,11 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 )
 
@@ -57,6 +60,128 @@ func getRowsAndCount[T any](page, pageSize int, getRows func(int, int) (*sql.Row
 	return objects, count, nil
 }
 
+type CursorPage struct {
+	PageSize   int
+	After      string
+	Before     string
+	NextCursor string
+	PrevCursor string
+}
+
+type SortKey struct {
+	Expr   string
+	IdExpr string
+	Desc   bool
+}
+
+type keysetCursor struct {
+	Value string `json:"v"`
+	Id    int64  `json:"id"`
+}
+
+func encodeCursor(cursor keysetCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(encoded string) (keysetCursor, bool) {
+	var cursor keysetCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return cursor, false
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return cursor, false
+	}
+
+	return cursor, true
+}
+
+func getRowsAndCountByCursor[T any](cursorPage *CursorPage, key SortKey, getRows func(string, []any, string, int) (*sql.Rows, error), scanRow func(*sql.Rows) (T, keysetCursor, error), getCount func() *sql.Row) ([]T, int, error) {
+	var objects []T
+	var cursors []keysetCursor
+
+	cursor, hasCursor := decodeCursor(cursorPage.After)
+	reverse := false
+	if !hasCursor {
+		cursor, hasCursor = decodeCursor(cursorPage.Before)
+		reverse = hasCursor
+	}
+
+	desc := key.Desc != reverse
+	op, direction := ">", "ASC"
+	if desc {
+		op, direction = "<", "DESC"
+	}
+
+	keyset := "1 = 1"
+	var keysetArgs []any
+	if hasCursor {
+		keyset = fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s %[2]s ?))", key.Expr, op, key.IdExpr)
+		keysetArgs = []any{cursor.Value, cursor.Value, cursor.Id}
+	}
+	orderBy := fmt.Sprintf("%s %s, %s %s", key.Expr, direction, key.IdExpr, direction)
+
+	rows, err := getRows(keyset, keysetArgs, orderBy, cursorPage.PageSize+1)
+	if err != nil {
+		if rows != nil {
+			rows.Close()
+		}
+		log.Println(err)
+		return objects, 0, err
+	}
+
+	defer rows.Close()
+
+	// Skipping a row would move the cursors, so scan errors fail the whole page
+	for rows.Next() {
+		object, objectCursor, err := scanRow(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, 0, err
+		}
+		objects = append(objects, object)
+		cursors = append(cursors, objectCursor)
+	}
+	if err = rows.Err(); err != nil {
+		log.Println(err)
+		return nil, 0, err
+	}
+	rows.Close()
+
+	hasMore := len(objects) > cursorPage.PageSize
+	if hasMore {
+		objects = objects[:cursorPage.PageSize]
+		cursors = cursors[:cursorPage.PageSize]
+	}
+
+	if reverse {
+		slices.Reverse(objects)
+		slices.Reverse(cursors)
+	}
+
+	cursorPage.NextCursor, cursorPage.PrevCursor = "", ""
+	if len(objects) > 0 {
+		if (!reverse && hasMore) || (reverse && hasCursor) {
+			cursorPage.NextCursor = encodeCursor(cursors[len(cursors)-1])
+		}
+		if (reverse && hasMore) || (!reverse && hasCursor) {
+			cursorPage.PrevCursor = encodeCursor(cursors[0])
+		}
+	}
+
+	var count int
+	row := getCount()
+	err = row.Scan(&count)
+	if err != nil {
+		log.Println(err)
+		return objects, 0, err
+	}
+
+	return objects, count, nil
+}
+
 func BeginTx(ctx context.Context) (*sql.Tx, error) {
 	return database.BeginTx(ctx, nil)
 }
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FunnelCatalogView      = "catalog_view"
+	FunnelProductView      = "product_view"
+	FunnelAddToCart        = "add_to_cart"
+	FunnelCheckoutStarted  = "checkout_started"
+	FunnelPaymentStarted   = "payment_started"
+	FunnelPaymentCompleted = "payment_completed"
+)
+
+var FunnelSteps = []string{
+	FunnelCatalogView, FunnelProductView, FunnelAddToCart, FunnelCheckoutStarted, FunnelPaymentStarted, FunnelPaymentCompleted,
+}
+
+// FunnelEvent is a step of a visitor session. Sessions are identified by the cart id cookie, but events don't reference
+// carts so they are kept when old carts are cleaned.
+type FunnelEvent struct {
+	SessionId uuid.UUID
+	Event     string
+	ProductId int64
+	OrderId   int64
+	Quantity  int
+}
+
+func nullInt64(value int64) sql.NullInt64 {
+	if value == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: value, Valid: true}
+}
+
+func TrackFunnelEvent(ctx context.Context, event FunnelEvent) error {
+	_, err := database.ExecContext(
+		ctx,
+		"INSERT INTO funnel_events (session_id, event, product_id, order_id, quantity) VALUES (?, ?, ?, ?, ?);",
+		event.SessionId, event.Event, nullInt64(event.ProductId), nullInt64(event.OrderId), event.Quantity,
+	)
+	return err
+}
+
+// TrackOrderFunnelEvent records an event of an order, attributing it to the session which started the order's payment
+// if it is known, because payments may be completed from another browser.
+func TrackOrderFunnelEvent(ctx context.Context, event FunnelEvent) error {
+	var sessionId uuid.UUID
+	err := database.QueryRowContext(
+		ctx,
+		"SELECT session_id FROM funnel_events WHERE order_id = ? AND event = ? ORDER BY id LIMIT 1;",
+		event.OrderId, FunnelPaymentStarted,
+	).Scan(&sessionId)
+	if err == nil {
+		event.SessionId = sessionId
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return TrackFunnelEvent(ctx, event)
+}
+
+func (filter AnalysisFilter) eventsWhere(alias string) (string, []any) {
+	return alias + ".created_at >= ? AND " + alias + ".created_at < ?", []any{filter.From, filter.To.AddDate(0, 0, 1)}
+}
+
+// GetFunnelSessions returns the number of distinct sessions that reached every step.
+func GetFunnelSessions(filter AnalysisFilter) (map[string]int, error) {
+	where, args := filter.eventsWhere("e")
+	rows, err := database.Query(
+		`SELECT e.event, COUNT(DISTINCT e.session_id)
+		FROM funnel_events e
+		WHERE `+where+`
+		GROUP BY e.event;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make(map[string]int)
+	for rows.Next() {
+		var event string
+		var count int
+		if err = rows.Scan(&event, &count); err != nil {
+			return nil, err
+		}
+		sessions[event] = count
+	}
+
+	return sessions, rows.Err()
+}
+
+type FunnelDay struct {
+	Day       time.Time
+	Visits    int
+	AddToCart int
+	Completed int
+}
+
+func (day FunnelDay) ConversionRate() float64 {
+	if day.Visits == 0 {
+		return 0
+	}
+	return float64(day.Completed) / float64(day.Visits)
+}
+
+func (day FunnelDay) AbandonmentRate() float64 {
+	if day.AddToCart == 0 {
+		return 0
+	}
+	return 1 - float64(day.Completed)/float64(day.AddToCart)
+}
+
+// GetFunnelPerDay counts sessions that visited the shop, added products to the cart and completed a payment on each day.
+// Days without events are omitted.
+func GetFunnelPerDay(filter AnalysisFilter) ([]FunnelDay, error) {
+	where, args := filter.eventsWhere("e")
+	days, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT
+					DATE(e.created_at) AS day,
+					COUNT(DISTINCT IF(e.event IN (?, ?), e.session_id, NULL)),
+					COUNT(DISTINCT IF(e.event = ?, e.session_id, NULL)),
+					COUNT(DISTINCT IF(e.event = ?, e.session_id, NULL))
+				FROM funnel_events e
+				WHERE `+where+`
+				GROUP BY day
+				ORDER BY day;`,
+				append([]any{FunnelCatalogView, FunnelProductView, FunnelAddToCart, FunnelPaymentCompleted}, args...)...,
+			)
+		},
+		func(rows *sql.Rows) (FunnelDay, error) {
+			day := FunnelDay{}
+			err := rows.Scan(&day.Day, &day.Visits, &day.AddToCart, &day.Completed)
+			return day, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return days, err
+}
+
+type AbandonedProduct struct {
+	Product   Product
+	Added     int
+	Abandoned int
+}
+
+func (product AbandonedProduct) AbandonmentRate() float64 {
+	if product.Added == 0 {
+		return 0
+	}
+	return float64(product.Abandoned) / float64(product.Added)
+}
+
+// GetMostAbandonedProducts returns products added to carts in sessions that didn't complete a payment afterward.
+func GetMostAbandonedProducts(filter AnalysisFilter, limit int) ([]AbandonedProduct, error) {
+	where, args := filter.eventsWhere("e")
+	if filter.CategoryId != 0 {
+		where += " AND e.product_id IN (SELECT cp.id FROM products cp WHERE cp.category_id IN (" + categoryDescendantsQuery + "))"
+		args = append(args, filter.CategoryId)
+	}
+
+	rows, err := database.Query(
+		`SELECT a.product_id, COUNT(*), SUM(NOT EXISTS (
+			SELECT 1 FROM funnel_events c WHERE c.session_id = a.session_id AND c.event = ? AND c.created_at >= a.added_at
+		)) AS abandoned_count
+		FROM (
+			SELECT e.product_id, e.session_id, MAX(e.created_at) AS added_at
+			FROM funnel_events e
+			WHERE e.event = ? AND e.product_id IS NOT NULL AND `+where+`
+			GROUP BY e.product_id, e.session_id
+		) a
+		GROUP BY a.product_id
+		HAVING abandoned_count > 0
+		ORDER BY abandoned_count DESC, a.product_id
+		LIMIT ?;`,
+		append(append([]any{FunnelPaymentCompleted, FunnelAddToCart}, args...), limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AbandonedProduct
+	var productIds []int64
+	for rows.Next() {
+		var row AbandonedProduct
+		if err = rows.Scan(&row.Product.Id, &row.Added, &row.Abandoned); err != nil {
+			return nil, err
+		}
+		productIds = append(productIds, row.Product.Id)
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	products, err := GetProductsByIds(productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	productsById := make(map[int64]Product, len(products))
+	for _, product := range products {
+		productsById[product.Id] = product
+	}
+	for i := range result {
+		if product, ok := productsById[result[i].Product.Id]; ok {
+			result[i].Product = product
+		}
+	}
+
+	return result, nil
+}
@@ -6,6 +6,14 @@ import (
 	"time"
 )
 
+const (
+	OrderStatusCreated  = "created"
+	OrderStatusPayment  = "payment"
+	OrderStatusComplete = "complete"
+)
+
+var OrderStatuses = []string{OrderStatusCreated, OrderStatusPayment, OrderStatusComplete}
+
 type Order struct {
 	Id        int64
 	Customer  Customer
@@ -15,28 +23,56 @@ type Order struct {
 	PayPalId  string
 }
 
-func GetOrders(page, pageSize int) ([]Order, int, error) {
-	return getRowsAndCount(
-		page,
-		pageSize,
-		func(page, pageSize int) (*sql.Rows, error) {
+const (
+	OrderSortDefault = ""
+	OrderSortNewest  = "newest"
+	OrderSortOldest  = "oldest"
+	OrderSortStatus  = "status"
+)
+
+func orderSortKey(sort string) SortKey {
+	switch sort {
+	case OrderSortNewest:
+		return SortKey{Expr: "o.created_at", IdExpr: "o.id", Desc: true}
+	case OrderSortOldest:
+		return SortKey{Expr: "o.created_at", IdExpr: "o.id"}
+	case OrderSortStatus:
+		// Enums are ordered by their index but compared as strings, the cursor needs both to match
+		return SortKey{Expr: "CAST(o.status AS CHAR)", IdExpr: "o.id"}
+	}
+
+	return SortKey{Expr: "o.id", IdExpr: "o.id"}
+}
+
+func GetOrders(cursorPage *CursorPage, sort string) ([]Order, int, error) {
+	key := orderSortKey(sort)
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
+    				CAST(`+key.Expr+` AS CHAR),
     				o.id, o.created_at, o.address, o.status, COALESCE(o.paypal_id, ''),
     				COALESCE(c.id, 0), COALESCE(c.first_name, ''), COALESCE(c.last_name, ''), COALESCE(c.email, '')
 				FROM orders o 
 				LEFT OUTER JOIN customers c ON o.customer_id = c.id
-				ORDER BY o.id LIMIT ? OFFSET ?;`,
-				pageSize, (page-1)*pageSize,
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
 			)
 		},
-		func(rows *sql.Rows) (Order, error) {
+		func(rows *sql.Rows) (Order, keysetCursor, error) {
 			order := Order{}
+			cursor := keysetCursor{}
 			err := rows.Scan(
+				&cursor.Value,
 				&order.Id, &order.CreatedAt, &order.Address, &order.Status, &order.PayPalId,
 				&order.Customer.Id, &order.Customer.FirstName, &order.Customer.LastName, &order.Customer.Email,
 			)
-			return order, err
+			cursor.Id = order.Id
+			return order, cursor, err
 		},
 		func() *sql.Row {
 			return database.QueryRow("SELECT COUNT(*) FROM `orders`;")
@@ -81,10 +117,16 @@ func CreateOrder(ctx context.Context, order *Order, tx *sql.Tx) error {
 		payPalId = sql.NullString{}
 	}
 
+	// Orders are created now unless the creation time is given, e.g. for imported or generated orders
+	var createdAt sql.NullTime
+	if !order.CreatedAt.IsZero() {
+		createdAt = sql.NullTime{Time: order.CreatedAt, Valid: true}
+	}
+
 	result, err := dbExec(
 		ctx,
-		"INSERT INTO orders (address, customer_id, status, paypal_id) VALUES (?, ?, ?, ?);",
-		order.Address, customerId, order.Status, payPalId,
+		"INSERT INTO orders (address, customer_id, status, paypal_id, created_at) VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP));",
+		order.Address, customerId, order.Status, payPalId, createdAt,
 	)
 	if err != nil {
 		return err
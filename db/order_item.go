@@ -9,6 +9,7 @@ type OrderItem struct {
 	Id           int64
 	OrderId      int64
 	Product      Product
+	Variant      ProductVariant
 	Quantity     int
 	PricePerItem float64
 }
@@ -21,9 +22,11 @@ func GetOrderItems(orderId int64) ([]OrderItem, int, error) {
 			return database.Query(
 				`SELECT 
     				i.id, i.order_id, i.quantity, i.price_per_item,
-    				p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, ''),
+    				COALESCE(v.id, 0), COALESCE(v.sku, ''), COALESCE(v.price, 0), COALESCE(v.quantity, 0), COALESCE(v.image_url, ''), `+variantNameQuery+`
 				FROM order_items i 
 				LEFT OUTER JOIN products p ON i.product_id = p.id
+				LEFT OUTER JOIN product_variants v ON i.variant_id = v.id
 				WHERE i.order_id = ?
 				ORDER BY i.id;`,
 				orderId,
@@ -34,6 +37,7 @@ func GetOrderItems(orderId int64) ([]OrderItem, int, error) {
 			err := rows.Scan(
 				&item.Id, &item.OrderId, &item.Quantity, &item.PricePerItem,
 				&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+				&item.Variant.Id, &item.Variant.Sku, &item.Variant.Price, &item.Variant.Quantity, &item.Variant.ImageUrl, &item.Variant.Name,
 			)
 			return item, err
 		},
@@ -49,15 +53,18 @@ func GetOrderItem(itemId, orderId int) (OrderItem, error) {
 	row := database.QueryRow(
 		`SELECT 
     		i.id, i.order_id, i.quantity, i.price_per_item,
-    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, ''),
+    		COALESCE(v.id, 0), COALESCE(v.sku, ''), COALESCE(v.price, 0), COALESCE(v.quantity, 0), COALESCE(v.image_url, ''), `+variantNameQuery+`
 		FROM order_items i
 		LEFT OUTER JOIN products p ON i.product_id = p.id
+		LEFT OUTER JOIN product_variants v ON i.variant_id = v.id
 		WHERE i.id = ? AND i.order_id = ?;`,
 		itemId, orderId,
 	)
 	err := row.Scan(
 		&item.Id, &item.OrderId, &item.Quantity, &item.PricePerItem,
 		&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+		&item.Variant.Id, &item.Variant.Sku, &item.Variant.Price, &item.Variant.Quantity, &item.Variant.ImageUrl, &item.Variant.Name,
 	)
 
 	return item, err
@@ -72,11 +79,18 @@ func CreateOrderItem(ctx context.Context, item OrderItem, tx *sql.Tx) error {
 		dbExec = tx.ExecContext
 	}
 
+	var variantId sql.NullInt64
+	if item.Variant.Id == 0 {
+		variantId = sql.NullInt64{}
+	} else {
+		variantId = sql.NullInt64{Int64: item.Variant.Id, Valid: true}
+	}
+
 	_, err := dbExec(
 		ctx,
-		`INSERT INTO order_items (order_id, product_id, quantity, price_per_item) 
-		VALUES (?, ?, ?, ?);`,
-		item.OrderId, item.Product.Id, item.Quantity, item.PricePerItem,
+		`INSERT INTO order_items (order_id, product_id, variant_id, quantity, price_per_item) 
+		VALUES (?, ?, ?, ?, ?);`,
+		item.OrderId, item.Product.Id, variantId, item.Quantity, item.PricePerItem,
 	)
 	return err
 }
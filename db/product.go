@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type Product struct {
@@ -15,30 +17,89 @@ type Product struct {
 	Quantity     int
 	ImageUrl     string
 	WarrantyDays int
+	Sku          string
+	Barcode      string
+	PrimaryImage string
+	Rating       float64
+	ReviewsCount int
 }
 
-func GetProducts(page, pageSize int) ([]Product, int, error) {
-	return getRowsAndCount(
-		page,
-		pageSize,
-		func(page, pageSize int) (*sql.Rows, error) {
+var DuplicateSkuOrBarcode = errors.New("product with same sku or barcode already exists")
+
+func convertProductSaveError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return DuplicateSkuOrBarcode
+	}
+	return err
+}
+
+const (
+	ProductSortDefault     = ""
+	ProductSortPriceAsc    = "price_asc"
+	ProductSortPriceDesc   = "price_desc"
+	ProductSortNewest      = "newest"
+	ProductSortBestSelling = "best_selling"
+	ProductSortName        = "name"
+	ProductSortRating      = "rating"
+)
+
+func productSortKey(sort string) SortKey {
+	switch sort {
+	case ProductSortPriceAsc:
+		return SortKey{Expr: "p.price", IdExpr: "p.id"}
+	case ProductSortPriceDesc:
+		return SortKey{Expr: "p.price", IdExpr: "p.id", Desc: true}
+	case ProductSortNewest:
+		return SortKey{Expr: "p.id", IdExpr: "p.id", Desc: true}
+	case ProductSortBestSelling:
+		return SortKey{Expr: "COALESCE(s.sold, 0)", IdExpr: "p.id", Desc: true}
+	case ProductSortName:
+		return SortKey{Expr: "p.model", IdExpr: "p.id"}
+	case ProductSortRating:
+		return SortKey{Expr: "p.rating", IdExpr: "p.id", Desc: true}
+	}
+
+	return SortKey{Expr: "p.id", IdExpr: "p.id"}
+}
+
+func productSortJoin(sort string) string {
+	if sort == ProductSortBestSelling {
+		return "LEFT OUTER JOIN (SELECT product_id, SUM(quantity) AS sold FROM order_items GROUP BY product_id) s ON s.product_id = p.id"
+	}
+	return ""
+}
+
+func GetProducts(cursorPage *CursorPage, sort string) ([]Product, int, error) {
+	key := productSortKey(sort)
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days,
+    				CAST(`+key.Expr+` AS CHAR),
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
     				COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
 				FROM products p 
 				LEFT OUTER JOIN categories c ON p.category_id = c.id
-				ORDER BY p.id LIMIT ? OFFSET ?;`,
-				pageSize, (page-1)*pageSize,
+				`+productSortJoin(sort)+`
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
 			)
 		},
-		func(rows *sql.Rows) (Product, error) {
+		func(rows *sql.Rows) (Product, keysetCursor, error) {
 			product := Product{}
+			cursor := keysetCursor{}
 			err := rows.Scan(
-				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays,
+				&cursor.Value,
+				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
 				&product.Category.Id, &product.Category.Name, &product.Category.Description,
 			)
-			return product, err
+			cursor.Id = product.Id
+			return product, cursor, err
 		},
 		func() *sql.Row {
 			return database.QueryRow("SELECT COUNT(*) FROM `products`;")
@@ -53,7 +114,7 @@ func SearchProducts(model string, limit int) ([]Product, error) {
 		func(page, pageSize int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days,
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
     				COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
 				FROM products p 
 				LEFT OUTER JOIN categories c ON p.category_id = c.id
@@ -65,7 +126,7 @@ func SearchProducts(model string, limit int) ([]Product, error) {
 		func(rows *sql.Rows) (Product, error) {
 			product := Product{}
 			err := rows.Scan(
-				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays,
+				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
 				&product.Category.Id, &product.Category.Name, &product.Category.Description,
 			)
 			return product, err
@@ -77,40 +138,56 @@ func SearchProducts(model string, limit int) ([]Product, error) {
 	return products, err
 }
 
-func SearchProductsCatalog(page, pageSize int, category Category, query string) ([]Product, int, error) {
-	return getRowsAndCount(
-		page,
-		pageSize,
-		func(page, pageSize int) (*sql.Rows, error) {
+func SearchProductsCatalog(cursorPage *CursorPage, filter CatalogFilter) ([]Product, int, error) {
+	where, args := filter.where("", 0)
+	key := filter.sortKey()
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days,
+    				CAST(`+key.Expr+` AS CHAR),
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    				COALESCE((SELECT i.file_name FROM product_images i WHERE i.product_id = p.id ORDER BY i.is_primary DESC, i.position LIMIT 1), ''),
+    				p.rating, p.reviews_count,
     				COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
 				FROM products p 
 				LEFT OUTER JOIN categories c ON p.category_id = c.id
-				WHERE (LOWER(p.model) LIKE CONCAT(?, '%') OR LOWER(p.manufacturer) LIKE CONCAT(?, '%')) AND (? = 0 OR p.category_id = ?)
-				ORDER BY p.id LIMIT ? OFFSET ?;`,
-				query, query, category.Id, category.Id, pageSize, (page-1)*pageSize,
+				`+productSortJoin(filter.Sort)+`
+				WHERE `+where+` AND `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(append(append([]any{}, args...), keysetArgs...), limit)...,
 			)
 		},
-		func(rows *sql.Rows) (Product, error) {
+		func(rows *sql.Rows) (Product, keysetCursor, error) {
 			product := Product{}
+			cursor := keysetCursor{}
 			err := rows.Scan(
-				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays,
+				&cursor.Value,
+				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode, &product.PrimaryImage,
+				&product.Rating, &product.ReviewsCount,
 				&product.Category.Id, &product.Category.Name, &product.Category.Description,
 			)
-			return product, err
+			cursor.Id = product.Id
+			return product, cursor, err
 		},
 		func() *sql.Row {
-			return database.QueryRow(
-				"SELECT COUNT(*) FROM products WHERE (LOWER(model) LIKE CONCAT(?, '%') OR LOWER(manufacturer) LIKE CONCAT(?, '%')) AND (? = 0 OR category_id = ?);",
-				query, query, category.Id, category.Id,
-			)
+			return database.QueryRow("SELECT COUNT(*) FROM products p WHERE "+where+";", args...)
 		},
 	)
 }
 
-func CreateProduct(product Product) error {
+func CreateProduct(ctx context.Context, product *Product, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
 	var imageUrl sql.NullString
 	if product.ImageUrl == "" {
 		imageUrl = sql.NullString{}
@@ -125,12 +202,38 @@ func CreateProduct(product Product) error {
 		categoryId = sql.NullInt64{Int64: product.Category.Id, Valid: true}
 	}
 
-	_, err := database.Exec(
-		`INSERT INTO products (model, manufacturer, price, quantity, image_url, warranty_days, category_id) 
-		VALUES (?, ?, ?, ?, ?, ?, ?);`,
+	var sku sql.NullString
+	if product.Sku == "" {
+		sku = sql.NullString{}
+	} else {
+		sku = sql.NullString{String: product.Sku, Valid: true}
+	}
+
+	var barcode sql.NullString
+	if product.Barcode == "" {
+		barcode = sql.NullString{}
+	} else {
+		barcode = sql.NullString{String: product.Barcode, Valid: true}
+	}
+
+	result, err := dbExec(
+		ctx,
+		`INSERT INTO products (model, manufacturer, price, quantity, image_url, warranty_days, category_id, sku, barcode) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
 		product.Model, product.Manufacturer, product.Price, product.Quantity, imageUrl, product.WarrantyDays, categoryId,
+		sku, barcode,
 	)
-	return err
+	if err != nil {
+		return convertProductSaveError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	product.Id = id
+	return nil
 }
 
 func GetProduct(productId int64) (Product, error) {
@@ -138,7 +241,8 @@ func GetProduct(productId int64) (Product, error) {
 
 	row := database.QueryRow(
 		`SELECT 
-    		p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days,
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    		p.rating, p.reviews_count,
     		COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
 		FROM products p 
 		LEFT OUTER JOIN categories c ON p.category_id = c.id
@@ -146,7 +250,8 @@ func GetProduct(productId int64) (Product, error) {
 		productId,
 	)
 	err := row.Scan(
-		&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays,
+		&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
+		&product.Rating, &product.ReviewsCount,
 		&product.Category.Id, &product.Category.Name, &product.Category.Description,
 	)
 
@@ -177,17 +282,223 @@ func (product *Product) DbSave(ctx context.Context, tx *sql.Tx) error {
 			categoryId = sql.NullInt64{Int64: product.Category.Id, Valid: true}
 		}
 
+		var sku sql.NullString
+		if product.Sku == "" {
+			sku = sql.NullString{}
+		} else {
+			sku = sql.NullString{String: product.Sku, Valid: true}
+		}
+
+		var barcode sql.NullString
+		if product.Barcode == "" {
+			barcode = sql.NullString{}
+		} else {
+			barcode = sql.NullString{String: product.Barcode, Valid: true}
+		}
+
 		_, err := dbExec(
 			ctx,
 			`UPDATE products 
-			SET model=?, manufacturer=?, price=?, quantity=?, image_url=?, warranty_days=?, category_id=?
+			SET model=?, manufacturer=?, price=?, quantity=?, image_url=?, warranty_days=?, category_id=?, sku=?, barcode=?
 			WHERE id=?;`,
-			product.Model, product.Manufacturer, product.Price, product.Quantity, imageUrl, product.WarrantyDays, categoryId, product.Id,
+			product.Model, product.Manufacturer, product.Price, product.Quantity, imageUrl, product.WarrantyDays, categoryId,
+			sku, barcode, product.Id,
 		)
-		return err
+		return convertProductSaveError(err)
+	}
+
+	return CreateProduct(ctx, product, tx)
+}
+
+func GetProductBySkuOrBarcode(code string) (Product, error) {
+	var product Product
+
+	alternativeCode := code
+	if len(code) == 12 {
+		alternativeCode = "0" + code
+	} else if len(code) == 13 && code[0] == '0' {
+		alternativeCode = code[1:]
+	}
+
+	row := database.QueryRow(
+		`SELECT 
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    		COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
+		FROM products p 
+		LEFT OUTER JOIN categories c ON p.category_id = c.id
+		WHERE p.sku = ? OR p.barcode = ? OR p.barcode = ?
+		LIMIT 1;`,
+		code, code, alternativeCode,
+	)
+	err := row.Scan(
+		&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
+		&product.Category.Id, &product.Category.Name, &product.Category.Description,
+	)
+
+	return product, err
+}
+
+func GetAllProducts() ([]Product, error) {
+	products, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    				COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
+				FROM products p 
+				LEFT OUTER JOIN categories c ON p.category_id = c.id
+				ORDER BY p.id;`,
+			)
+		},
+		func(rows *sql.Rows) (Product, error) {
+			product := Product{}
+			err := rows.Scan(
+				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
+				&product.Category.Id, &product.Category.Name, &product.Category.Description,
+			)
+			return product, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return products, err
+}
+
+func GetProductsByIds(productIds []int64) ([]Product, error) {
+	if len(productIds) == 0 {
+		return nil, nil
 	}
 
-	return CreateProduct(*product)
+	args := make([]any, len(productIds))
+	for i, id := range productIds {
+		args[i] = id
+	}
+
+	products, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    				p.rating, p.reviews_count,
+    				COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
+				FROM products p 
+				LEFT OUTER JOIN categories c ON p.category_id = c.id
+				WHERE p.id IN (`+sqlPlaceholders(len(args))+`)
+				ORDER BY p.id;`,
+				args...,
+			)
+		},
+		func(rows *sql.Rows) (Product, error) {
+			product := Product{}
+			err := rows.Scan(
+				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
+				&product.Rating, &product.ReviewsCount,
+				&product.Category.Id, &product.Category.Name, &product.Category.Description,
+			)
+			return product, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return products, err
+}
+
+func GetProductsBySkuOrModel(ctx context.Context, sku, model string, tx *sql.Tx) ([]Product, error) {
+	var dbQuery func(context.Context, string, ...any) (*sql.Rows, error)
+
+	if tx == nil {
+		dbQuery = database.QueryContext
+	} else {
+		dbQuery = tx.QueryContext
+	}
+
+	products, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			if sku != "" {
+				return dbQuery(
+					ctx,
+					`SELECT 
+    					p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    					COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
+					FROM products p 
+					LEFT OUTER JOIN categories c ON p.category_id = c.id
+					WHERE p.sku = ?
+					ORDER BY p.id;`,
+					sku,
+				)
+			}
+
+			return dbQuery(
+				ctx,
+				`SELECT 
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    				COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')
+				FROM products p 
+				LEFT OUTER JOIN categories c ON p.category_id = c.id
+				WHERE LOWER(p.model) = LOWER(?)
+				ORDER BY p.id;`,
+				model,
+			)
+		},
+		func(rows *sql.Rows) (Product, error) {
+			product := Product{}
+			err := rows.Scan(
+				&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode,
+				&product.Category.Id, &product.Category.Name, &product.Category.Description,
+			)
+			return product, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return products, err
+}
+
+type ProductSearchText struct {
+	ProductId       int64
+	Model           string
+	Manufacturer    string
+	Sku             string
+	Category        string
+	Characteristics string
+}
+
+func GetProductSearchTexts(productId int64) ([]ProductSearchText, error) {
+	texts, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				p.id, p.model, p.manufacturer, COALESCE(p.sku, ''), COALESCE(c.name, ''),
+    				COALESCE(GROUP_CONCAT(pc.value SEPARATOR ' '), '')
+				FROM products p 
+				LEFT OUTER JOIN categories c ON p.category_id = c.id
+				LEFT OUTER JOIN product_characteristics pc ON pc.product_id = p.id
+				WHERE ? = 0 OR p.id = ?
+				GROUP BY p.id, p.model, p.manufacturer, p.sku, c.name;`,
+				productId, productId,
+			)
+		},
+		func(rows *sql.Rows) (ProductSearchText, error) {
+			text := ProductSearchText{}
+			err := rows.Scan(&text.ProductId, &text.Model, &text.Manufacturer, &text.Sku, &text.Category, &text.Characteristics)
+			return text, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return texts, err
 }
 
 var NotEnoughQuantity = errors.New("specified product does not have enough quantity to add it to order")
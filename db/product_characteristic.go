@@ -1,12 +1,17 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
 
 type ProductCharacteristic struct {
 	Id             int64
 	ProductId      int64
 	Characteristic Characteristic
 	Value          string
+	Required       bool
 }
 
 func GetProductCharacteristics(productId int64) ([]ProductCharacteristic, int, error) {
@@ -16,12 +21,15 @@ func GetProductCharacteristics(productId int64) ([]ProductCharacteristic, int, e
 		func(page, pageSize int) (*sql.Rows, error) {
 			return database.Query(
 				`SELECT 
-    				p.id, p.product_id, p.value,
-    				c.id, c.name, COALESCE(c.measurement_unit, '')
+    				p.id, p.product_id, COALESCE(p.value, ''),
+    				c.id, c.name, COALESCE(c.measurement_unit, ''), c.value_type,
+    				COALESCE(cc.required, FALSE)
 				FROM product_characteristics p 
 				LEFT OUTER JOIN characteristics c ON p.characteristic_id = c.id
+				LEFT OUTER JOIN products pr ON p.product_id = pr.id
+				LEFT OUTER JOIN category_characteristics cc ON cc.category_id = pr.category_id AND cc.characteristic_id = p.characteristic_id
 				WHERE p.product_id = ?
-				ORDER BY p.id;`,
+				ORDER BY cc.display_order IS NULL, cc.display_order, p.id;`,
 				productId,
 			)
 		},
@@ -29,7 +37,8 @@ func GetProductCharacteristics(productId int64) ([]ProductCharacteristic, int, e
 			char := ProductCharacteristic{}
 			err := rows.Scan(
 				&char.Id, &char.ProductId, &char.Value,
-				&char.Characteristic.Id, &char.Characteristic.Name, &char.Characteristic.Unit,
+				&char.Characteristic.Id, &char.Characteristic.Name, &char.Characteristic.Unit, &char.Characteristic.ValueType,
+				&char.Required,
 			)
 			return char, err
 		},
@@ -39,6 +48,35 @@ func GetProductCharacteristics(productId int64) ([]ProductCharacteristic, int, e
 	)
 }
 
+func GetAllProductCharacteristics() ([]ProductCharacteristic, error) {
+	chars, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				p.id, p.product_id, p.value,
+    				c.id, c.name, COALESCE(c.measurement_unit, '')
+				FROM product_characteristics p 
+				INNER JOIN characteristics c ON p.characteristic_id = c.id
+				ORDER BY p.product_id, p.id;`,
+			)
+		},
+		func(rows *sql.Rows) (ProductCharacteristic, error) {
+			char := ProductCharacteristic{}
+			err := rows.Scan(
+				&char.Id, &char.ProductId, &char.Value,
+				&char.Characteristic.Id, &char.Characteristic.Name, &char.Characteristic.Unit,
+			)
+			return char, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return chars, err
+}
+
 func GetProductCharacteristic(characteristicId, productId int) (ProductCharacteristic, error) {
 	var char ProductCharacteristic
 
@@ -61,9 +99,9 @@ func GetProductCharacteristic(characteristicId, productId int) (ProductCharacter
 
 func CreateProductCharacteristic(char ProductCharacteristic) error {
 	_, err := database.Exec(
-		`INSERT INTO product_characteristics (product_id, characteristic_id, value) 
-		VALUES (?, ?, ?);`,
-		char.ProductId, char.Characteristic.Id, char.Value,
+		`INSERT INTO product_characteristics (product_id, characteristic_id, value, value_number) 
+		VALUES (?, ?, ?, ?);`,
+		char.ProductId, char.Characteristic.Id, char.Value, char.Characteristic.numberValue(char.Value),
 	)
 	return err
 }
@@ -72,9 +110,9 @@ func (char *ProductCharacteristic) DbSave() error {
 	if char.Id > 0 {
 		_, err := database.Exec(
 			`UPDATE product_characteristics 
-			SET value=?
+			SET value=?, value_number=?
 			WHERE id=?;`,
-			char.Value, char.Id,
+			char.Value, char.Characteristic.numberValue(char.Value), char.Id,
 		)
 		return err
 	}
@@ -86,3 +124,41 @@ func (char *ProductCharacteristic) DbDelete() error {
 	_, err := database.Exec("DELETE FROM `product_characteristics` WHERE `id`=?;", char.Id)
 	return err
 }
+
+func SetProductCharacteristicValue(ctx context.Context, char ProductCharacteristic, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+	var dbQueryRow func(context.Context, string, ...any) *sql.Row
+
+	if tx == nil {
+		dbExec = database.ExecContext
+		dbQueryRow = database.QueryRowContext
+	} else {
+		dbExec = tx.ExecContext
+		dbQueryRow = tx.QueryRowContext
+	}
+
+	var existingId int64
+	err := dbQueryRow(
+		ctx,
+		"SELECT id FROM product_characteristics WHERE product_id=? AND characteristic_id=? ORDER BY id LIMIT 1;",
+		char.ProductId, char.Characteristic.Id,
+	).Scan(&existingId)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = dbExec(
+			ctx,
+			"INSERT INTO product_characteristics (product_id, characteristic_id, value, value_number) VALUES (?, ?, ?, ?);",
+			char.ProductId, char.Characteristic.Id, char.Value, char.Characteristic.numberValue(char.Value),
+		)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = dbExec(
+		ctx,
+		"UPDATE product_characteristics SET value=?, value_number=? WHERE id=?;",
+		char.Value, char.Characteristic.numberValue(char.Value), existingId,
+	)
+	return err
+}
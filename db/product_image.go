@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type ProductImage struct {
+	Id        int64
+	ProductId int64
+	FileName  string
+	Position  int
+	IsPrimary bool
+}
+
+func GetProductImages(productId int64) ([]ProductImage, int, error) {
+	return getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT i.id, i.product_id, i.file_name, i.position, i.is_primary
+				FROM product_images i
+				WHERE i.product_id = ?
+				ORDER BY i.is_primary DESC, i.position, i.id;`,
+				productId,
+			)
+		},
+		func(rows *sql.Rows) (ProductImage, error) {
+			image := ProductImage{}
+			err := rows.Scan(&image.Id, &image.ProductId, &image.FileName, &image.Position, &image.IsPrimary)
+			return image, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `product_images` WHERE product_id=?;", productId)
+		},
+	)
+}
+
+func GetProductImage(imageId, productId int64) (ProductImage, error) {
+	var image ProductImage
+
+	row := database.QueryRow(
+		`SELECT i.id, i.product_id, i.file_name, i.position, i.is_primary
+		FROM product_images i
+		WHERE i.id = ? AND i.product_id = ?;`,
+		imageId, productId,
+	)
+	err := row.Scan(&image.Id, &image.ProductId, &image.FileName, &image.Position, &image.IsPrimary)
+
+	return image, err
+}
+
+func CreateProductImage(image *ProductImage) error {
+	result, err := database.Exec(
+		`INSERT INTO product_images (product_id, file_name, position, is_primary)
+		SELECT ?, ?, COALESCE(MAX(position) + 1, 0), COUNT(*) = 0 FROM product_images WHERE product_id = ?;`,
+		image.ProductId, image.FileName, image.ProductId,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	image.Id = id
+	return nil
+}
+
+func (image *ProductImage) SetPrimary(ctx context.Context) error {
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_images SET is_primary = (id = ?) WHERE product_id = ?;", image.Id, image.ProductId)
+	if err != nil {
+		return err
+	}
+
+	image.IsPrimary = true
+	return tx.Commit()
+}
+
+// Move swaps image position with previous (direction < 0) or next (direction > 0) image of the same product
+func (image *ProductImage) Move(ctx context.Context, direction int) error {
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT id, position FROM product_images WHERE product_id = ? AND position > ? ORDER BY position LIMIT 1;`
+	if direction < 0 {
+		query = `SELECT id, position FROM product_images WHERE product_id = ? AND position < ? ORDER BY position DESC LIMIT 1;`
+	}
+
+	var otherId int64
+	var otherPosition int
+	err = tx.QueryRowContext(ctx, query, image.ProductId, image.Position).Scan(&otherId, &otherPosition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE product_images SET position=? WHERE id=?;", image.Position, otherId); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE product_images SET position=? WHERE id=?;", otherPosition, image.Id); err != nil {
+		return err
+	}
+
+	image.Position = otherPosition
+	return tx.Commit()
+}
+
+func (image *ProductImage) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `product_images` WHERE `id`=?;", image.Id)
+	if err != nil {
+		return err
+	}
+
+	if image.IsPrimary {
+		_, err = database.Exec(
+			"UPDATE product_images SET is_primary = 1 WHERE product_id = ? ORDER BY position LIMIT 1;",
+			image.ProductId,
+		)
+	}
+
+	return err
+}
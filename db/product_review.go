@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+var ReviewStatuses = []string{ReviewPending, ReviewApproved, ReviewRejected}
+
+type ProductReview struct {
+	Id           int64
+	ProductId    int64
+	ProductModel string
+	Customer     Customer
+	OrderId      int64
+	Rating       int
+	Title        string
+	Text         string
+	Status       string
+	CreatedAt    time.Time
+}
+
+var NotVerifiedPurchase = errors.New("customer has no completed order with this product")
+var DuplicateReview = errors.New("customer already reviewed this product")
+
+func GetProductReviews(productId int64, status string) ([]ProductReview, error) {
+	reviews, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT
+    				r.id, r.product_id, p.model, r.order_id, r.rating, r.title, COALESCE(r.text, ''), r.status, r.created_at,
+    				c.id, c.first_name, c.last_name, c.email
+				FROM product_reviews r
+				INNER JOIN products p ON r.product_id = p.id
+				INNER JOIN customers c ON r.customer_id = c.id
+				WHERE r.product_id = ? AND r.status = ?
+				ORDER BY r.created_at DESC, r.id DESC;`,
+				productId, status,
+			)
+		},
+		scanProductReview,
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return reviews, err
+}
+
+func GetReviewsByStatus(cursorPage *CursorPage, status string) ([]ProductReview, int, error) {
+	key := SortKey{Expr: "r.created_at", IdExpr: "r.id"}
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT
+    				CAST(`+key.Expr+` AS CHAR),
+    				r.id, r.product_id, p.model, r.order_id, r.rating, r.title, COALESCE(r.text, ''), r.status, r.created_at,
+    				c.id, c.first_name, c.last_name, c.email
+				FROM product_reviews r
+				INNER JOIN products p ON r.product_id = p.id
+				INNER JOIN customers c ON r.customer_id = c.id
+				WHERE r.status = ? AND `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(append([]any{status}, keysetArgs...), limit)...,
+			)
+		},
+		func(rows *sql.Rows) (ProductReview, keysetCursor, error) {
+			review := ProductReview{}
+			cursor := keysetCursor{}
+			err := rows.Scan(
+				&cursor.Value,
+				&review.Id, &review.ProductId, &review.ProductModel, &review.OrderId, &review.Rating, &review.Title, &review.Text, &review.Status, &review.CreatedAt,
+				&review.Customer.Id, &review.Customer.FirstName, &review.Customer.LastName, &review.Customer.Email,
+			)
+			cursor.Id = review.Id
+			return review, cursor, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `product_reviews` WHERE status=?;", status)
+		},
+	)
+}
+
+func scanProductReview(rows *sql.Rows) (ProductReview, error) {
+	review := ProductReview{}
+	err := rows.Scan(
+		&review.Id, &review.ProductId, &review.ProductModel, &review.OrderId, &review.Rating, &review.Title, &review.Text, &review.Status, &review.CreatedAt,
+		&review.Customer.Id, &review.Customer.FirstName, &review.Customer.LastName, &review.Customer.Email,
+	)
+	return review, err
+}
+
+func GetProductReview(reviewId int64) (ProductReview, error) {
+	var review ProductReview
+
+	row := database.QueryRow(
+		`SELECT
+    		r.id, r.product_id, p.model, r.order_id, r.rating, r.title, COALESCE(r.text, ''), r.status, r.created_at,
+    		c.id, c.first_name, c.last_name, c.email
+		FROM product_reviews r
+		INNER JOIN products p ON r.product_id = p.id
+		INNER JOIN customers c ON r.customer_id = c.id
+		WHERE r.id = ?;`,
+		reviewId,
+	)
+	err := row.Scan(
+		&review.Id, &review.ProductId, &review.ProductModel, &review.OrderId, &review.Rating, &review.Title, &review.Text, &review.Status, &review.CreatedAt,
+		&review.Customer.Id, &review.Customer.FirstName, &review.Customer.LastName, &review.Customer.Email,
+	)
+
+	return review, err
+}
+
+func CreateProductReview(review *ProductReview) error {
+	err := database.QueryRow(
+		`SELECT c.id, c.first_name, c.last_name, c.email
+		FROM orders o
+		INNER JOIN customers c ON o.customer_id = c.id
+		WHERE o.id = ? AND LOWER(c.email) = LOWER(?) AND o.status = 'complete'
+			AND EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = o.id AND oi.product_id = ?);`,
+		review.OrderId, review.Customer.Email, review.ProductId,
+	).Scan(&review.Customer.Id, &review.Customer.FirstName, &review.Customer.LastName, &review.Customer.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotVerifiedPurchase
+	}
+	if err != nil {
+		return err
+	}
+
+	var text sql.NullString
+	if review.Text == "" {
+		text = sql.NullString{}
+	} else {
+		text = sql.NullString{String: review.Text, Valid: true}
+	}
+
+	review.Status = ReviewPending
+	result, err := database.Exec(
+		`INSERT INTO product_reviews (product_id, customer_id, order_id, rating, title, text, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		review.ProductId, review.Customer.Id, review.OrderId, review.Rating, review.Title, text, review.Status,
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return DuplicateReview
+	}
+	if err != nil {
+		return err
+	}
+
+	review.Id, err = result.LastInsertId()
+	return err
+}
+
+func updateProductRating(ctx context.Context, productId int64, tx *sql.Tx) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`UPDATE products p
+		SET p.rating = COALESCE((SELECT AVG(r.rating) FROM product_reviews r WHERE r.product_id = p.id AND r.status = 'approved'), 0),
+			p.reviews_count = (SELECT COUNT(*) FROM product_reviews r WHERE r.product_id = p.id AND r.status = 'approved')
+		WHERE p.id = ?;`,
+		productId,
+	)
+	return err
+}
+
+func (review *ProductReview) SetStatus(ctx context.Context, status string) error {
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE product_reviews SET status=? WHERE id=?;", status, review.Id)
+	if err != nil {
+		return err
+	}
+
+	if err = updateProductRating(ctx, review.ProductId, tx); err != nil {
+		return err
+	}
+
+	review.Status = status
+	return tx.Commit()
+}
+
+func (review *ProductReview) DbDelete(ctx context.Context) error {
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM `product_reviews` WHERE `id`=?;", review.Id)
+	if err != nil {
+		return err
+	}
+
+	if err = updateProductRating(ctx, review.ProductId, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+type ProductVariant struct {
+	Id        int64
+	ProductId int64
+	Sku       string
+	Price     float64
+	Quantity  int
+	ImageUrl  string
+
+	// Name is built from variant values, e.g. "XL / Red"
+	Name string
+}
+
+type ProductVariantValue struct {
+	Id             int64
+	VariantId      int64
+	Characteristic Characteristic
+	Value          string
+}
+
+// EffectivePrice returns variant price override or product price if variant does not override it
+func (variant ProductVariant) EffectivePrice(product Product) float64 {
+	if variant.Id > 0 && variant.Price > 0 {
+		return variant.Price
+	}
+	return product.Price
+}
+
+func (variant ProductVariant) Label() string {
+	if variant.Name != "" {
+		return variant.Name
+	}
+	return variant.Sku
+}
+
+const variantNameQuery = `COALESCE((
+	SELECT GROUP_CONCAT(vv.value ORDER BY vv.characteristic_id SEPARATOR ' / ')
+	FROM product_variant_values vv
+	WHERE vv.variant_id = v.id
+), '')`
+
+func GetProductVariants(productId int64) ([]ProductVariant, int, error) {
+	return getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				v.id, v.product_id, v.sku, COALESCE(v.price, 0), v.quantity, COALESCE(v.image_url, ''), `+variantNameQuery+`
+				FROM product_variants v
+				WHERE v.product_id = ?
+				ORDER BY v.id;`,
+				productId,
+			)
+		},
+		func(rows *sql.Rows) (ProductVariant, error) {
+			variant := ProductVariant{}
+			err := rows.Scan(
+				&variant.Id, &variant.ProductId, &variant.Sku, &variant.Price, &variant.Quantity, &variant.ImageUrl, &variant.Name,
+			)
+			return variant, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `product_variants` WHERE product_id=?;", productId)
+		},
+	)
+}
+
+func GetProductVariant(variantId, productId int64) (ProductVariant, error) {
+	var variant ProductVariant
+
+	row := database.QueryRow(
+		`SELECT 
+    		v.id, v.product_id, v.sku, COALESCE(v.price, 0), v.quantity, COALESCE(v.image_url, ''), `+variantNameQuery+`
+		FROM product_variants v
+		WHERE v.id = ? AND v.product_id = ?;`,
+		variantId, productId,
+	)
+	err := row.Scan(
+		&variant.Id, &variant.ProductId, &variant.Sku, &variant.Price, &variant.Quantity, &variant.ImageUrl, &variant.Name,
+	)
+
+	return variant, err
+}
+
+func CreateProductVariant(variant ProductVariant) error {
+	var price sql.NullFloat64
+	if variant.Price > 0 {
+		price = sql.NullFloat64{Float64: variant.Price, Valid: true}
+	}
+
+	var imageUrl sql.NullString
+	if variant.ImageUrl != "" {
+		imageUrl = sql.NullString{String: variant.ImageUrl, Valid: true}
+	}
+
+	_, err := database.Exec(
+		`INSERT INTO product_variants (product_id, sku, price, quantity, image_url) 
+		VALUES (?, ?, ?, ?, ?);`,
+		variant.ProductId, variant.Sku, price, variant.Quantity, imageUrl,
+	)
+	return err
+}
+
+func (variant *ProductVariant) DbSave(ctx context.Context, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
+	if variant.Id > 0 {
+		var price sql.NullFloat64
+		if variant.Price > 0 {
+			price = sql.NullFloat64{Float64: variant.Price, Valid: true}
+		}
+
+		var imageUrl sql.NullString
+		if variant.ImageUrl != "" {
+			imageUrl = sql.NullString{String: variant.ImageUrl, Valid: true}
+		}
+
+		_, err := dbExec(
+			ctx,
+			`UPDATE product_variants SET sku=?, price=?, quantity=?, image_url=? WHERE id=?;`,
+			variant.Sku, price, variant.Quantity, imageUrl, variant.Id,
+		)
+		return err
+	}
+
+	return CreateProductVariant(*variant)
+}
+
+func (variant *ProductVariant) SubtractQuantity(ctx context.Context, quantity int, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+	var dbQueryRow func(context.Context, string, ...any) *sql.Row
+
+	if tx == nil {
+		dbExec = database.ExecContext
+		dbQueryRow = database.QueryRowContext
+	} else {
+		dbExec = tx.ExecContext
+		dbQueryRow = tx.QueryRowContext
+	}
+
+	var enough bool
+	if err := dbQueryRow(ctx, "SELECT (quantity >= ?) FROM product_variants WHERE id=?", quantity, variant.Id).Scan(&enough); err != nil {
+		return err
+	}
+
+	if !enough {
+		return NotEnoughQuantity
+	}
+
+	_, err := dbExec(ctx, "UPDATE product_variants SET quantity = (quantity - ?) WHERE id=?;", quantity, variant.Id)
+	return err
+}
+
+func (variant *ProductVariant) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `product_variants` WHERE `id`=?;", variant.Id)
+	return err
+}
+
+func GetProductVariantValues(variantId int64) ([]ProductVariantValue, int, error) {
+	return getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				vv.id, vv.variant_id, vv.value,
+    				c.id, c.name, COALESCE(c.measurement_unit, '')
+				FROM product_variant_values vv
+				LEFT OUTER JOIN characteristics c ON vv.characteristic_id = c.id
+				WHERE vv.variant_id = ?
+				ORDER BY vv.characteristic_id;`,
+				variantId,
+			)
+		},
+		func(rows *sql.Rows) (ProductVariantValue, error) {
+			value := ProductVariantValue{}
+			err := rows.Scan(
+				&value.Id, &value.VariantId, &value.Value,
+				&value.Characteristic.Id, &value.Characteristic.Name, &value.Characteristic.Unit,
+			)
+			return value, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `product_variant_values` WHERE variant_id=?;", variantId)
+		},
+	)
+}
+
+// GetProductVariantAxes returns characteristics that product variants differ by
+func GetProductVariantAxes(productId int64) ([]Characteristic, error) {
+	axes, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT DISTINCT c.id, c.name, COALESCE(c.measurement_unit, '')
+				FROM product_variant_values vv
+					JOIN product_variants v ON v.id = vv.variant_id
+					JOIN characteristics c ON c.id = vv.characteristic_id
+				WHERE v.product_id = ?
+				ORDER BY c.id;`,
+				productId,
+			)
+		},
+		func(rows *sql.Rows) (Characteristic, error) {
+			characteristic := Characteristic{}
+			err := rows.Scan(&characteristic.Id, &characteristic.Name, &characteristic.Unit)
+			return characteristic, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+
+	return axes, err
+}
+
+func (value *ProductVariantValue) DbSave() error {
+	_, err := database.Exec(
+		`INSERT INTO product_variant_values (variant_id, characteristic_id, value) 
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE value=?;`,
+		value.VariantId, value.Characteristic.Id, value.Value, value.Value,
+	)
+	return err
+}
+
+func GetProductVariantValue(valueId, variantId int64) (ProductVariantValue, error) {
+	var value ProductVariantValue
+
+	row := database.QueryRow(
+		`SELECT 
+    		vv.id, vv.variant_id, vv.value,
+    		c.id, c.name, COALESCE(c.measurement_unit, '')
+		FROM product_variant_values vv
+		LEFT OUTER JOIN characteristics c ON vv.characteristic_id = c.id
+		WHERE vv.id = ? AND vv.variant_id = ?;`,
+		valueId, variantId,
+	)
+	err := row.Scan(
+		&value.Id, &value.VariantId, &value.Value,
+		&value.Characteristic.Id, &value.Characteristic.Name, &value.Characteristic.Unit,
+	)
+
+	return value, err
+}
+
+func (value *ProductVariantValue) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `product_variant_values` WHERE `id`=?;", value.Id)
+	return err
+}
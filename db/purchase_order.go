@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	PurchaseOrderDraft             = "draft"
+	PurchaseOrderSent              = "sent"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+)
+
+type PurchaseOrder struct {
+	Id        int64
+	Supplier  Supplier
+	CreatedAt time.Time
+	Status    string
+}
+
+func GetPurchaseOrders(cursorPage *CursorPage) ([]PurchaseOrder, int, error) {
+	key := SortKey{Expr: "o.id", IdExpr: "o.id"}
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				CAST(`+key.Expr+` AS CHAR),
+    				o.id, o.created_at, o.status,
+    				COALESCE(s.id, 0), COALESCE(s.name, ''), COALESCE(s.email, ''), COALESCE(s.phone, '')
+				FROM purchase_orders o 
+				LEFT OUTER JOIN suppliers s ON o.supplier_id = s.id
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
+			)
+		},
+		func(rows *sql.Rows) (PurchaseOrder, keysetCursor, error) {
+			order := PurchaseOrder{}
+			cursor := keysetCursor{}
+			err := rows.Scan(
+				&cursor.Value, &order.Id, &order.CreatedAt, &order.Status,
+				&order.Supplier.Id, &order.Supplier.Name, &order.Supplier.Email, &order.Supplier.Phone,
+			)
+			cursor.Id = order.Id
+			return order, cursor, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `purchase_orders`;")
+		},
+	)
+}
+
+func CreatePurchaseOrder(ctx context.Context, order *PurchaseOrder, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
+	if order.Status == "" {
+		order.Status = PurchaseOrderDraft
+	}
+
+	result, err := dbExec(
+		ctx,
+		"INSERT INTO purchase_orders (supplier_id, status) VALUES (?, ?);",
+		order.Supplier.Id, order.Status,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	order.Id = id
+	return nil
+}
+
+func GetPurchaseOrder(orderId int64) (PurchaseOrder, error) {
+	var order PurchaseOrder
+
+	row := database.QueryRow(
+		`SELECT 
+    		o.id, o.created_at, o.status,
+    		COALESCE(s.id, 0), COALESCE(s.name, ''), COALESCE(s.email, ''), COALESCE(s.phone, '')
+		FROM purchase_orders o 
+		LEFT OUTER JOIN suppliers s ON o.supplier_id = s.id
+		WHERE o.id = ?;`,
+		orderId,
+	)
+	err := row.Scan(
+		&order.Id, &order.CreatedAt, &order.Status,
+		&order.Supplier.Id, &order.Supplier.Name, &order.Supplier.Email, &order.Supplier.Phone,
+	)
+
+	return order, err
+}
+
+func (order *PurchaseOrder) DbSave(ctx context.Context, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
+	if order.Id > 0 {
+		_, err := dbExec(
+			ctx,
+			`UPDATE purchase_orders SET supplier_id=?, status=? WHERE id=?;`,
+			order.Supplier.Id, order.Status, order.Id,
+		)
+		return err
+	}
+
+	return CreatePurchaseOrder(ctx, order, tx)
+}
+
+// UpdateReceivedStatus sets order status to "received" if every line is fully received, "partially_received" otherwise
+func (order *PurchaseOrder) UpdateReceivedStatus(ctx context.Context, tx *sql.Tx) error {
+	var dbQueryRow func(context.Context, string, ...any) *sql.Row
+
+	if tx == nil {
+		dbQueryRow = database.QueryRowContext
+	} else {
+		dbQueryRow = tx.QueryRowContext
+	}
+
+	var pending int
+	err := dbQueryRow(
+		ctx,
+		"SELECT COUNT(*) FROM purchase_order_items WHERE purchase_order_id=? AND quantity_received < quantity;",
+		order.Id,
+	).Scan(&pending)
+	if err != nil {
+		return err
+	}
+
+	if pending == 0 {
+		order.Status = PurchaseOrderReceived
+	} else {
+		order.Status = PurchaseOrderPartiallyReceived
+	}
+
+	return order.DbSave(ctx, tx)
+}
+
+func (order *PurchaseOrder) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `purchase_orders` WHERE `id`=?;", order.Id)
+	return err
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type PurchaseOrderItem struct {
+	Id               int64
+	PurchaseOrderId  int64
+	Product          Product
+	Quantity         int
+	QuantityReceived int
+	CostPerItem      float64
+}
+
+func (item PurchaseOrderItem) QuantityPending() int {
+	return item.Quantity - item.QuantityReceived
+}
+
+func GetPurchaseOrderItems(orderId int64) ([]PurchaseOrderItem, int, error) {
+	return getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				i.id, i.purchase_order_id, i.quantity, i.quantity_received, i.cost_per_item,
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+				FROM purchase_order_items i 
+				LEFT OUTER JOIN products p ON i.product_id = p.id
+				WHERE i.purchase_order_id = ?
+				ORDER BY i.id;`,
+				orderId,
+			)
+		},
+		func(rows *sql.Rows) (PurchaseOrderItem, error) {
+			item := PurchaseOrderItem{}
+			err := rows.Scan(
+				&item.Id, &item.PurchaseOrderId, &item.Quantity, &item.QuantityReceived, &item.CostPerItem,
+				&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+			)
+			return item, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `purchase_order_items` WHERE purchase_order_id=?;", orderId)
+		},
+	)
+}
+
+func GetPurchaseOrderItem(itemId, orderId int64) (PurchaseOrderItem, error) {
+	var item PurchaseOrderItem
+
+	row := database.QueryRow(
+		`SELECT 
+    		i.id, i.purchase_order_id, i.quantity, i.quantity_received, i.cost_per_item,
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+		FROM purchase_order_items i
+		LEFT OUTER JOIN products p ON i.product_id = p.id
+		WHERE i.id = ? AND i.purchase_order_id = ?;`,
+		itemId, orderId,
+	)
+	err := row.Scan(
+		&item.Id, &item.PurchaseOrderId, &item.Quantity, &item.QuantityReceived, &item.CostPerItem,
+		&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+	)
+
+	return item, err
+}
+
+func CreatePurchaseOrderItem(ctx context.Context, item PurchaseOrderItem, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
+	_, err := dbExec(
+		ctx,
+		`INSERT INTO purchase_order_items (purchase_order_id, product_id, quantity, quantity_received, cost_per_item) 
+		VALUES (?, ?, ?, ?, ?);`,
+		item.PurchaseOrderId, item.Product.Id, item.Quantity, item.QuantityReceived, item.CostPerItem,
+	)
+	return err
+}
+
+func (item *PurchaseOrderItem) DbSave(ctx context.Context, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+
+	if tx == nil {
+		dbExec = database.ExecContext
+	} else {
+		dbExec = tx.ExecContext
+	}
+
+	if item.Id > 0 {
+		_, err := dbExec(
+			ctx,
+			`UPDATE purchase_order_items SET quantity=?, quantity_received=?, cost_per_item=? WHERE id=?;`,
+			item.Quantity, item.QuantityReceived, item.CostPerItem, item.Id,
+		)
+		return err
+	}
+
+	return CreatePurchaseOrderItem(ctx, *item, tx)
+}
+
+var TooManyReceived = errors.New("received quantity exceeds ordered quantity")
+var InvalidReceiveQuantity = errors.New("received quantity must be positive")
+
+// Receive marks quantity units of this line as received and adds them to product stock.
+// The pending quantity is checked by the update itself, so concurrent receipts can't exceed the ordered quantity.
+func (item *PurchaseOrderItem) Receive(ctx context.Context, quantity int, tx *sql.Tx) error {
+	var dbExec func(context.Context, string, ...any) (sql.Result, error)
+	var dbQueryRow func(context.Context, string, ...any) *sql.Row
+
+	if tx == nil {
+		dbExec = database.ExecContext
+		dbQueryRow = database.QueryRowContext
+	} else {
+		dbExec = tx.ExecContext
+		dbQueryRow = tx.QueryRowContext
+	}
+
+	if quantity <= 0 {
+		return InvalidReceiveQuantity
+	}
+
+	result, err := dbExec(
+		ctx,
+		"UPDATE purchase_order_items SET quantity_received = quantity_received + ? WHERE id = ? AND quantity_received + ? <= quantity;",
+		quantity, item.Id, quantity,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return TooManyReceived
+	}
+
+	err = dbQueryRow(ctx, "SELECT quantity_received FROM purchase_order_items WHERE id = ?;", item.Id).Scan(&item.QuantityReceived)
+	if err != nil {
+		return err
+	}
+
+	_, err = dbExec(ctx, "UPDATE products SET quantity = (quantity + ?) WHERE id=?;", quantity, item.Product.Id)
+	if err != nil {
+		return err
+	}
+
+	item.Product.Quantity += quantity
+	return nil
+}
+
+func (item *PurchaseOrderItem) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `purchase_order_items` WHERE `id`=?;", item.Id)
+	return err
+}
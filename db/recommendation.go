@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+const recommendationsPerProduct = 20
+
+const recommendationProductColumns = `p.id, p.model, p.manufacturer, p.price, p.quantity, COALESCE(p.image_url, ''), p.warranty_days, COALESCE(p.sku, ''), COALESCE(p.barcode, ''),
+    COALESCE((SELECT i.file_name FROM product_images i WHERE i.product_id = p.id ORDER BY i.is_primary DESC, i.position LIMIT 1), ''),
+    p.rating, p.reviews_count,
+    COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.description, '')`
+
+func scanRecommendedProduct(rows *sql.Rows) (Product, error) {
+	product := Product{}
+	err := rows.Scan(
+		&product.Id, &product.Model, &product.Manufacturer, &product.Price, &product.Quantity, &product.ImageUrl, &product.WarrantyDays, &product.Sku, &product.Barcode, &product.PrimaryImage,
+		&product.Rating, &product.ReviewsCount,
+		&product.Category.Id, &product.Category.Name, &product.Category.Description,
+	)
+	return product, err
+}
+
+func RebuildRecommendations(ctx context.Context) error {
+	tx, err := BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM product_recommendations;"); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO product_recommendations (product_id, related_product_id, together_count, score)
+		SELECT product_id, related_product_id, together_count, score
+		FROM (
+			SELECT
+				pairs.product_id, pairs.related_product_id, pairs.together_count,
+				pairs.together_count / SQRT(pc1.orders_count * pc2.orders_count) AS score,
+				ROW_NUMBER() OVER (
+					PARTITION BY pairs.product_id
+					ORDER BY pairs.together_count / SQRT(pc1.orders_count * pc2.orders_count) DESC, pairs.together_count DESC, pairs.related_product_id
+				) AS position
+			FROM (
+				SELECT po1.product_id, po2.product_id AS related_product_id, COUNT(*) AS together_count
+				FROM (SELECT DISTINCT order_id, product_id FROM order_items) po1
+				INNER JOIN (SELECT DISTINCT order_id, product_id FROM order_items) po2
+					ON po1.order_id = po2.order_id AND po1.product_id <> po2.product_id
+				GROUP BY po1.product_id, po2.product_id
+			) pairs
+			INNER JOIN (SELECT product_id, COUNT(DISTINCT order_id) AS orders_count FROM order_items GROUP BY product_id) pc1
+				ON pc1.product_id = pairs.product_id
+			INNER JOIN (SELECT product_id, COUNT(DISTINCT order_id) AS orders_count FROM order_items GROUP BY product_id) pc2
+				ON pc2.product_id = pairs.related_product_id
+		) ranked
+		WHERE position <= ?;`,
+		recommendationsPerProduct,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	affected, _ := result.RowsAffected()
+	log.Printf("Computed %d product recommendations\n", affected)
+	return nil
+}
+
+func GetRecommendedProducts(productIds []int64, limit int) ([]Product, error) {
+	if len(productIds) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	exclude := make([]any, 0, len(productIds)+limit)
+	for _, id := range productIds {
+		exclude = append(exclude, id)
+	}
+
+	products, _, err := getRowsAndCount(
+		1,
+		limit,
+		func(page, pageSize int) (*sql.Rows, error) {
+			args := append(append(append([]any{}, exclude...), exclude...), pageSize)
+			return database.Query(
+				`SELECT `+recommendationProductColumns+`
+				FROM (
+					SELECT related_product_id, SUM(score) AS score
+					FROM product_recommendations
+					WHERE product_id IN (`+sqlPlaceholders(len(exclude))+`) AND related_product_id NOT IN (`+sqlPlaceholders(len(exclude))+`)
+					GROUP BY related_product_id
+				) r
+				INNER JOIN products p ON p.id = r.related_product_id
+				LEFT OUTER JOIN categories c ON p.category_id = c.id
+				WHERE p.quantity > 0 AND `+missingRequiredCharacteristicsCondition+`
+				ORDER BY r.score DESC, p.id
+				LIMIT ?;`,
+				args...,
+			)
+		},
+		scanRecommendedProduct,
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	if err != nil || len(products) >= limit {
+		return products, err
+	}
+
+	for _, product := range products {
+		exclude = append(exclude, product.Id)
+	}
+
+	bestsellers, _, err := getRowsAndCount(
+		1,
+		limit-len(products),
+		func(page, pageSize int) (*sql.Rows, error) {
+			args := append(append(append([]any{}, exclude[:len(productIds)]...), exclude...), pageSize)
+			return database.Query(
+				`SELECT `+recommendationProductColumns+`
+				FROM products p
+				LEFT OUTER JOIN categories c ON p.category_id = c.id
+				LEFT OUTER JOIN (SELECT product_id, SUM(quantity) AS sold FROM order_items GROUP BY product_id) s ON s.product_id = p.id
+				WHERE p.category_id IN (SELECT category_id FROM products WHERE id IN (`+sqlPlaceholders(len(productIds))+`))
+					AND p.id NOT IN (`+sqlPlaceholders(len(exclude))+`)
+					AND p.quantity > 0 AND `+missingRequiredCharacteristicsCondition+`
+				ORDER BY COALESCE(s.sold, 0) DESC, p.rating DESC, p.id
+				LIMIT ?;`,
+				args...,
+			)
+		},
+		scanRecommendedProduct,
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+
+	return append(products, bestsellers...), err
+}
+
+func RecommendationsLoop(rebuildSec int) {
+	duration := time.Duration(rebuildSec) * time.Second
+	timer := time.NewTimer(0)
+
+	for {
+		<-timer.C
+		log.Println("Rebuilding recommendations because of timer")
+
+		timer.Reset(duration)
+		if err := RebuildRecommendations(context.Background()); err != nil {
+			log.Printf("Failed to rebuild recommendations: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	ReportDaily   = "daily"
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+var ReportFrequencies = []string{ReportDaily, ReportWeekly, ReportMonthly}
+
+const (
+	ReportDeliverySent   = "sent"
+	ReportDeliveryFailed = "failed"
+)
+
+type ReportSchedule struct {
+	Id         int64
+	Name       string
+	Frequency  string
+	Recipients string
+	Hour       int
+	Enabled    bool
+	LastSentAt time.Time
+	NextRunAt  time.Time
+}
+
+type ReportDelivery struct {
+	Id           int64
+	ScheduleId   int64
+	ScheduleName string
+	Recipients   string
+	Subject      string
+	Status       string
+	Error        string
+	SentAt       time.Time
+}
+
+func (schedule ReportSchedule) RecipientsList() []string {
+	return strings.FieldsFunc(schedule.Recipients, func(char rune) bool {
+		return char == ',' || char == ';' || unicode.IsSpace(char)
+	})
+}
+
+// Period returns the last complete day, week or month before now.
+func (schedule ReportSchedule) Period(now time.Time) AnalysisFilter {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	switch schedule.Frequency {
+	case ReportWeekly:
+		return AnalysisFilter{From: today.AddDate(0, 0, -7), To: yesterday}
+	case ReportMonthly:
+		monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return AnalysisFilter{From: monthStart.AddDate(0, -1, 0), To: monthStart.AddDate(0, 0, -1)}
+	default:
+		return AnalysisFilter{From: yesterday, To: yesterday}
+	}
+}
+
+// NextRun returns the first time after "after" when the report is due: every day,
+// every Monday or every first day of the month at the configured hour.
+func (schedule ReportSchedule) NextRun(after time.Time) time.Time {
+	after = after.In(time.Local)
+	next := time.Date(after.Year(), after.Month(), after.Day(), schedule.Hour, 0, 0, 0, time.Local)
+
+	switch schedule.Frequency {
+	case ReportWeekly:
+		next = next.AddDate(0, 0, -(int(next.Weekday())+6)%7)
+		for !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+	case ReportMonthly:
+		next = time.Date(after.Year(), after.Month(), 1, schedule.Hour, 0, 0, 0, time.Local)
+		for !next.After(after) {
+			next = next.AddDate(0, 1, 0)
+		}
+	default:
+		for !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+
+	return next
+}
+
+func scanReportSchedule(scanner interface{ Scan(...any) error }) (ReportSchedule, error) {
+	schedule := ReportSchedule{}
+	var lastSentAt sql.NullTime
+	err := scanner.Scan(
+		&schedule.Id, &schedule.Name, &schedule.Frequency, &schedule.Recipients, &schedule.Hour, &schedule.Enabled, &lastSentAt, &schedule.NextRunAt,
+	)
+	schedule.LastSentAt = lastSentAt.Time
+	return schedule, err
+}
+
+func GetReportSchedules() ([]ReportSchedule, error) {
+	schedules, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT s.id, s.name, s.frequency, s.recipients, s.hour, s.enabled, s.last_sent_at, s.next_run_at
+				FROM report_schedules s
+				ORDER BY s.id;`,
+			)
+		},
+		func(rows *sql.Rows) (ReportSchedule, error) {
+			return scanReportSchedule(rows)
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return schedules, err
+}
+
+func GetDueReportSchedules(now time.Time) ([]ReportSchedule, error) {
+	schedules, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT s.id, s.name, s.frequency, s.recipients, s.hour, s.enabled, s.last_sent_at, s.next_run_at
+				FROM report_schedules s
+				WHERE s.enabled AND s.next_run_at <= ?
+				ORDER BY s.next_run_at;`,
+				now,
+			)
+		},
+		func(rows *sql.Rows) (ReportSchedule, error) {
+			return scanReportSchedule(rows)
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return schedules, err
+}
+
+func GetReportSchedule(scheduleId int64) (ReportSchedule, error) {
+	return scanReportSchedule(database.QueryRow(
+		`SELECT s.id, s.name, s.frequency, s.recipients, s.hour, s.enabled, s.last_sent_at, s.next_run_at
+		FROM report_schedules s
+		WHERE s.id = ?;`,
+		scheduleId,
+	))
+}
+
+func (schedule *ReportSchedule) DbSave() error {
+	schedule.NextRunAt = schedule.NextRun(time.Now())
+
+	if schedule.Id > 0 {
+		_, err := database.Exec(
+			"UPDATE report_schedules SET name=?, frequency=?, recipients=?, hour=?, enabled=?, next_run_at=? WHERE id=?;",
+			schedule.Name, schedule.Frequency, schedule.Recipients, schedule.Hour, schedule.Enabled, schedule.NextRunAt, schedule.Id,
+		)
+		return err
+	}
+
+	result, err := database.Exec(
+		"INSERT INTO report_schedules (name, frequency, recipients, hour, enabled, next_run_at) VALUES (?, ?, ?, ?, ?, ?);",
+		schedule.Name, schedule.Frequency, schedule.Recipients, schedule.Hour, schedule.Enabled, schedule.NextRunAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	schedule.Id, err = result.LastInsertId()
+	return err
+}
+
+func (schedule *ReportSchedule) MarkSent(sentAt time.Time) error {
+	schedule.LastSentAt = sentAt
+	schedule.NextRunAt = schedule.NextRun(sentAt)
+
+	_, err := database.Exec(
+		"UPDATE report_schedules SET last_sent_at=?, next_run_at=? WHERE id=?;",
+		schedule.LastSentAt, schedule.NextRunAt, schedule.Id,
+	)
+	return err
+}
+
+func (schedule *ReportSchedule) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `report_schedules` WHERE `id`=?;", schedule.Id)
+	return err
+}
+
+func CreateReportDelivery(delivery *ReportDelivery) error {
+	var scheduleId sql.NullInt64
+	if delivery.ScheduleId == 0 {
+		scheduleId = sql.NullInt64{}
+	} else {
+		scheduleId = sql.NullInt64{Int64: delivery.ScheduleId, Valid: true}
+	}
+
+	var deliveryError sql.NullString
+	if delivery.Error == "" {
+		deliveryError = sql.NullString{}
+	} else {
+		deliveryError = sql.NullString{String: delivery.Error, Valid: true}
+	}
+
+	result, err := database.Exec(
+		`INSERT INTO report_deliveries (schedule_id, schedule_name, recipients, subject, status, error)
+		VALUES (?, ?, ?, ?, ?, ?);`,
+		scheduleId, delivery.ScheduleName, delivery.Recipients, delivery.Subject, delivery.Status, deliveryError,
+	)
+	if err != nil {
+		return err
+	}
+
+	delivery.Id, err = result.LastInsertId()
+	return err
+}
+
+func GetReportDeliveries(cursorPage *CursorPage) ([]ReportDelivery, int, error) {
+	key := SortKey{Expr: "d.sent_at", IdExpr: "d.id", Desc: true}
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT CAST(`+key.Expr+` AS CHAR),
+					d.id, COALESCE(d.schedule_id, 0), d.schedule_name, d.recipients, d.subject, d.status, COALESCE(d.error, ''), d.sent_at
+				FROM report_deliveries d
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
+			)
+		},
+		func(rows *sql.Rows) (ReportDelivery, keysetCursor, error) {
+			delivery := ReportDelivery{}
+			cursor := keysetCursor{}
+			err := rows.Scan(
+				&cursor.Value,
+				&delivery.Id, &delivery.ScheduleId, &delivery.ScheduleName, &delivery.Recipients, &delivery.Subject, &delivery.Status, &delivery.Error, &delivery.SentAt,
+			)
+			cursor.Id = delivery.Id
+			return delivery, cursor, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `report_deliveries`;")
+		},
+	)
+}
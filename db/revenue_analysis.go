@@ -0,0 +1,128 @@
+package db
+
+import (
+	"cmp"
+	"slices"
+)
+
+const (
+	RevenueByCategory     = "category"
+	RevenueByManufacturer = "manufacturer"
+	RevenueByProduct      = "product"
+)
+
+var RevenueGroupings = []string{RevenueByCategory, RevenueByManufacturer, RevenueByProduct}
+
+var revenueGroupColumns = map[string][2]string{
+	RevenueByCategory:     {"COALESCE(c.id, 0)", "COALESCE(c.name, '')"},
+	RevenueByManufacturer: {"p.manufacturer", "p.manufacturer"},
+	RevenueByProduct:      {"p.id", "p.model"},
+}
+
+// productCostsQuery estimates the unit cost of every product: the average cost of received purchase orders
+// or, if nothing was received yet, the cheapest supplier price. Products without both have NULL cost.
+const productCostsQuery = `(
+	SELECT p.id AS product_id, COALESCE(po.cost, sp.cost) AS unit_cost
+	FROM products p
+		LEFT OUTER JOIN (
+			SELECT poi.product_id, SUM(poi.quantity_received * poi.cost_per_item) / SUM(poi.quantity_received) AS cost
+			FROM purchase_order_items poi
+			WHERE poi.quantity_received > 0
+			GROUP BY poi.product_id
+		) po ON po.product_id = p.id
+		LEFT OUTER JOIN (
+			SELECT spp.product_id, MIN(spp.cost_price) AS cost
+			FROM supplier_products spp
+			GROUP BY spp.product_id
+		) sp ON sp.product_id = p.id
+)`
+
+type RevenueGroup struct {
+	Key   string
+	Name  string
+	Units int
+	// Cost only covers products with a known cost, CostedRevenue is the revenue of these products
+	Revenue       float64
+	Cost          float64
+	CostedRevenue float64
+	Daily         []FloatStatPerDay
+}
+
+func (group RevenueGroup) HasCost() bool {
+	return group.CostedRevenue > 0
+}
+
+func (group RevenueGroup) Margin() float64 {
+	return group.CostedRevenue - group.Cost
+}
+
+func (group RevenueGroup) MarginRate() float64 {
+	if group.CostedRevenue == 0 {
+		return 0
+	}
+	return group.Margin() / group.CostedRevenue
+}
+
+func (group RevenueGroup) CostCoverage() float64 {
+	if group.Revenue == 0 {
+		return 0
+	}
+	return group.CostedRevenue / group.Revenue
+}
+
+// GetRevenueGroups returns revenue, units and cost per category, manufacturer or product, ordered by revenue.
+// Daily holds only days with sales.
+func GetRevenueGroups(filter AnalysisFilter, grouping string) ([]RevenueGroup, error) {
+	columns, ok := revenueGroupColumns[grouping]
+	if !ok {
+		columns = revenueGroupColumns[RevenueByProduct]
+	}
+
+	where, args := filter.rollupWhere("s", "s.product_id")
+	rows, err := database.Query(
+		`SELECT
+			`+columns[0]+` AS group_key, `+columns[1]+` AS group_name, s.day,
+			SUM(s.quantity), SUM(s.revenue), COALESCE(SUM(s.quantity * pc.unit_cost), 0), COALESCE(SUM(IF(pc.unit_cost IS NULL, 0, s.revenue)), 0)
+		FROM daily_product_sales s
+			INNER JOIN products p ON p.id = s.product_id
+			LEFT OUTER JOIN categories c ON c.id = p.category_id
+			LEFT OUTER JOIN `+productCostsQuery+` pc ON pc.product_id = s.product_id
+		WHERE `+where+`
+		GROUP BY group_key, group_name, s.day
+		ORDER BY group_key, s.day;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []RevenueGroup
+	for rows.Next() {
+		var key, name string
+		var day FloatStatPerDay
+		var units int
+		var cost, costedRevenue float64
+		if err = rows.Scan(&key, &name, &day.Day, &units, &day.Value, &cost, &costedRevenue); err != nil {
+			return nil, err
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].Key != key {
+			groups = append(groups, RevenueGroup{Key: key, Name: name})
+		}
+		group := &groups[len(groups)-1]
+		group.Units += units
+		group.Revenue += day.Value
+		group.Cost += cost
+		group.CostedRevenue += costedRevenue
+		group.Daily = append(group.Daily, day)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(groups, func(a, b RevenueGroup) int {
+		return cmp.Compare(b.Revenue, a.Revenue)
+	})
+	return groups, nil
+}
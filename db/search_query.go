@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+type SearchQuery struct {
+	Query string
+	Count int
+}
+
+type ProductPopularity struct {
+	Product Product
+	Sold    int
+}
+
+type CategoryPopularity struct {
+	Category Category
+	Sold     int
+}
+
+func RecordSearchQuery(query string) error {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" || len(query) > 255 {
+		return nil
+	}
+
+	_, err := database.Exec(
+		`INSERT INTO search_queries (query, count) VALUES (?, 1)
+		ON DUPLICATE KEY UPDATE count = count + 1, last_searched_at = NOW();`,
+		query,
+	)
+	return err
+}
+
+func GetPopularSearchQueries(limit int) ([]SearchQuery, error) {
+	queries, _, err := getRowsAndCount(
+		1,
+		limit,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				"SELECT q.query, q.count FROM search_queries q ORDER BY q.count DESC, q.last_searched_at DESC LIMIT ?;",
+				pageSize,
+			)
+		},
+		func(rows *sql.Rows) (SearchQuery, error) {
+			query := SearchQuery{}
+			err := rows.Scan(&query.Query, &query.Count)
+			return query, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return queries, err
+}
+
+func GetProductsPopularity() ([]ProductPopularity, error) {
+	products, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				p.id, p.model, p.manufacturer, COALESCE(SUM(i.quantity), 0)
+				FROM products p 
+				LEFT OUTER JOIN order_items i ON i.product_id = p.id
+				WHERE ` + missingRequiredCharacteristicsCondition + `
+				GROUP BY p.id, p.model, p.manufacturer;`,
+			)
+		},
+		func(rows *sql.Rows) (ProductPopularity, error) {
+			popularity := ProductPopularity{}
+			err := rows.Scan(&popularity.Product.Id, &popularity.Product.Model, &popularity.Product.Manufacturer, &popularity.Sold)
+			return popularity, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return products, err
+}
+
+func GetCategoriesPopularity() ([]CategoryPopularity, error) {
+	categories, _, err := getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				c.id, c.name, COALESCE(SUM(i.quantity), 0)
+				FROM categories c 
+				LEFT OUTER JOIN products p ON p.category_id = c.id AND ` + missingRequiredCharacteristicsCondition + `
+				LEFT OUTER JOIN order_items i ON i.product_id = p.id
+				GROUP BY c.id, c.name;`,
+			)
+		},
+		func(rows *sql.Rows) (CategoryPopularity, error) {
+			popularity := CategoryPopularity{}
+			err := rows.Scan(&popularity.Category.Id, &popularity.Category.Name, &popularity.Sold)
+			return popularity, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+	return categories, err
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type Supplier struct {
+	Id    int64
+	Name  string
+	Email string
+	Phone string
+}
+
+func GetSuppliers(cursorPage *CursorPage) ([]Supplier, int, error) {
+	key := SortKey{Expr: "s.id", IdExpr: "s.id"}
+
+	return getRowsAndCountByCursor(
+		cursorPage,
+		key,
+		func(keyset string, keysetArgs []any, orderBy string, limit int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				CAST(`+key.Expr+` AS CHAR),
+    				s.id, s.name, COALESCE(s.email, ''), COALESCE(s.phone, '')
+				FROM suppliers s
+				WHERE `+keyset+`
+				ORDER BY `+orderBy+` LIMIT ?;`,
+				append(keysetArgs, limit)...,
+			)
+		},
+		func(rows *sql.Rows) (Supplier, keysetCursor, error) {
+			supplier := Supplier{}
+			cursor := keysetCursor{}
+			err := rows.Scan(&cursor.Value, &supplier.Id, &supplier.Name, &supplier.Email, &supplier.Phone)
+			cursor.Id = supplier.Id
+			return supplier, cursor, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `suppliers`;")
+		},
+	)
+}
+
+func SearchSuppliers(namePart string, limit int) ([]Supplier, error) {
+	suppliers, _, err := getRowsAndCount(
+		1,
+		limit,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				s.id, s.name, COALESCE(s.email, ''), COALESCE(s.phone, '')
+				FROM suppliers s
+				WHERE LOWER(s.name) LIKE ?
+				ORDER BY s.id LIMIT ?;`,
+				"%"+strings.ToLower(namePart)+"%", pageSize,
+			)
+		},
+		func(rows *sql.Rows) (Supplier, error) {
+			supplier := Supplier{}
+			err := rows.Scan(&supplier.Id, &supplier.Name, &supplier.Email, &supplier.Phone)
+			return supplier, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT 0;")
+		},
+	)
+
+	return suppliers, err
+}
+
+func CreateSupplier(supplier Supplier) error {
+	var email sql.NullString
+	if supplier.Email == "" {
+		email = sql.NullString{}
+	} else {
+		email = sql.NullString{String: supplier.Email, Valid: true}
+	}
+
+	var phone sql.NullString
+	if supplier.Phone == "" {
+		phone = sql.NullString{}
+	} else {
+		phone = sql.NullString{String: supplier.Phone, Valid: true}
+	}
+
+	_, err := database.Exec(
+		"INSERT INTO suppliers (name, email, phone) VALUES (?, ?, ?);",
+		supplier.Name, email, phone,
+	)
+	return err
+}
+
+func GetSupplier(supplierId int64) (Supplier, error) {
+	var supplier Supplier
+
+	row := database.QueryRow(
+		"SELECT s.id, s.name, COALESCE(s.email, ''), COALESCE(s.phone, '') FROM suppliers s WHERE s.id = ?;",
+		supplierId,
+	)
+	err := row.Scan(&supplier.Id, &supplier.Name, &supplier.Email, &supplier.Phone)
+
+	return supplier, err
+}
+
+func (supplier *Supplier) DbSave() error {
+	if supplier.Id > 0 {
+		var email sql.NullString
+		if supplier.Email == "" {
+			email = sql.NullString{}
+		} else {
+			email = sql.NullString{String: supplier.Email, Valid: true}
+		}
+
+		var phone sql.NullString
+		if supplier.Phone == "" {
+			phone = sql.NullString{}
+		} else {
+			phone = sql.NullString{String: supplier.Phone, Valid: true}
+		}
+
+		_, err := database.Exec(
+			"UPDATE suppliers SET name=?, email=?, phone=? WHERE id=?;",
+			supplier.Name, email, phone, supplier.Id,
+		)
+		return err
+	}
+
+	return CreateSupplier(*supplier)
+}
+
+var SupplierHasPurchaseOrders = errors.New("supplier has purchase orders")
+
+func (supplier *Supplier) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `suppliers` WHERE `id`=?;", supplier.Id)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+		return SupplierHasPurchaseOrders
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package db
+
+import "database/sql"
+
+type SupplierProduct struct {
+	Id         int64
+	SupplierId int64
+	Product    Product
+	CostPrice  float64
+}
+
+func GetSupplierProducts(supplierId int64) ([]SupplierProduct, int, error) {
+	return getRowsAndCount(
+		1,
+		0,
+		func(page, pageSize int) (*sql.Rows, error) {
+			return database.Query(
+				`SELECT 
+    				s.id, s.supplier_id, s.cost_price,
+    				p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+				FROM supplier_products s
+				LEFT OUTER JOIN products p ON s.product_id = p.id
+				WHERE s.supplier_id = ?
+				ORDER BY s.id;`,
+				supplierId,
+			)
+		},
+		func(rows *sql.Rows) (SupplierProduct, error) {
+			item := SupplierProduct{}
+			err := rows.Scan(
+				&item.Id, &item.SupplierId, &item.CostPrice,
+				&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+			)
+			return item, err
+		},
+		func() *sql.Row {
+			return database.QueryRow("SELECT COUNT(*) FROM `supplier_products` WHERE supplier_id=?;", supplierId)
+		},
+	)
+}
+
+func GetSupplierProduct(itemId, supplierId int64) (SupplierProduct, error) {
+	var item SupplierProduct
+
+	row := database.QueryRow(
+		`SELECT 
+    		s.id, s.supplier_id, s.cost_price,
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+		FROM supplier_products s
+		LEFT OUTER JOIN products p ON s.product_id = p.id
+		WHERE s.id = ? AND s.supplier_id = ?;`,
+		itemId, supplierId,
+	)
+	err := row.Scan(
+		&item.Id, &item.SupplierId, &item.CostPrice,
+		&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+	)
+
+	return item, err
+}
+
+func GetSupplierProductByProductId(productId, supplierId int64) (SupplierProduct, error) {
+	var item SupplierProduct
+
+	row := database.QueryRow(
+		`SELECT 
+    		s.id, s.supplier_id, s.cost_price,
+    		p.id, p.model, p.manufacturer, p.price, p.quantity, p.warranty_days, COALESCE(p.image_url, '')
+		FROM supplier_products s
+		LEFT OUTER JOIN products p ON s.product_id = p.id
+		WHERE s.product_id = ? AND s.supplier_id = ?;`,
+		productId, supplierId,
+	)
+	err := row.Scan(
+		&item.Id, &item.SupplierId, &item.CostPrice,
+		&item.Product.Id, &item.Product.Model, &item.Product.Manufacturer, &item.Product.Price, &item.Product.Quantity, &item.Product.WarrantyDays, &item.Product.ImageUrl,
+	)
+
+	return item, err
+}
+
+func CreateSupplierProduct(item SupplierProduct) error {
+	_, err := database.Exec(
+		`INSERT INTO supplier_products (supplier_id, product_id, cost_price) 
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE cost_price=?;`,
+		item.SupplierId, item.Product.Id, item.CostPrice, item.CostPrice,
+	)
+	return err
+}
+
+func (item *SupplierProduct) DbSave() error {
+	if item.Id > 0 {
+		_, err := database.Exec(
+			`UPDATE supplier_products SET cost_price=? WHERE id=?;`,
+			item.CostPrice, item.Id,
+		)
+		return err
+	}
+
+	return CreateSupplierProduct(*item)
+}
+
+func (item *SupplierProduct) DbDelete() error {
+	_, err := database.Exec("DELETE FROM `supplier_products` WHERE `id`=?;", item.Id)
+	return err
+}
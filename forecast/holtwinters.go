@@ -0,0 +1,153 @@
+package forecast
+
+import "math"
+
+// Z95 is the normal quantile for 95% confidence intervals.
+const Z95 = 1.96
+
+type Point struct {
+	Value float64
+	Lower float64
+	Upper float64
+}
+
+// Model is an additive Holt-Winters exponential smoothing model.
+// Series shorter than two seasons are fitted without seasonality.
+type Model struct {
+	Alpha  float64
+	Beta   float64
+	Gamma  float64
+	Period int
+	Sigma  float64
+
+	level  float64
+	trend  float64
+	season []float64
+	length int
+}
+
+var (
+	alphaGrid = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+	betaGrid  = []float64{0, 0.05, 0.1, 0.2}
+	gammaGrid = []float64{0.05, 0.1, 0.2, 0.3, 0.5}
+)
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// smooth runs the model over the series and returns the sum of squared one-step-ahead errors.
+func (model *Model) smooth(series []float64) float64 {
+	model.length = len(series)
+	if len(series) == 0 {
+		return 0
+	}
+
+	start := 1
+	model.level = series[0]
+	model.trend = 0
+	model.season = nil
+	if model.Period > 0 {
+		start = model.Period
+		first := series[:model.Period]
+		model.level = mean(first)
+		model.trend = (mean(series[model.Period:2*model.Period]) - model.level) / float64(model.Period)
+		model.season = make([]float64, model.Period)
+		for i, value := range first {
+			model.season[i] = value - model.level
+		}
+	}
+
+	var sse float64
+	for t := start; t < len(series); t++ {
+		seasonal := 0.0
+		if model.Period > 0 {
+			seasonal = model.season[t%model.Period]
+		}
+
+		predicted := model.level + model.trend + seasonal
+		sse += (series[t] - predicted) * (series[t] - predicted)
+
+		previousLevel := model.level
+		model.level = model.Alpha*(series[t]-seasonal) + (1-model.Alpha)*(model.level+model.trend)
+		model.trend = model.Beta*(model.level-previousLevel) + (1-model.Beta)*model.trend
+		if model.Period > 0 {
+			model.season[t%model.Period] = model.Gamma*(series[t]-model.level) + (1-model.Gamma)*seasonal
+		}
+	}
+
+	if steps := len(series) - start; steps > 0 {
+		model.Sigma = math.Sqrt(sse / float64(steps))
+	}
+	return sse
+}
+
+// Fit picks the smoothing parameters with the smallest one-step-ahead error on a grid.
+func Fit(series []float64, period int) Model {
+	if len(series) < 2*period {
+		period = 0
+	}
+
+	gammas := gammaGrid
+	if period == 0 {
+		gammas = []float64{0}
+	}
+
+	best := Model{Alpha: alphaGrid[0], Period: period}
+	bestSse := math.Inf(1)
+	for _, alpha := range alphaGrid {
+		for _, beta := range betaGrid {
+			for _, gamma := range gammas {
+				model := Model{Alpha: alpha, Beta: beta, Gamma: gamma, Period: period}
+				if sse := model.smooth(series); sse < bestSse {
+					best, bestSse = model, sse
+				}
+			}
+		}
+	}
+
+	best.smooth(series)
+	return best
+}
+
+// Forecast predicts the next horizon values. The interval half-width is z·σ·sqrt(1 + (h-1)·α²),
+// the usual approximation for the growing uncertainty of simple exponential smoothing.
+func (model Model) Forecast(horizon int, z float64) []Point {
+	points := make([]Point, horizon)
+	for h := 1; h <= horizon; h++ {
+		value := model.level + float64(h)*model.trend
+		if model.Period > 0 {
+			value += model.season[(model.length+h-1)%model.Period]
+		}
+
+		width := z * model.Sigma * math.Sqrt(1+float64(h-1)*model.Alpha*model.Alpha)
+		points[h-1] = Point{Value: value, Lower: value - width, Upper: value + width}
+	}
+	return points
+}
+
+// NonNegative clamps forecasts of quantities that can't go below zero, like sales.
+func NonNegative(points []Point) []Point {
+	for i := range points {
+		points[i].Value = max(points[i].Value, 0)
+		points[i].Lower = max(points[i].Lower, 0)
+		points[i].Upper = max(points[i].Upper, 0)
+	}
+	return points
+}
+
+// StockoutDay returns the first day (counting from 1) on which cumulative sales reach the stock, or 0 if they don't within the forecast.
+func StockoutDay(sales []float64, stock float64) int {
+	var sold float64
+	for day, value := range sales {
+		sold += value
+		if sold >= stock {
+			return day + 1
+		}
+	}
+	return 0
+}
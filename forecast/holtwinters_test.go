@@ -0,0 +1,121 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+// Sales of a shop which sells more at the weekend
+var weeklyPattern = []float64{10, 8, 8, 9, 11, 15, 17}
+
+func weeklySeries(weeks int, noise float64) []float64 {
+	series := make([]float64, 0, weeks*len(weeklyPattern))
+	for i := 0; i < weeks*len(weeklyPattern); i++ {
+		value := weeklyPattern[i%len(weeklyPattern)]
+		if i%2 == 0 {
+			value += noise
+		} else {
+			value -= noise
+		}
+		series = append(series, value)
+	}
+	return series
+}
+
+func TestFitForecast(t *testing.T) {
+	tests := []struct {
+		name      string
+		series    []float64
+		period    int
+		want      []float64
+		tolerance float64
+	}{
+		{
+			name:      "weekly season",
+			series:    weeklySeries(8, 0),
+			period:    7,
+			want:      weeklyPattern,
+			tolerance: 1e-9,
+		},
+		{
+			name:      "weekly season with noise",
+			series:    weeklySeries(12, 0.5),
+			period:    7,
+			want:      weeklyPattern,
+			tolerance: 1.5,
+		},
+		{
+			name:      "too short for a season",
+			series:    []float64{5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+			period:    7,
+			want:      []float64{5, 5, 5},
+			tolerance: 1e-9,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			points := Fit(test.series, test.period).Forecast(len(test.want), Z95)
+			if len(points) != len(test.want) {
+				t.Fatalf("got %d points, want %d", len(points), len(test.want))
+			}
+			for i, point := range points {
+				if math.Abs(point.Value-test.want[i]) > test.tolerance {
+					t.Errorf("day %d: got %.3f, want %.3f", i+1, point.Value, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestForecastIntervals(t *testing.T) {
+	points := Fit(weeklySeries(12, 0.5), 7).Forecast(14, Z95)
+
+	previousWidth := 0.0
+	for i, point := range points {
+		if point.Lower > point.Value || point.Value > point.Upper {
+			t.Errorf("day %d: value %.3f is outside of the interval %.3f - %.3f", i+1, point.Value, point.Lower, point.Upper)
+		}
+
+		width := point.Upper - point.Lower
+		if width <= 0 || width < previousWidth {
+			t.Errorf("day %d: interval width %.3f doesn't grow from %.3f", i+1, width, previousWidth)
+		}
+		previousWidth = width
+	}
+}
+
+func TestNonNegative(t *testing.T) {
+	points := NonNegative([]Point{{Value: -1, Lower: -3, Upper: 1}, {Value: 2, Lower: 1, Upper: 3}})
+
+	want := []Point{{Value: 0, Lower: 0, Upper: 1}, {Value: 2, Lower: 1, Upper: 3}}
+	for i, point := range points {
+		if point != want[i] {
+			t.Errorf("point %d: got %+v, want %+v", i, point, want[i])
+		}
+	}
+}
+
+func TestStockoutDay(t *testing.T) {
+	tests := []struct {
+		name  string
+		sales []float64
+		stock float64
+		want  int
+	}{
+		{name: "no stock", sales: []float64{1, 1}, stock: 0, want: 1},
+		{name: "runs out on the last day", sales: []float64{2, 2, 2}, stock: 6, want: 3},
+		{name: "runs out in the middle", sales: []float64{1.5, 1.5, 1.5, 1.5}, stock: 2.5, want: 2},
+		{name: "lasts the whole forecast", sales: []float64{1, 1, 1}, stock: 10, want: 0},
+		{name: "no sales", sales: []float64{0, 0}, stock: 1, want: 0},
+		{name: "empty forecast", sales: nil, stock: 1, want: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := StockoutDay(test.sales, test.stock); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,91 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"go-lb4/db"
+	"go-lb4/mining"
 	"go-lb4/utils"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"time"
 )
 
+const (
+	analysisPreset7Days      = "7d"
+	analysisPreset30Days     = "30d"
+	analysisPreset90Days     = "90d"
+	analysisPresetMonthToDay = "mtd"
+	analysisPresetYear       = "year"
+	analysisPresetCustom     = "custom"
+
+	// maxAnalysisDays limits custom periods, longer ones fall back to the default preset
+	maxAnalysisDays = 731
+)
+
+type AnalysisPreset struct {
+	Value string
+	Name  string
+}
+
+var analysisPresets = []AnalysisPreset{
+	{analysisPreset7Days, "Last 7 days"},
+	{analysisPreset30Days, "Last 30 days"},
+	{analysisPreset90Days, "Last 90 days"},
+	{analysisPresetMonthToDay, "Month to date"},
+	{analysisPresetYear, "Year to date"},
+}
+
 type ProductWithCount struct {
 	Product db.Product
 	Count   int64
 }
 
-type ProductsAnalysisTmplContext struct {
-	utils.BaseTmplContext
+type AnalysisComparison struct {
+	Name     string
+	Current  float64
+	Previous float64
+}
+
+func (comparison AnalysisComparison) Change() float64 {
+	if comparison.Previous == 0 {
+		return 0
+	}
+	return (comparison.Current - comparison.Previous) / comparison.Previous * 100
+}
+
+type AnalysisFilterContext struct {
+	Filter   db.AnalysisFilter
+	Preset   string
+	Presets  []AnalysisPreset
+	Category db.Category
+	Statuses []string
+	// NoStatus hides the order status filter on pages that don't analyze orders
+	NoStatus bool
+}
+
+func (context AnalysisFilterContext) Query() template.URL {
+	params := url.Values{}
+	params.Set("preset", context.Preset)
+	params.Set("from", context.Filter.From.Format(time.DateOnly))
+	params.Set("to", context.Filter.To.Format(time.DateOnly))
+	if context.Filter.CategoryId != 0 {
+		params.Set("category_id", strconv.FormatInt(context.Filter.CategoryId, 10))
+	}
+	if context.Filter.Status != "" {
+		params.Set("status", context.Filter.Status)
+	}
+	return template.URL(params.Encode())
+}
+
+type AnalysisReport struct {
+	PreviousFilter db.AnalysisFilter
+
+	Comparisons []AnalysisComparison
 
 	MostOrdered                      ProductWithCount
 	LeastOrdered                     ProductWithCount
@@ -23,6 +93,9 @@ type ProductsAnalysisTmplContext struct {
 	CustomersPerDay                  []db.FloatStatPerDay
 	AvgTotalPerDay                   []db.FloatStatPerDay
 	MedTotalPerDay                   []db.FloatStatPerDay
+	PrevCustomersPerDay              []db.FloatStatPerDay
+	PrevAvgTotalPerDay               []db.FloatStatPerDay
+	PrevMedTotalPerDay               []db.FloatStatPerDay
 	MinOrdersDay                     db.FloatStatPerDay
 	MaxOrdersDay                     db.FloatStatPerDay
 	ProductMostCommonWithMostOrdered ProductWithCount
@@ -30,107 +103,393 @@ type ProductsAnalysisTmplContext struct {
 	LeastOrderedProductPairs         []db.OrderedProductPair
 }
 
-func fillMissingDates(counts []db.FloatStatPerDay) []db.FloatStatPerDay {
-	now := time.Now().Truncate(24 * time.Hour).UTC()
-	start := now.AddDate(0, 0, -30)
+type ProductsAnalysisTmplContext struct {
+	utils.BaseTmplContext
+	AnalysisFilterContext
+	AnalysisReport
+	RevenueForecast ForecastChart
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func getAnalysisFilter(r *http.Request) (db.AnalysisFilter, string) {
+	params := r.URL.Query()
+	today := truncateToDay(time.Now())
+
+	filter := db.AnalysisFilter{To: today}
+	filter.CategoryId, _ = strconv.ParseInt(params.Get("category_id"), 10, 64)
+	if status := params.Get("status"); slices.Contains(db.OrderStatuses, status) {
+		filter.Status = status
+	}
+
+	preset := params.Get("preset")
+	from, fromErr := time.Parse(time.DateOnly, params.Get("from"))
+	to, toErr := time.Parse(time.DateOnly, params.Get("to"))
+	if preset == analysisPresetCustom || (preset == "" && fromErr == nil && toErr == nil) {
+		if fromErr == nil && toErr == nil && !from.After(to) && to.Before(from.AddDate(0, 0, maxAnalysisDays)) {
+			filter.From, filter.To = from, to
+			return filter, analysisPresetCustom
+		}
+		preset = analysisPreset30Days
+	}
+
+	switch preset {
+	case analysisPreset7Days:
+		filter.From = today.AddDate(0, 0, -6)
+	case analysisPreset90Days:
+		filter.From = today.AddDate(0, 0, -89)
+	case analysisPresetMonthToDay:
+		filter.From = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case analysisPresetYear:
+		filter.From = time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		preset = analysisPreset30Days
+		filter.From = today.AddDate(0, 0, -29)
+	}
+
+	return filter, preset
+}
+
+func getAnalysisFilterContext(w http.ResponseWriter, r *http.Request) (AnalysisFilterContext, bool) {
+	filter, preset := getAnalysisFilter(r)
 
+	var category db.Category
+	if filter.CategoryId != 0 {
+		var err error
+		category, err = db.GetCategory(filter.CategoryId)
+		if errors.Is(err, sql.ErrNoRows) {
+			filter.CategoryId = 0
+		} else if utils.ReturnOnDatabaseError(err, w) {
+			return AnalysisFilterContext{}, false
+		}
+	}
+
+	return AnalysisFilterContext{
+		Filter:   filter,
+		Preset:   preset,
+		Presets:  analysisPresets,
+		Category: category,
+		Statuses: db.OrderStatuses,
+	}, true
+}
+
+func fillMissingDates(counts []db.FloatStatPerDay, filter db.AnalysisFilter) []db.FloatStatPerDay {
 	dateSet := make(map[time.Time]float64)
 	for _, c := range counts {
-		day := c.Day.Truncate(24 * time.Hour)
-		dateSet[day] = c.Value
+		dateSet[truncateToDay(c.Day)] = c.Value
 	}
 
 	var filled []db.FloatStatPerDay
-	for d := start; !d.After(now); d = d.AddDate(0, 0, 1) {
-		if value, exists := dateSet[d]; !exists {
-			filled = append(filled, db.FloatStatPerDay{Day: d, Value: 0})
-		} else {
-			filled = append(filled, db.FloatStatPerDay{Day: d, Value: value})
-		}
+	for d := filter.From; !d.After(filter.To); d = d.AddDate(0, 0, 1) {
+		filled = append(filled, db.FloatStatPerDay{Day: d, Value: dateSet[d]})
 	}
 
 	return filled
 }
 
-func ProductsAnalysisHandler(w http.ResponseWriter, _ *http.Request) {
-	mostOrdered, mostCount, err := db.GetMostOrderedProduct()
-	if utils.ReturnOnDatabaseError(err, w) {
-		return
+func ignoreNoRows(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
 	}
+	return err
+}
 
-	leastOrdered, leastCount, err := db.GetLeastOrderedProduct()
-	if utils.ReturnOnDatabaseError(err, w) {
-		return
+func getStatsPerDayFilled(filter db.AnalysisFilter, getStats func(db.AnalysisFilter) ([]db.FloatStatPerDay, error)) ([]db.FloatStatPerDay, error) {
+	stats, err := getStats(filter)
+	if err != nil {
+		return nil, err
 	}
+	return fillMissingDates(stats, filter), nil
+}
 
-	averageTotal, err := db.GetOrdersAverageTotal()
-	if utils.ReturnOnDatabaseError(err, w) {
-		return
+func getAnalysisReport(filter db.AnalysisFilter) (AnalysisReport, error) {
+	report := AnalysisReport{PreviousFilter: filter.Previous()}
+
+	summary, err := db.GetAnalysisSummary(filter)
+	if err != nil {
+		return report, err
 	}
 
-	customersPerDay, err := db.GetCustomersCountPerDay()
-	if utils.ReturnOnDatabaseError(err, w) {
-		return
+	previousSummary, err := db.GetAnalysisSummary(report.PreviousFilter)
+	if err != nil {
+		return report, err
 	}
 
-	avgOrderTotalPerDay, err := db.GetAverageOrderTotalPerDay()
-	if utils.ReturnOnDatabaseError(err, w) {
+	report.Comparisons = []AnalysisComparison{
+		{Name: "Orders", Current: float64(summary.Orders), Previous: float64(previousSummary.Orders)},
+		{Name: "Customers", Current: float64(summary.Customers), Previous: float64(previousSummary.Customers)},
+		{Name: "Items sold", Current: float64(summary.ItemsSold), Previous: float64(previousSummary.ItemsSold)},
+		{Name: "Revenue", Current: summary.Revenue, Previous: previousSummary.Revenue},
+		{Name: "Average order total", Current: summary.AverageTotal, Previous: previousSummary.AverageTotal},
+	}
+
+	report.MostOrdered.Product, report.MostOrdered.Count, err = db.GetMostOrderedProduct(filter)
+	if err = ignoreNoRows(err); err != nil {
+		return report, err
+	}
+
+	report.LeastOrdered.Product, report.LeastOrdered.Count, err = db.GetLeastOrderedProduct(filter)
+	if err = ignoreNoRows(err); err != nil {
+		return report, err
+	}
+
+	report.AverageOrderTotal, err = db.GetOrdersAverageTotal(filter)
+	if err != nil {
+		return report, err
+	}
+
+	if report.CustomersPerDay, err = getStatsPerDayFilled(filter, db.GetCustomersCountPerDay); err != nil {
+		return report, err
+	}
+	if report.AvgTotalPerDay, err = getStatsPerDayFilled(filter, db.GetAverageOrderTotalPerDay); err != nil {
+		return report, err
+	}
+	if report.MedTotalPerDay, err = getStatsPerDayFilled(filter, db.GetMedianOrderTotalPerDay); err != nil {
+		return report, err
+	}
+	if report.PrevCustomersPerDay, err = getStatsPerDayFilled(report.PreviousFilter, db.GetCustomersCountPerDay); err != nil {
+		return report, err
+	}
+	if report.PrevAvgTotalPerDay, err = getStatsPerDayFilled(report.PreviousFilter, db.GetAverageOrderTotalPerDay); err != nil {
+		return report, err
+	}
+	if report.PrevMedTotalPerDay, err = getStatsPerDayFilled(report.PreviousFilter, db.GetMedianOrderTotalPerDay); err != nil {
+		return report, err
+	}
+
+	minOrdersDay, minOrderCount, err := db.GetDayWithMinOrderCount(filter)
+	if err = ignoreNoRows(err); err != nil {
+		return report, err
+	}
+	report.MinOrdersDay = db.FloatStatPerDay{Day: minOrdersDay, Value: float64(minOrderCount)}
+
+	maxOrdersDay, maxOrderCount, err := db.GetDayWithMaxOrderCount(filter)
+	if err = ignoreNoRows(err); err != nil {
+		return report, err
+	}
+	report.MaxOrdersDay = db.FloatStatPerDay{Day: maxOrdersDay, Value: float64(maxOrderCount)}
+
+	if report.MostOrdered.Product.Id != 0 {
+		mostCommon := &report.ProductMostCommonWithMostOrdered
+		mostCommon.Product, mostCommon.Count, err = db.GetMostOrderedProductWithThis(report.MostOrdered.Product, filter)
+		if err = ignoreNoRows(err); err != nil {
+			return report, err
+		}
+	}
+
+	if report.MostOrderedProductPairs, err = db.GetMostOrderedProductPairs(5, filter); err != nil {
+		return report, err
+	}
+	report.LeastOrderedProductPairs, err = db.GetLeastOrderedProductPairs(5, filter)
+
+	return report, err
+}
+
+func ProductsAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
 		return
 	}
 
-	medOrderTotalPerDay, err := db.GetMedianOrderTotalPerDay()
+	report, err := getAnalysisReport(filterContext.Filter)
 	if utils.ReturnOnDatabaseError(err, w) {
 		return
 	}
 
-	minOrdersDay, minOrderCount, err := db.GetDayWithMinOrderCount()
+	revenueForecast, err := getRevenueForecast(filterContext.Filter, defaultForecastDays)
 	if utils.ReturnOnDatabaseError(err, w) {
 		return
 	}
 
-	maxOrdersDay, maxOrderCount, err := db.GetDayWithMaxOrderCount()
-	if utils.ReturnOnDatabaseError(err, w) {
+	tmpl, _ := template.ParseFiles("templates/analysis.gohtml", "templates/layout.gohtml", "templates/analysis-filter.gohtml", "templates/forecast-chart.gohtml")
+	err = tmpl.Execute(w, ProductsAnalysisTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "analysis",
+		},
+		AnalysisFilterContext: filterContext,
+		AnalysisReport:        report,
+		RevenueForecast:       revenueForecast,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+const (
+	defaultRulesMinSupport    = 1.0
+	defaultRulesMinConfidence = 30.0
+	defaultRulesMaxSize       = 4
+	rulesDisplayLimit         = 100
+)
+
+type AnalysisItemset struct {
+	mining.Itemset
+	Products []db.Product
+}
+
+type AnalysisRule struct {
+	mining.Rule
+	AntecedentProducts []db.Product
+	ConsequentProducts []db.Product
+}
+
+type AnalysisRulesTmplContext struct {
+	utils.BaseTmplContext
+	AnalysisFilterContext
+
+	MinSupport    float64
+	MinConfidence float64
+	MinSize       int
+	MaxSize       int
+	Least         bool
+
+	Error         string
+	OrdersCount   int
+	ItemsetsCount int
+	RulesCount    int
+	Itemsets      []AnalysisItemset
+	Rules         []AnalysisRule
+	DisplayLimit  int
+}
+
+var analysisTmplFuncs = template.FuncMap{
+	"percent": func(value float64) float64 {
+		return value * 100
+	},
+}
+
+func getFloatParam(r *http.Request, name string, defaultValue, minValue, maxValue float64) float64 {
+	value, err := strconv.ParseFloat(r.URL.Query().Get(name), 64)
+	if err != nil || value < minValue || value > maxValue {
+		return defaultValue
+	}
+	return value
+}
+
+func getIntParam(r *http.Request, name string, defaultValue, minValue, maxValue int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < minValue || value > maxValue {
+		return defaultValue
+	}
+	return value
+}
+
+func itemsProducts(items []int64, products map[int64]db.Product) []db.Product {
+	result := make([]db.Product, len(items))
+	for i, id := range items {
+		product, ok := products[id]
+		if !ok {
+			product = db.Product{Id: id, Model: "#" + strconv.FormatInt(id, 10)}
+		}
+		result[i] = product
+	}
+	return result
+}
+
+func AnalysisRulesHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
 		return
 	}
 
-	mostCommonWithMostOrdered, countOrdered, err := db.GetMostOrderedProductWithThis(mostOrdered)
+	minSupport := getFloatParam(r, "min_support", defaultRulesMinSupport, 0.1, 100)
+	minConfidence := getFloatParam(r, "min_confidence", defaultRulesMinConfidence, 0, 100)
+	minSize := getIntParam(r, "min_size", 2, 1, 100)
+	maxSize := getIntParam(r, "max_size", defaultRulesMaxSize, 1, mining.MaxItemsetSize)
+	least := r.URL.Query().Get("order") == "least"
+
+	baskets, err := db.GetOrderBaskets(filterContext.Filter)
 	if utils.ReturnOnDatabaseError(err, w) {
 		return
 	}
 
-	mostOrderedPairs, err := db.GetMostOrderedProductPairs(5)
-	if utils.ReturnOnDatabaseError(err, w) {
+	var miningError string
+	allItemsets, err := mining.FrequentItemsets(baskets, minSupport/100, maxSize)
+	if errors.Is(err, mining.TooManyItemsets) {
+		miningError = err.Error()
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	allRules := mining.AssociationRules(allItemsets, minConfidence/100)
+
+	var itemsets []mining.Itemset
+	for _, itemset := range allItemsets {
+		if len(itemset.Items) >= minSize {
+			itemsets = append(itemsets, itemset)
+		}
+	}
+	itemsetsCount := len(itemsets)
+	if least {
+		slices.Reverse(itemsets)
+	}
+	itemsets = itemsets[:min(len(itemsets), rulesDisplayLimit)]
+	rules := allRules[:min(len(allRules), rulesDisplayLimit)]
+
+	productIdsSet := make(map[int64]bool)
+	for _, itemset := range itemsets {
+		for _, id := range itemset.Items {
+			productIdsSet[id] = true
+		}
+	}
+	for _, rule := range rules {
+		for _, id := range append(slices.Clone(rule.Antecedent), rule.Consequent...) {
+			productIdsSet[id] = true
+		}
+	}
 
-	leastOrderedPairs, err := db.GetLeastOrderedProductPairs(5)
+	productIds := make([]int64, 0, len(productIdsSet))
+	for id := range productIdsSet {
+		productIds = append(productIds, id)
+	}
+
+	productsList, err := db.GetProductsByIds(productIds)
 	if utils.ReturnOnDatabaseError(err, w) {
 		return
 	}
 
-	tmpl, _ := template.ParseFiles("templates/analysis.gohtml", "templates/layout.gohtml")
-	err = tmpl.Execute(w, ProductsAnalysisTmplContext{
+	products := make(map[int64]db.Product, len(productsList))
+	for _, product := range productsList {
+		products[product.Id] = product
+	}
+
+	context := AnalysisRulesTmplContext{
 		BaseTmplContext: utils.BaseTmplContext{
-			Type: "analysis",
-		},
-		MostOrdered: ProductWithCount{
-			Product: mostOrdered,
-			Count:   mostCount,
+			Type: "analysis-rules",
 		},
-		LeastOrdered: ProductWithCount{
-			Product: leastOrdered,
-			Count:   leastCount,
-		},
-		AverageOrderTotal:                averageTotal,
-		CustomersPerDay:                  fillMissingDates(customersPerDay),
-		AvgTotalPerDay:                   fillMissingDates(avgOrderTotalPerDay),
-		MedTotalPerDay:                   fillMissingDates(medOrderTotalPerDay),
-		MinOrdersDay:                     db.FloatStatPerDay{Day: minOrdersDay, Value: float64(minOrderCount)},
-		MaxOrdersDay:                     db.FloatStatPerDay{Day: maxOrdersDay, Value: float64(maxOrderCount)},
-		ProductMostCommonWithMostOrdered: ProductWithCount{Product: mostCommonWithMostOrdered, Count: countOrdered},
-		MostOrderedProductPairs:          mostOrderedPairs,
-		LeastOrderedProductPairs:         leastOrderedPairs,
-	})
+		AnalysisFilterContext: filterContext,
+		MinSupport:            minSupport,
+		MinConfidence:         minConfidence,
+		MinSize:               minSize,
+		MaxSize:               maxSize,
+		Least:                 least,
+		Error:                 miningError,
+		OrdersCount:           len(baskets),
+		ItemsetsCount:         itemsetsCount,
+		RulesCount:            len(allRules),
+		DisplayLimit:          rulesDisplayLimit,
+	}
+	for _, itemset := range itemsets {
+		context.Itemsets = append(context.Itemsets, AnalysisItemset{Itemset: itemset, Products: itemsProducts(itemset.Items, products)})
+	}
+	for _, rule := range rules {
+		context.Rules = append(context.Rules, AnalysisRule{
+			Rule:               rule,
+			AntecedentProducts: itemsProducts(rule.Antecedent, products),
+			ConsequentProducts: itemsProducts(rule.Consequent, products),
+		})
+	}
+
+	tmpl := template.New("analysis-rules.gohtml")
+	_, err = tmpl.Funcs(analysisTmplFuncs).ParseFiles("templates/analysis-rules.gohtml", "templates/layout.gohtml", "templates/analysis-filter.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, context)
 	if err != nil {
 		log.Println(err)
 	}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go-lb4/db"
+	"go-lb4/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AnalysisExport struct {
+	Filter   db.AnalysisFilter
+	Category string
+	AnalysisReport
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+func formatStatDay(stat db.FloatStatPerDay, layout string) string {
+	if stat.Day.IsZero() {
+		return ""
+	}
+	return stat.Day.Format(layout)
+}
+
+func analysisExportFileName(filter db.AnalysisFilter, extension string) string {
+	return fmt.Sprintf("analysis_%s_%s.%s", filter.From.Format(time.DateOnly), filter.To.Format(time.DateOnly), extension)
+}
+
+func productPairRows(pairs []db.OrderedProductPair) [][]string {
+	var rows [][]string
+	for _, pair := range pairs {
+		rows = append(rows, []string{
+			strconv.FormatInt(pair.Products[0].Id, 10), pair.Products[0].Model,
+			strconv.FormatInt(pair.Products[1].Id, 10), pair.Products[1].Model,
+			strconv.FormatInt(pair.Count, 10),
+		})
+	}
+	return rows
+}
+
+func statsPerDayRows(report AnalysisReport) [][]string {
+	var rows [][]string
+	for i, stat := range report.CustomersPerDay {
+		row := []string{stat.Day.Format(time.DateOnly), formatFloat(stat.Value), formatFloat(report.AvgTotalPerDay[i].Value), formatFloat(report.MedTotalPerDay[i].Value)}
+		if i < len(report.PrevCustomersPerDay) {
+			row = append(
+				row,
+				report.PrevCustomersPerDay[i].Day.Format(time.DateOnly), formatFloat(report.PrevCustomersPerDay[i].Value),
+				formatFloat(report.PrevAvgTotalPerDay[i].Value), formatFloat(report.PrevMedTotalPerDay[i].Value),
+			)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func productWithCountRow(name string, product ProductWithCount) []string {
+	if product.Product.Id == 0 {
+		return []string{name, "", "", ""}
+	}
+	return []string{name, strconv.FormatInt(product.Product.Id, 10), product.Product.Model, strconv.FormatInt(product.Count, 10)}
+}
+
+func analysisCsvRows(export AnalysisExport) [][]string {
+	report := export.AnalysisReport
+	category := export.Category
+	if category == "" {
+		category = "All"
+	}
+	status := export.Filter.Status
+	if status == "" {
+		status = "All"
+	}
+
+	rows := [][]string{
+		{"Period", export.Filter.From.Format(time.DateOnly), export.Filter.To.Format(time.DateOnly)},
+		{"Previous period", report.PreviousFilter.From.Format(time.DateOnly), report.PreviousFilter.To.Format(time.DateOnly)},
+		{"Category", category},
+		{"Order status", status},
+		{},
+		{"Metric", "Current", "Previous", "Change %"},
+	}
+	for _, comparison := range report.Comparisons {
+		rows = append(rows, []string{comparison.Name, formatFloat(comparison.Current), formatFloat(comparison.Previous), formatFloat(comparison.Change())})
+	}
+
+	rows = append(
+		rows,
+		[]string{},
+		[]string{"Product", "Id", "Model", "Count"},
+		productWithCountRow("Most ordered", report.MostOrdered),
+		productWithCountRow("Least ordered", report.LeastOrdered),
+		productWithCountRow("Most common with most ordered", report.ProductMostCommonWithMostOrdered),
+		[]string{},
+		[]string{"Average order total", formatFloat(report.AverageOrderTotal)},
+		[]string{"Day with minimum order count", formatStatDay(report.MinOrdersDay, time.DateOnly), formatFloat(report.MinOrdersDay.Value)},
+		[]string{"Day with maximum order count", formatStatDay(report.MaxOrdersDay, time.DateOnly), formatFloat(report.MaxOrdersDay.Value)},
+		[]string{},
+		[]string{"Date", "Customers", "Average total", "Median total", "Previous date", "Previous customers", "Previous average total", "Previous median total"},
+	)
+	rows = append(rows, statsPerDayRows(report)...)
+
+	pairsHeader := []string{"First product id", "First product", "Second product id", "Second product", "Ordered together"}
+	rows = append(rows, []string{}, []string{"Most ordered product pairs"}, pairsHeader)
+	rows = append(rows, productPairRows(report.MostOrderedProductPairs)...)
+	rows = append(rows, []string{}, []string{"Least ordered product pairs"}, pairsHeader)
+	rows = append(rows, productPairRows(report.LeastOrderedProductPairs)...)
+
+	return rows
+}
+
+func statValues(stats []db.FloatStatPerDay) []float64 {
+	values := make([]float64, len(stats))
+	for i, stat := range stats {
+		values[i] = stat.Value
+	}
+	return values
+}
+
+func writeAnalysisPdf(export AnalysisExport) *utils.Pdf {
+	report := export.AnalysisReport
+	pdf := utils.NewPdf()
+
+	pdf.Heading("Shop analysis report", 18)
+	pdf.Paragraph(fmt.Sprintf(
+		"Period %s - %s, compared to %s - %s",
+		export.Filter.From.Format("02.01.2006"), export.Filter.To.Format("02.01.2006"),
+		report.PreviousFilter.From.Format("02.01.2006"), report.PreviousFilter.To.Format("02.01.2006"),
+	), 10)
+	if export.Category != "" {
+		pdf.Paragraph("Category: "+export.Category, 10)
+	}
+	if export.Filter.Status != "" {
+		pdf.Paragraph("Order status: "+export.Filter.Status, 10)
+	}
+	pdf.Paragraph("Generated "+time.Now().Format("02.01.2006 15:04"), 8)
+	pdf.Y += 8
+
+	var comparisonRows [][]string
+	for _, comparison := range report.Comparisons {
+		change := "-"
+		if comparison.Previous != 0 {
+			change = fmt.Sprintf("%+.1f%%", comparison.Change())
+		}
+		comparisonRows = append(comparisonRows, []string{comparison.Name, formatFloat(comparison.Current), formatFloat(comparison.Previous), change})
+	}
+	pdf.Heading("Summary", 13)
+	pdf.Table([]string{"Metric", "This period", "Previous period", "Change"}, []float64{175, 120, 120, 100}, comparisonRows, 1, 2, 3)
+
+	var productRows [][]string
+	for _, row := range [][]string{
+		productWithCountRow("Most ordered", report.MostOrdered),
+		productWithCountRow("Least ordered", report.LeastOrdered),
+		productWithCountRow("Most common with most ordered", report.ProductMostCommonWithMostOrdered),
+	} {
+		productRows = append(productRows, []string{row[0], row[2], row[3]})
+	}
+	pdf.Heading("Products", 13)
+	pdf.Table([]string{"", "Model", "Times ordered"}, []float64{175, 240, 100}, productRows, 2)
+
+	pdf.Table(
+		[]string{"", "Value"},
+		[]float64{175, 340},
+		[][]string{
+			{"Average order total", formatFloat(report.AverageOrderTotal)},
+			{"Day with minimum order count", fmt.Sprintf("%s (%.0f orders)", formatStatDay(report.MinOrdersDay, "02.01.2006"), report.MinOrdersDay.Value)},
+			{"Day with maximum order count", fmt.Sprintf("%s (%.0f orders)", formatStatDay(report.MaxOrdersDay, "02.01.2006"), report.MaxOrdersDay.Value)},
+		},
+	)
+
+	labels := make([]string, len(report.CustomersPerDay))
+	for i, stat := range report.CustomersPerDay {
+		labels[i] = stat.Day.Format("02.01")
+	}
+	pdf.Chart("Customers per day", labels, []utils.PdfSeries{
+		{Name: "This period", Values: statValues(report.CustomersPerDay), Color: utils.PdfBlue, Bars: true},
+		{Name: "Previous period", Values: statValues(report.PrevCustomersPerDay), Color: utils.PdfRed, Bars: true},
+	}, 150)
+	pdf.Chart("Average order total per day", labels, []utils.PdfSeries{
+		{Name: "This period", Values: statValues(report.AvgTotalPerDay), Color: utils.PdfBlue},
+		{Name: "Previous period", Values: statValues(report.PrevAvgTotalPerDay), Color: utils.PdfRed},
+	}, 150)
+	pdf.Chart("Median order total per day", labels, []utils.PdfSeries{
+		{Name: "This period", Values: statValues(report.MedTotalPerDay), Color: utils.PdfBlue},
+		{Name: "Previous period", Values: statValues(report.PrevMedTotalPerDay), Color: utils.PdfRed},
+	}, 150)
+
+	pdf.AddPage()
+	pdf.Heading("Daily statistics", 13)
+	var dayRows [][]string
+	for _, row := range statsPerDayRows(report) {
+		dayRows = append(dayRows, row[:4])
+	}
+	pdf.Table([]string{"Date", "Customers", "Average total", "Median total"}, []float64{130, 120, 135, 130}, dayRows, 1, 2, 3)
+
+	pairWidths := []float64{215, 215, 85}
+	pairHeader := []string{"First product", "Second product", "Together"}
+	for _, pairs := range []struct {
+		title string
+		pairs []db.OrderedProductPair
+	}{
+		{"Most ordered product pairs", report.MostOrderedProductPairs},
+		{"Least ordered product pairs", report.LeastOrderedProductPairs},
+	} {
+		var rows [][]string
+		for _, row := range productPairRows(pairs.pairs) {
+			rows = append(rows, []string{row[1], row[3], row[4]})
+		}
+		pdf.Heading(pairs.title, 13)
+		pdf.Table(pairHeader, pairWidths, rows, 2)
+	}
+
+	return pdf
+}
+
+func AnalysisExportHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := getAnalysisReport(filterContext.Filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	export := AnalysisExport{
+		Filter:         filterContext.Filter,
+		Category:       filterContext.Category.Name,
+		AnalysisReport: report,
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+analysisExportFileName(export.Filter, "json")+"\"")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(export); err != nil {
+			log.Println(err)
+		}
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+analysisExportFileName(export.Filter, "pdf")+"\"")
+		if _, err = writeAnalysisPdf(export).WriteTo(w); err != nil {
+			log.Println(err)
+		}
+	default:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+analysisExportFileName(export.Filter, "csv")+"\"")
+		writer := csv.NewWriter(w)
+		if err = writer.WriteAll(analysisCsvRows(export)); err != nil {
+			log.Println(err)
+		}
+	}
+}
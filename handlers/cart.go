@@ -17,7 +17,8 @@ import (
 type CartProductsListTmplContext struct {
 	utils.BaseTmplContext
 
-	Products []db.CartProduct
+	Products   []db.CartProduct
+	AlsoBought []db.Product
 }
 
 func CartProductsListHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,9 +35,22 @@ func CartProductsListHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	products, _, err := db.GetCartProducts(cart.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	productIds := make([]int64, 0, len(products))
+	for _, cartProduct := range products {
+		productIds = append(productIds, cartProduct.Product.Id)
+	}
+
+	alsoBought, err := db.GetRecommendedProducts(productIds, recommendationsCount)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
 
 	tmpl := template.New("list.gohtml")
-	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/cart/list.gohtml", "templates/layout.gohtml")
+	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/cart/list.gohtml", "templates/layout.gohtml", "templates/products/recommendations.gohtml")
 	if err != nil {
 		log.Println(err)
 		return
@@ -46,7 +60,8 @@ func CartProductsListHandler(w http.ResponseWriter, r *http.Request) {
 		BaseTmplContext: utils.BaseTmplContext{
 			Type: "cart",
 		},
-		Products: products,
+		Products:   products,
+		AlsoBought: alsoBought,
 	})
 	if err != nil {
 		log.Println(err)
@@ -92,7 +107,7 @@ func CartProductEditHandler(w http.ResponseWriter, r *http.Request) {
 	allGood := true
 
 	product.Quantity = utils.GetFormInt(r, "quantity", nil, &allGood, nil)
-	if product.Quantity < 1 || product.Quantity > product.Product.Quantity {
+	if product.Quantity < 1 || product.Quantity > product.AvailableQuantity() {
 		allGood = false
 	}
 
@@ -204,14 +219,14 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 	for _, product := range products {
-		if product.Quantity > product.Product.Quantity {
-			product.Quantity = product.Product.Quantity
+		if product.Quantity > product.AvailableQuantity() {
+			product.Quantity = product.AvailableQuantity()
 			if utils.ReturnOnDatabaseError(product.DbSave(ctx, tx), w) {
 				return
 			}
 		}
 
-		total += float64(product.Quantity) * product.Product.Price
+		total += float64(product.Quantity) * product.Price()
 		allProductsCount += product.Quantity
 	}
 
@@ -228,6 +243,10 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 		CartTotal:     total,
 	}
 
+	if r.Method != "POST" && len(products) > 0 {
+		trackFunnelEvent(r, db.FunnelEvent{SessionId: cart.Id, Event: db.FunnelCheckoutStarted, Quantity: allProductsCount})
+	}
+
 	if r.Method == "POST" {
 		tx, err = db.BeginTx(ctx)
 		if utils.ReturnOnDatabaseError(err, w) {
@@ -236,11 +255,10 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 		defer tx.Rollback()
 
 		order := db.Order{
-			Id:        0,
-			Customer:  db.Customer{},
-			CreatedAt: time.Now(),
-			Address:   "",
-			Status:    "created",
+			Id:       0,
+			Customer: db.Customer{},
+			Address:  "",
+			Status:   "created",
 		}
 
 		allGood := true
@@ -256,7 +274,12 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 			}
 
 			for _, product := range products {
-				if utils.ReturnOnDatabaseError(product.Product.SubtractQuantity(ctx, product.Quantity, tx), w) {
+				if product.Variant.Id > 0 {
+					err = product.Variant.SubtractQuantity(ctx, product.Quantity, tx)
+				} else {
+					err = product.Product.SubtractQuantity(ctx, product.Quantity, tx)
+				}
+				if utils.ReturnOnDatabaseError(err, w) {
 					return
 				}
 
@@ -264,8 +287,9 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 					Id:           0,
 					OrderId:      order.Id,
 					Product:      product.Product,
+					Variant:      product.Variant,
 					Quantity:     product.Quantity,
-					PricePerItem: product.Product.Price,
+					PricePerItem: product.Price(),
 				}
 
 				if utils.ReturnOnDatabaseError(item.DbSave(ctx, tx), w) {
@@ -276,6 +300,8 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 			if utils.ReturnOnDatabaseError(tx.Commit(), w) {
 				return
 			}
+			// Deferred so the rollup also includes the payment status set below
+			defer refreshDailySales(ctx, time.Now())
 
 			orderId, err := payPal.CreateOrder(strconv.FormatInt(order.Id, 10), "USD", total)
 			if err != nil {
@@ -287,6 +313,7 @@ func CartPaymentHandler(w http.ResponseWriter, r *http.Request) {
 				if utils.ReturnOnDatabaseError(order.DbSave(ctx, nil), w) {
 					return
 				}
+				trackFunnelEvent(r, db.FunnelEvent{SessionId: cart.Id, Event: db.FunnelPaymentStarted, OrderId: order.Id, Quantity: allProductsCount})
 				http.Redirect(w, r, "https://www.sandbox.paypal.com/checkoutnow?token="+orderId, 302)
 			}
 
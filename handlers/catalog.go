@@ -2,25 +2,119 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"go-lb4/db"
+	"go-lb4/search"
 	"go-lb4/utils"
 	"html/template"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type CatalogTmplContext struct {
 	Products       []db.Product
 	Category       db.Category
+	CategoryPath   []db.Category
+	CategoryTree   []db.CategoryNode
 	Query          string
 	CartItemsCount int
+	Compared       map[int64]bool
+
+	Sort     string
+	Filter   db.CatalogFilter
+	Facets   db.CatalogFacets
+	MinPrice string
+	MaxPrice string
+
+	HighlightTerms []string
 
 	Pagination utils.PaginationInfo
 	ThisUrl    string
 }
 
+func getCatalogFilter(r *http.Request) db.CatalogFilter {
+	var filter db.CatalogFilter
+	params := r.URL.Query()
+
+	filter.MinPrice, _ = strconv.ParseFloat(params.Get("min_price"), 64)
+	filter.MaxPrice, _ = strconv.ParseFloat(params.Get("max_price"), 64)
+	filter.InStock = params.Get("in_stock") == "1"
+	filter.MinRating, _ = strconv.Atoi(params.Get("min_rating"))
+	filter.MinRating = min(max(filter.MinRating, 0), 5)
+
+	for _, manufacturer := range params["manufacturer"] {
+		if manufacturer != "" {
+			filter.Manufacturers = append(filter.Manufacturers, manufacturer)
+		}
+	}
+
+	charFilters := make(map[int64]*db.CatalogCharacteristicFilter)
+	for name, values := range params {
+		if !strings.HasPrefix(name, "char_") || len(values) == 0 {
+			continue
+		}
+
+		idStr, bound, _ := strings.Cut(strings.TrimPrefix(name, "char_"), "_")
+		characteristicId, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		charFilter, ok := charFilters[characteristicId]
+		if !ok {
+			charFilter = &db.CatalogCharacteristicFilter{CharacteristicId: characteristicId}
+			charFilters[characteristicId] = charFilter
+		}
+
+		switch bound {
+		case "min", "max":
+			number, err := strconv.ParseFloat(values[0], 64)
+			if err != nil {
+				continue
+			}
+			if bound == "min" {
+				charFilter.Min = sql.NullFloat64{Float64: number, Valid: true}
+			} else {
+				charFilter.Max = sql.NullFloat64{Float64: number, Valid: true}
+			}
+		case "":
+			for _, value := range values {
+				if value != "" {
+					charFilter.Values = append(charFilter.Values, value)
+				}
+			}
+		}
+	}
+
+	for _, charFilter := range charFilters {
+		if charFilter.IsActive() {
+			filter.Characteristics = append(filter.Characteristics, *charFilter)
+		}
+	}
+
+	sort.Slice(filter.Characteristics, func(i, j int) bool {
+		return filter.Characteristics[i].CharacteristicId < filter.Characteristics[j].CharacteristicId
+	})
+
+	return filter
+}
+
+func markSelectedCategory(nodes []db.CategoryNode, categoryId int64) bool {
+	found := false
+	for idx := range nodes {
+		node := &nodes[idx]
+		node.Selected = node.Category.Id == categoryId
+		node.Expanded = markSelectedCategory(node.Children, categoryId) || node.Selected
+		found = found || node.Expanded
+	}
+	return found
+}
+
 func ProductCatalogHandler(w http.ResponseWriter, r *http.Request) {
 	cartId := utils.GetCartId(r)
 	http.SetCookie(w, &http.Cookie{Name: "cartId", Value: cartId.String(), Path: "/", HttpOnly: true, MaxAge: 86400})
@@ -33,9 +127,15 @@ func ProductCatalogHandler(w http.ResponseWriter, r *http.Request) {
 	if utils.ReturnOnDatabaseError(cart.DbSave(), w) {
 		return
 	}
+	trackFunnelEvent(r, db.FunnelEvent{SessionId: cart.Id, Event: db.FunnelCatalogView})
 
 	cartCount, err := db.GetCartProductsCount(cart.Id)
 
+	compared := make(map[int64]bool)
+	for _, id := range utils.GetCompareIds(r) {
+		compared[id] = true
+	}
+
 	allGood := true
 
 	var category db.Category
@@ -50,14 +150,61 @@ func ProductCatalogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	page, pageSize := utils.GetPageAndSize(r)
-	products, count, err := db.SearchProductsCatalog(page, pageSize, category, query)
+	var categoryPath []db.Category
+	if category.Id != 0 {
+		categoryPath, err = db.GetCategoryPath(category.Id)
+		if utils.ReturnOnDatabaseError(err, w) {
+			return
+		}
+	}
+
+	categoryTree, err := db.GetCategoryTree()
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	markSelectedCategory(categoryTree, category.Id)
+
+	filter := getCatalogFilter(r)
+	filter.Category = category
+	filter.Query = query
+	filter.Sort = r.URL.Query().Get("sort")
+
+	var highlightTerms []string
+	if query != "" && search.Products.Ready() {
+		var results []search.Result
+		results, highlightTerms = search.Products.Search(query, db.CatalogSearchLimit)
+
+		filter.FullText = true
+		filter.SearchIds = make([]int64, 0, len(results))
+		for _, result := range results {
+			filter.SearchIds = append(filter.SearchIds, result.Id)
+		}
+	}
+
+	_, pageSize := utils.GetPageAndSize(r)
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	products, count, err := db.SearchProductsCatalog(&cursorPage, filter)
 	if utils.ReturnOnDatabaseError(err, w) {
 		return
 	}
 
+	facets, err := db.GetCatalogFacets(filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if query != "" && cursorPage.PrevCursor == "" && count > 0 {
+		if err = db.RecordSearchQuery(query); err != nil {
+			log.Println(err)
+		}
+	}
+
 	tmpl := template.New("catalog.gohtml")
-	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/catalog.gohtml", "templates/pagination.gohtml")
+	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).Funcs(template.FuncMap{"highlight": search.Highlight}).ParseFiles("templates/catalog.gohtml", "templates/pagination.gohtml")
 	if err != nil {
 		log.Println(err)
 		return
@@ -66,14 +213,25 @@ func ProductCatalogHandler(w http.ResponseWriter, r *http.Request) {
 	err = tmpl.Funcs(utils.TmplPaginationFuncs).Execute(w, CatalogTmplContext{
 		Products:       products,
 		Category:       category,
+		CategoryPath:   categoryPath,
+		CategoryTree:   categoryTree,
 		Query:          query,
 		CartItemsCount: cartCount,
+		Compared:       compared,
+		Filter:         filter,
+		Facets:         facets,
+		MinPrice:       r.URL.Query().Get("min_price"),
+		MaxPrice:       r.URL.Query().Get("max_price"),
+		HighlightTerms: highlightTerms,
+		Sort:           filter.Sort,
 		Pagination: utils.PaginationInfo{
-			Page:     page,
-			PageSize: pageSize,
-			Count:    count,
-			UrlPath:  "/catalog",
-			Query:    r.URL.RawQuery,
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/catalog",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
 		},
 		ThisUrl: r.RequestURI,
 	})
@@ -81,3 +239,21 @@ func ProductCatalogHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 }
+
+func SuggestHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	suggestions := search.Suggestions.Suggest(r.URL.Query().Get("q"), min(limit, 50))
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(suggestions) > 0 {
+		suggestionsJson, _ := json.Marshal(suggestions)
+		w.Write(suggestionsJson)
+	} else {
+		w.Write([]byte("[]"))
+	}
+}
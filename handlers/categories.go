@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"go-lb4/db"
+	"go-lb4/search"
 	"go-lb4/utils"
 	"html/template"
 	"log"
@@ -17,12 +18,19 @@ type CategoriesListTmplContext struct {
 	utils.BaseTmplContext
 
 	Categories []db.Category
+	Sort       string
 	Pagination utils.PaginationInfo
 }
 
 func CategoriesListHandler(w http.ResponseWriter, r *http.Request) {
-	page, pageSize := utils.GetPageAndSize(r)
-	categories, count, err := db.GetCategories(page, pageSize)
+	_, pageSize := utils.GetPageAndSize(r)
+	sort := r.URL.Query().Get("sort")
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	categories, count, err := db.GetCategories(&cursorPage, sort)
 
 	tmpl := template.New("list.gohtml")
 	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/categories/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
@@ -36,11 +44,15 @@ func CategoriesListHandler(w http.ResponseWriter, r *http.Request) {
 			Type: "categories",
 		},
 		Categories: categories,
+		Sort:       sort,
 		Pagination: utils.PaginationInfo{
-			Page:     page,
-			PageSize: pageSize,
-			Count:    count,
-			UrlPath:  "/categories",
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/categories",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
 		},
 	})
 	if err != nil {
@@ -72,6 +84,8 @@ type CreateCategoryTmplContext struct {
 
 	Name        string
 	Description string
+	ParentId    string
+	ParentName  string
 
 	Error string
 }
@@ -81,6 +95,7 @@ func CategoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 		BaseTmplContext: utils.BaseTmplContext{
 			Type: "categories",
 		},
+		ParentId: "0",
 	}
 
 	if r.Method == "POST" {
@@ -89,6 +104,8 @@ func CategoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 
 		newCategory.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
 		newCategory.Description = utils.GetFormString(r, "description", &resp.Error, &allGood, &resp.Description)
+		newCategory.ParentId = utils.GetFormInt64(r, "parent_id", &resp.Error, &allGood, &resp.ParentId)
+		resp.ParentName = r.FormValue("_parent_name")
 
 		if allGood {
 			err := newCategory.DbSave()
@@ -96,6 +113,7 @@ func CategoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 				log.Println(err)
 			}
 
+			search.RequestRebuild()
 			http.Redirect(w, r, "/categories", 301)
 			return
 		}
@@ -111,8 +129,12 @@ func CategoryCreateHandler(w http.ResponseWriter, r *http.Request) {
 type EditCategoryTmplContext struct {
 	utils.BaseTmplContext
 
-	Name        string
-	Description string
+	CategoryId      int64
+	Name            string
+	Description     string
+	ParentId        string
+	ParentName      string
+	Characteristics []db.CategoryCharacteristic
 
 	Error string
 }
@@ -138,12 +160,21 @@ func CategoryEditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	characteristics, err := db.GetCategoryCharacteristics(category.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
 	resp := EditCategoryTmplContext{
 		BaseTmplContext: utils.BaseTmplContext{
 			Type: "categories",
 		},
-		Name:        category.Name,
-		Description: category.Description,
+		CategoryId:      category.Id,
+		Name:            category.Name,
+		Description:     category.Description,
+		ParentId:        strconv.FormatInt(category.ParentId, 10),
+		ParentName:      category.ParentName,
+		Characteristics: characteristics,
 	}
 
 	if r.Method == "POST" {
@@ -151,16 +182,23 @@ func CategoryEditHandler(w http.ResponseWriter, r *http.Request) {
 
 		category.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
 		category.Description = utils.GetFormString(r, "description", &resp.Error, &allGood, &resp.Description)
+		category.ParentId = utils.GetFormInt64(r, "parent_id", &resp.Error, &allGood, &resp.ParentId)
+		resp.ParentName = r.FormValue("_parent_name")
 
 		if allGood {
 			err = category.DbSave()
 			if err == nil {
+				search.RequestRebuild()
 				http.Redirect(w, r, "/categories", 301)
 				return
 			}
 
-			log.Println(err)
-			resp.Error += "Database error occurred. "
+			if errors.Is(err, db.CategoryCycle) {
+				resp.Error += "Category cannot be moved into itself or into one of its subcategories. "
+			} else {
+				log.Println(err)
+				resp.Error += "Database error occurred. "
+			}
 		}
 	}
 
@@ -210,6 +248,7 @@ func CategoryDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		err = category.DbDelete()
 		if err == nil {
+			search.RequestRebuild()
 			http.Redirect(w, r, "/categories", 301)
 			return
 		}
@@ -224,3 +263,113 @@ func CategoryDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 }
+
+func CategoryAddCharacteristicHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	categoryIdStr := r.PathValue("categoryId")
+	categoryId, err := strconv.ParseInt(categoryIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/categories", 301)
+		return
+	}
+
+	allGood := true
+	charId := utils.GetFormInt64(r, "characteristic_id", nil, &allGood, nil)
+	displayOrder := utils.GetFormInt(r, "display_order", nil, &allGood, nil)
+
+	if !allGood {
+		http.Redirect(w, r, "/categories/"+categoryIdStr+"/edit", 301)
+		return
+	}
+
+	category, err := db.GetCategory(categoryId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown category!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	characteristic, err := db.GetCharacteristic(charId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown characteristic!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	categoryChar := db.CategoryCharacteristic{
+		CategoryId:     category.Id,
+		Characteristic: characteristic,
+		Required:       r.FormValue("required") == "1",
+		DisplayOrder:   displayOrder,
+	}
+	if utils.ReturnOnDatabaseError(categoryChar.DbSave(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/categories/"+categoryIdStr+"/edit", 301)
+}
+
+func CategoryDeleteCharacteristicHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	categoryIdStr := r.PathValue("categoryId")
+	categoryId, err := strconv.ParseInt(categoryIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/categories", 301)
+		return
+	}
+
+	charId, err := strconv.ParseInt(r.PathValue("characteristicId"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/categories/"+categoryIdStr+"/edit", 301)
+		return
+	}
+
+	categoryChar := db.CategoryCharacteristic{
+		CategoryId:     categoryId,
+		Characteristic: db.Characteristic{Id: charId},
+	}
+	if utils.ReturnOnDatabaseError(categoryChar.DbDelete(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/categories/"+categoryIdStr+"/edit", 301)
+}
+
+func CategoryPrefillCharacteristicsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	categoryIdStr := r.PathValue("categoryId")
+	categoryId, err := strconv.ParseInt(categoryIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/categories", 301)
+		return
+	}
+
+	added, err := db.PrefillCategoryProductsCharacteristics(categoryId)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	log.Printf("Added %d blank characteristics to products of category %d\n", added, categoryId)
+
+	http.Redirect(w, r, "/categories/"+categoryIdStr+"/edit", 301)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"go-lb4/db"
+	"go-lb4/search"
 	"go-lb4/utils"
 	"html/template"
 	"log"
@@ -17,12 +18,19 @@ type CharacteristicsListTmplContext struct {
 	utils.BaseTmplContext
 
 	Characteristics []db.Characteristic
+	Sort            string
 	Pagination      utils.PaginationInfo
 }
 
 func CharacteristicsListHandler(w http.ResponseWriter, r *http.Request) {
-	page, pageSize := utils.GetPageAndSize(r)
-	characteristics, count, err := db.GetCharacteristics(page, pageSize)
+	_, pageSize := utils.GetPageAndSize(r)
+	sort := r.URL.Query().Get("sort")
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	characteristics, count, err := db.GetCharacteristics(&cursorPage, sort)
 
 	tmpl := template.New("list.gohtml")
 	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/characteristics/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
@@ -36,11 +44,15 @@ func CharacteristicsListHandler(w http.ResponseWriter, r *http.Request) {
 			Type: "characteristics",
 		},
 		Characteristics: characteristics,
+		Sort:            sort,
 		Pagination: utils.PaginationInfo{
-			Page:     page,
-			PageSize: pageSize,
-			Count:    count,
-			UrlPath:  "/characteristics",
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/characteristics",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
 		},
 	})
 	if err != nil {
@@ -70,17 +82,52 @@ func CharacteristicsSearchHandler(w http.ResponseWriter, r *http.Request) {
 type CreateCharacteristicTmplContext struct {
 	utils.BaseTmplContext
 
-	Name string
-	Unit string
+	Name          string
+	Unit          string
+	ValueType     string
+	AllowedValues string
+	Types         []string
 
 	Error string
 }
 
+func getFormCharacteristicType(r *http.Request, errorText *string, valid *bool, valueType, allowedValues *string) (string, []string) {
+	*valueType = r.FormValue("value_type")
+	*allowedValues = r.FormValue("allowed_values")
+
+	isKnown := false
+	for _, knownType := range db.CharacteristicTypes {
+		if *valueType == knownType {
+			isKnown = true
+			break
+		}
+	}
+	if !isKnown {
+		*errorText += "\"value_type\" is empty or invalid. "
+		*valid = false
+		return "", nil
+	}
+
+	if *valueType != db.CharacteristicEnum {
+		return *valueType, nil
+	}
+
+	values := db.ParseAllowedValues(*allowedValues)
+	if len(values) == 0 {
+		*errorText += "\"allowed_values\" must contain at least one value. "
+		*valid = false
+	}
+
+	return *valueType, values
+}
+
 func CharacteristicCreateHandler(w http.ResponseWriter, r *http.Request) {
 	resp := CreateCharacteristicTmplContext{
 		BaseTmplContext: utils.BaseTmplContext{
 			Type: "characteristics",
 		},
+		ValueType: db.CharacteristicText,
+		Types:     db.CharacteristicTypes,
 	}
 
 	if r.Method == "POST" {
@@ -89,6 +136,7 @@ func CharacteristicCreateHandler(w http.ResponseWriter, r *http.Request) {
 
 		newCharacteristic.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
 		newCharacteristic.Unit = utils.GetFormString(r, "measurement_unit", &resp.Error, &allGood, &resp.Unit)
+		newCharacteristic.ValueType, newCharacteristic.AllowedValues = getFormCharacteristicType(r, &resp.Error, &allGood, &resp.ValueType, &resp.AllowedValues)
 
 		if allGood {
 			err := newCharacteristic.DbSave()
@@ -111,8 +159,11 @@ func CharacteristicCreateHandler(w http.ResponseWriter, r *http.Request) {
 type EditCharacteristicTmplContext struct {
 	utils.BaseTmplContext
 
-	Name string
-	Unit string
+	Name          string
+	Unit          string
+	ValueType     string
+	AllowedValues string
+	Types         []string
 
 	Error string
 }
@@ -142,8 +193,11 @@ func CharacteristicEditHandler(w http.ResponseWriter, r *http.Request) {
 		BaseTmplContext: utils.BaseTmplContext{
 			Type: "characteristics",
 		},
-		Name: characteristic.Name,
-		Unit: characteristic.Unit,
+		Name:          characteristic.Name,
+		Unit:          characteristic.Unit,
+		ValueType:     characteristic.ValueType,
+		AllowedValues: characteristic.AllowedValuesText(),
+		Types:         db.CharacteristicTypes,
 	}
 
 	if r.Method == "POST" {
@@ -151,6 +205,7 @@ func CharacteristicEditHandler(w http.ResponseWriter, r *http.Request) {
 
 		characteristic.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
 		characteristic.Unit = utils.GetFormString(r, "measurement_unit", &resp.Error, &allGood, &resp.Unit)
+		characteristic.ValueType, characteristic.AllowedValues = getFormCharacteristicType(r, &resp.Error, &allGood, &resp.ValueType, &resp.AllowedValues)
 
 		if allGood {
 			err = characteristic.DbSave()
@@ -209,6 +264,7 @@ func CharacteristicDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		err = characteristic.DbDelete()
 		if err == nil {
+			search.RequestRebuild()
 			http.Redirect(w, r, "/characteristics", 301)
 			return
 		}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+type CompareRow struct {
+	Name      string
+	Unit      string
+	Values    []string
+	Different bool
+}
+
+type CompareTmplContext struct {
+	utils.BaseTmplContext
+
+	Products        []db.Product
+	Category        db.Category
+	Rows            []CompareRow
+	OnlyDifferences bool
+	Conflict        db.Product
+}
+
+func newCompareRow(name, unit string, values []string) CompareRow {
+	row := CompareRow{Name: name, Unit: unit, Values: values}
+	for _, value := range values {
+		if value != values[0] {
+			row.Different = true
+			break
+		}
+	}
+	return row
+}
+
+func getCompareProducts(w http.ResponseWriter, r *http.Request) ([]db.Product, error) {
+	ids := utils.GetCompareIds(r)
+
+	found, err := db.GetProductsByIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	productsById := make(map[int64]db.Product, len(found))
+	for _, product := range found {
+		productsById[product.Id] = product
+	}
+
+	// Products keep the order in which they were added to the comparison
+	var products []db.Product
+	var validIds []int64
+	for _, id := range ids {
+		product, ok := productsById[id]
+		if !ok {
+			continue
+		}
+
+		products = append(products, product)
+		validIds = append(validIds, product.Id)
+	}
+
+	if len(validIds) != len(ids) {
+		utils.SetCompareIds(w, validIds)
+	}
+
+	return products, nil
+}
+
+func CompareHandler(w http.ResponseWriter, r *http.Request) {
+	products, err := getCompareProducts(w, r)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	resp := CompareTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "compare",
+		},
+		Products:        products,
+		OnlyDifferences: r.URL.Query().Get("diff") == "1",
+	}
+
+	if conflictId, err := strconv.ParseInt(r.URL.Query().Get("conflict"), 10, 64); err == nil {
+		resp.Conflict, err = db.GetProduct(conflictId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println(err)
+		}
+	}
+
+	if len(products) > 0 {
+		resp.Category = products[0].Category
+
+		var prices, manufacturers, warranties, stock []string
+		for _, product := range products {
+			prices = append(prices, fmt.Sprintf("%.2f", product.Price))
+			manufacturers = append(manufacturers, product.Manufacturer)
+			warranties = append(warranties, strconv.Itoa(product.WarrantyDays))
+			stock = append(stock, strconv.Itoa(product.Quantity))
+		}
+		resp.Rows = append(resp.Rows,
+			newCompareRow("Price", "$", prices),
+			newCompareRow("Manufacturer", "", manufacturers),
+			newCompareRow("Warranty", "days", warranties),
+			newCompareRow("In stock", "pcs", stock),
+		)
+
+		var characteristics []db.Characteristic
+		values := make(map[int64][]string)
+		for idx, product := range products {
+			productChars, _, err := db.GetProductCharacteristics(product.Id)
+			if utils.ReturnOnDatabaseError(err, w) {
+				return
+			}
+
+			for _, char := range productChars {
+				if char.Value == "" {
+					continue
+				}
+
+				if _, ok := values[char.Characteristic.Id]; !ok {
+					characteristics = append(characteristics, char.Characteristic)
+					values[char.Characteristic.Id] = make([]string, len(products))
+				}
+
+				charValues := values[char.Characteristic.Id]
+				if charValues[idx] == "" {
+					charValues[idx] = char.Value
+				} else {
+					charValues[idx] += ", " + char.Value
+				}
+			}
+		}
+
+		for _, char := range characteristics {
+			resp.Rows = append(resp.Rows, newCompareRow(char.Name, char.Unit, values[char.Id]))
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/compare.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func CompareAddHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	backUrlGood := true
+	backUrl := utils.GetFormString(r, "back_url", nil, &backUrlGood, nil)
+	if !backUrlGood || backUrl == "" {
+		backUrl = "/compare"
+	}
+
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, backUrl, 301)
+		return
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	products, err := getCompareProducts(w, r)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	ids := make([]int64, 0, len(products)+1)
+	for _, compared := range products {
+		ids = append(ids, compared.Id)
+	}
+
+	if slices.Contains(ids, product.Id) {
+		http.Redirect(w, r, backUrl, 301)
+		return
+	}
+
+	if len(products) > 0 && products[0].Category.Id != product.Category.Id {
+		if r.FormValue("replace") != "1" {
+			http.Redirect(w, r, "/compare?conflict="+productIdStr, 301)
+			return
+		}
+		ids = ids[:0]
+	}
+
+	if len(ids) >= utils.MaxCompareProducts {
+		ids = ids[len(ids)-utils.MaxCompareProducts+1:]
+	}
+
+	utils.SetCompareIds(w, append(ids, product.Id))
+	http.Redirect(w, r, backUrl, 301)
+}
+
+func CompareRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	productId, err := strconv.ParseInt(r.PathValue("productId"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/compare", 301)
+		return
+	}
+
+	ids := slices.DeleteFunc(utils.GetCompareIds(r), func(id int64) bool {
+		return id == productId
+	})
+	utils.SetCompareIds(w, ids)
+
+	http.Redirect(w, r, "/compare", 301)
+}
+
+func CompareClearHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	utils.SetCompareIds(w, nil)
+	http.Redirect(w, r, "/compare", 301)
+}
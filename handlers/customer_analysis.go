@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	customersTopLimit  = 10
+	rfmDisplayLimit    = 100
+	customersTabValue  = "value"
+	customersTabRfm    = "rfm"
+	customersTabCohort = "cohorts"
+)
+
+type AnalysisCustomersTmplContext struct {
+	utils.BaseTmplContext
+	AnalysisFilterContext
+	CustomersTab string
+}
+
+func newAnalysisCustomersContext(filterContext AnalysisFilterContext, tab string) AnalysisCustomersTmplContext {
+	return AnalysisCustomersTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "analysis-customers",
+		},
+		AnalysisFilterContext: filterContext,
+		CustomersTab:          tab,
+	}
+}
+
+func executeAnalysisCustomersTemplate(w http.ResponseWriter, name string, context any) {
+	tmpl := template.New(name)
+	_, err := tmpl.Funcs(analysisTmplFuncs).ParseFiles(
+		"templates/"+name, "templates/layout.gohtml", "templates/analysis-filter.gohtml", "templates/analysis-customers-tabs.gohtml",
+	)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, context)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type AnalysisCustomerValueTmplContext struct {
+	AnalysisCustomersTmplContext
+
+	Summary      db.CustomerValueSummary
+	TopCustomers []db.CustomerRfm
+}
+
+func AnalysisCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := db.GetCustomerValueSummary(filterContext.Filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	customers, err := db.GetCustomersRfm(filterContext.Filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	executeAnalysisCustomersTemplate(w, "analysis-customers.gohtml", AnalysisCustomerValueTmplContext{
+		AnalysisCustomersTmplContext: newAnalysisCustomersContext(filterContext, customersTabValue),
+		Summary:                      summary,
+		TopCustomers:                 customers[:min(len(customers), customersTopLimit)],
+	})
+}
+
+type RfmSegmentStats struct {
+	Segment      string
+	Customers    int
+	Share        float64
+	Revenue      float64
+	AvgRecency   float64
+	AvgOrders    float64
+	AvgMonetary  float64
+	RevenueShare float64
+}
+
+type AnalysisRfmTmplContext struct {
+	AnalysisCustomersTmplContext
+
+	Segment        string
+	Segments       []RfmSegmentStats
+	Customers      []db.CustomerRfm
+	CustomersCount int
+	DisplayLimit   int
+}
+
+func getRfmSegmentStats(customers []db.CustomerRfm) []RfmSegmentStats {
+	stats := make([]RfmSegmentStats, len(db.RfmSegments))
+	var totalRevenue float64
+	for i, segment := range db.RfmSegments {
+		stats[i].Segment = segment
+	}
+
+	for _, customer := range customers {
+		segmentStats := &stats[slices.Index(db.RfmSegments, customer.Segment)]
+		segmentStats.Customers++
+		segmentStats.Revenue += customer.Revenue
+		segmentStats.AvgRecency += float64(customer.RecencyDays)
+		segmentStats.AvgOrders += float64(customer.Orders)
+		totalRevenue += customer.Revenue
+	}
+
+	for i := range stats {
+		if stats[i].Customers == 0 {
+			continue
+		}
+		count := float64(stats[i].Customers)
+		stats[i].Share = count / float64(len(customers))
+		stats[i].AvgRecency /= count
+		stats[i].AvgOrders /= count
+		stats[i].AvgMonetary = stats[i].Revenue / count
+		if totalRevenue > 0 {
+			stats[i].RevenueShare = stats[i].Revenue / totalRevenue
+		}
+	}
+
+	return stats
+}
+
+func AnalysisRfmHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+
+	segment := r.URL.Query().Get("segment")
+	if !slices.Contains(db.RfmSegments, segment) {
+		segment = ""
+	}
+
+	allCustomers, err := db.GetCustomersRfm(filterContext.Filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	var customers []db.CustomerRfm
+	for _, customer := range allCustomers {
+		if segment == "" || customer.Segment == segment {
+			customers = append(customers, customer)
+		}
+	}
+
+	executeAnalysisCustomersTemplate(w, "analysis-rfm.gohtml", AnalysisRfmTmplContext{
+		AnalysisCustomersTmplContext: newAnalysisCustomersContext(filterContext, customersTabRfm),
+		Segment:                      segment,
+		Segments:                     getRfmSegmentStats(allCustomers),
+		Customers:                    customers[:min(len(customers), rfmDisplayLimit)],
+		CustomersCount:               len(customers),
+		DisplayLimit:                 rfmDisplayLimit,
+	})
+}
+
+type CohortCell struct {
+	Customers int
+	Rate      float64
+	// Future is set for months after the end of the period, which have no data yet
+	Future bool
+}
+
+type AnalysisCohort struct {
+	db.Cohort
+	Cells []CohortCell
+}
+
+type AnalysisCohortsTmplContext struct {
+	AnalysisCustomersTmplContext
+
+	Months         []int
+	Cohorts        []AnalysisCohort
+	AverageByMonth []float64
+}
+
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+func AnalysisCohortsHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+
+	cohorts, err := db.GetCohorts(filterContext.Filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	monthsCount := monthsBetween(filterContext.Filter.From, filterContext.Filter.To) + 1
+	context := AnalysisCohortsTmplContext{
+		AnalysisCustomersTmplContext: newAnalysisCustomersContext(filterContext, customersTabCohort),
+		AverageByMonth:               make([]float64, monthsCount),
+	}
+	for month := range monthsCount {
+		context.Months = append(context.Months, month)
+	}
+
+	cohortsByMonth := make([]int, monthsCount)
+	for _, cohort := range cohorts {
+		analysisCohort := AnalysisCohort{Cohort: cohort}
+		available := monthsBetween(cohort.Month, filterContext.Filter.To) + 1
+		for month := range monthsCount {
+			cell := CohortCell{Future: month >= available}
+			if month < len(cohort.Active) {
+				cell.Customers = cohort.Active[month]
+			}
+			if cohort.Customers > 0 {
+				cell.Rate = float64(cell.Customers) / float64(cohort.Customers)
+			}
+			if !cell.Future {
+				context.AverageByMonth[month] += cell.Rate
+				cohortsByMonth[month]++
+			}
+			analysisCohort.Cells = append(analysisCohort.Cells, cell)
+		}
+		context.Cohorts = append(context.Cohorts, analysisCohort)
+	}
+
+	for month := range context.AverageByMonth {
+		if cohortsByMonth[month] > 0 {
+			context.AverageByMonth[month] /= float64(cohortsByMonth[month])
+		}
+	}
+
+	executeAnalysisCustomersTemplate(w, "analysis-cohorts.gohtml", context)
+}
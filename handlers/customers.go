@@ -17,12 +17,19 @@ type CustomersListTmplContext struct {
 	utils.BaseTmplContext
 
 	Customers  []db.Customer
+	Sort       string
 	Pagination utils.PaginationInfo
 }
 
 func CustomersListHandler(w http.ResponseWriter, r *http.Request) {
-	page, pageSize := utils.GetPageAndSize(r)
-	customers, count, err := db.GetCustomers(page, pageSize)
+	_, pageSize := utils.GetPageAndSize(r)
+	sort := r.URL.Query().Get("sort")
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	customers, count, err := db.GetCustomers(&cursorPage, sort)
 
 	tmpl := template.New("list.gohtml")
 	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/customers/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
@@ -36,11 +43,15 @@ func CustomersListHandler(w http.ResponseWriter, r *http.Request) {
 			Type: "customers",
 		},
 		Customers: customers,
+		Sort:      sort,
 		Pagination: utils.PaginationInfo{
-			Page:     page,
-			PageSize: pageSize,
-			Count:    count,
-			UrlPath:  "/customers",
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/customers",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
 		},
 	})
 	if err != nil {
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"cmp"
+	"go-lb4/db"
+	"go-lb4/forecast"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultForecastDays = 14
+	maxForecastDays     = 90
+	forecastSeason      = 7
+	// forecastProductsLimit bounds the model fits per request to the best sellers of the period
+	forecastProductsLimit = 50
+)
+
+type ForecastDay struct {
+	Day time.Time
+	forecast.Point
+}
+
+type ForecastChart struct {
+	Id       string
+	Label    string
+	History  []db.FloatStatPerDay
+	Forecast []ForecastDay
+}
+
+func (chart ForecastChart) Total() forecast.Point {
+	var total forecast.Point
+	for _, day := range chart.Forecast {
+		total.Value += day.Value
+		total.Lower += day.Lower
+		total.Upper += day.Upper
+	}
+	return total
+}
+
+func forecastSeries(history []db.FloatStatPerDay, days int) []ForecastDay {
+	series := make([]float64, len(history))
+	for i, day := range history {
+		series[i] = day.Value
+	}
+
+	points := forecast.NonNegative(forecast.Fit(series, forecastSeason).Forecast(days, forecast.Z95))
+
+	last := history[len(history)-1].Day
+	result := make([]ForecastDay, days)
+	for i, point := range points {
+		result[i] = ForecastDay{Day: last.AddDate(0, 0, i+1), Point: point}
+	}
+	return result
+}
+
+// forecastHistoryFilter ends the history yesterday, the partial current day would drag the forecast down.
+// The period is shifted back to keep its length.
+func forecastHistoryFilter(filter db.AnalysisFilter) db.AnalysisFilter {
+	yesterday := truncateToDay(time.Now()).AddDate(0, 0, -1)
+	if filter.To.After(yesterday) {
+		shift := int(filter.To.Sub(yesterday).Hours() / 24)
+		filter.From = filter.From.AddDate(0, 0, -shift)
+		filter.To = yesterday
+	}
+	return filter
+}
+
+func getRevenueForecast(filter db.AnalysisFilter, days int) (ForecastChart, error) {
+	filter = forecastHistoryFilter(filter)
+	history, err := getStatsPerDayFilled(filter, db.GetRevenuePerDay)
+	if err != nil {
+		return ForecastChart{}, err
+	}
+
+	return ForecastChart{
+		Id:       "revenue_forecast",
+		Label:    "revenue",
+		History:  history,
+		Forecast: forecastSeries(history, days),
+	}, nil
+}
+
+type ProductForecast struct {
+	Product   db.Product
+	SoldUnits float64
+	Units     forecast.Point
+	// Stockout days count from the day after the history ends, 0 means stock lasts the whole forecast
+	StockoutDay      int
+	StockoutEarliest int
+	StockoutLatest   int
+}
+
+// getProductForecasts fits the best selling products of the period and the selected one.
+func getProductForecasts(filter db.AnalysisFilter, days int, selectedId int64) ([]ProductForecast, map[int64]ForecastChart, error) {
+	filter = forecastHistoryFilter(filter)
+	unitsPerDay, err := db.GetProductUnitsPerDay(filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sold := make(map[int64]float64, len(unitsPerDay))
+	productIds := make([]int64, 0, len(unitsPerDay))
+	for productId, units := range unitsPerDay {
+		for _, day := range units {
+			sold[productId] += day.Value
+		}
+		productIds = append(productIds, productId)
+	}
+
+	slices.SortFunc(productIds, func(a, b int64) int {
+		return cmp.Or(cmp.Compare(sold[b], sold[a]), cmp.Compare(a, b))
+	})
+	if len(productIds) > forecastProductsLimit {
+		selected := slices.Index(productIds, selectedId)
+		productIds = productIds[:forecastProductsLimit]
+		if selected >= forecastProductsLimit {
+			productIds = append(productIds, selectedId)
+		}
+	}
+
+	products, err := db.GetProductsByIds(productIds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var forecasts []ProductForecast
+	charts := make(map[int64]ForecastChart, len(products))
+	for _, product := range products {
+		chart := ForecastChart{
+			Id:      "product_forecast",
+			Label:   "units of " + product.Model,
+			History: fillMissingDates(unitsPerDay[product.Id], filter),
+		}
+		chart.Forecast = forecastSeries(chart.History, days)
+		charts[product.Id] = chart
+
+		productForecast := ProductForecast{Product: product, Units: chart.Total()}
+		var values, lower, upper []float64
+		for _, day := range chart.Forecast {
+			values = append(values, day.Value)
+			lower = append(lower, day.Lower)
+			upper = append(upper, day.Upper)
+		}
+		for _, day := range chart.History {
+			productForecast.SoldUnits += day.Value
+		}
+
+		stock := float64(product.Quantity)
+		productForecast.StockoutDay = forecast.StockoutDay(values, stock)
+		productForecast.StockoutEarliest = forecast.StockoutDay(upper, stock)
+		productForecast.StockoutLatest = forecast.StockoutDay(lower, stock)
+		forecasts = append(forecasts, productForecast)
+	}
+
+	// Products running out soonest first, then the best sellers
+	slices.SortFunc(forecasts, func(a, b ProductForecast) int {
+		if (a.StockoutEarliest == 0) != (b.StockoutEarliest == 0) {
+			if a.StockoutEarliest == 0 {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(cmp.Compare(a.StockoutEarliest, b.StockoutEarliest), cmp.Compare(b.Units.Value, a.Units.Value))
+	})
+
+	return forecasts, charts, nil
+}
+
+type AnalysisForecastTmplContext struct {
+	utils.BaseTmplContext
+	AnalysisFilterContext
+
+	Days            int
+	RevenueForecast ForecastChart
+	Products        []ProductForecast
+	ProductId       int64
+	ProductChart    ForecastChart
+	ProductsLimit   int
+}
+
+func AnalysisForecastHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+
+	days := getIntParam(r, "days", defaultForecastDays, 1, maxForecastDays)
+	productId, _ := strconv.ParseInt(r.URL.Query().Get("product_id"), 10, 64)
+
+	revenueForecast, err := getRevenueForecast(filterContext.Filter, days)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	products, charts, err := getProductForecasts(filterContext.Filter, days, productId)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	productChart, ok := charts[productId]
+	if !ok {
+		productId = 0
+	}
+
+	tmpl := template.New("analysis-forecast.gohtml")
+	_, err = tmpl.Funcs(analysisTmplFuncs).ParseFiles(
+		"templates/analysis-forecast.gohtml", "templates/layout.gohtml", "templates/analysis-filter.gohtml", "templates/forecast-chart.gohtml",
+	)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, AnalysisForecastTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "analysis-forecast",
+		},
+		AnalysisFilterContext: filterContext,
+		Days:                  days,
+		RevenueForecast:       revenueForecast,
+		Products:              products,
+		ProductId:             productId,
+		ProductChart:          productChart,
+		ProductsLimit:         forecastProductsLimit,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
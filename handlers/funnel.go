@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+)
+
+const abandonedProductsLimit = 20
+
+var funnelStepNames = map[string]string{
+	db.FunnelCatalogView:      "Catalog view",
+	db.FunnelProductView:      "Product view",
+	db.FunnelAddToCart:        "Add to cart",
+	db.FunnelCheckoutStarted:  "Checkout started",
+	db.FunnelPaymentStarted:   "Payment started",
+	db.FunnelPaymentCompleted: "Payment completed",
+}
+
+// trackFunnelEvent only logs failures, tracking must never break the shop pages.
+// Events of orders are attributed to the session which started the payment.
+func trackFunnelEvent(r *http.Request, event db.FunnelEvent) {
+	var err error
+	if event.OrderId != 0 {
+		err = db.TrackOrderFunnelEvent(r.Context(), event)
+	} else {
+		err = db.TrackFunnelEvent(r.Context(), event)
+	}
+	if err != nil {
+		log.Printf("Failed to track %s event: %s\n", event.Event, err)
+	}
+}
+
+type FunnelStep struct {
+	Name     string
+	Sessions int
+	// StepConversion is the share of sessions of the previous step, Conversion the share of the first step
+	StepConversion float64
+	Conversion     float64
+}
+
+type AnalysisFunnelTmplContext struct {
+	utils.BaseTmplContext
+	AnalysisFilterContext
+
+	Steps             []FunnelStep
+	Conversion        float64
+	CartAbandonment   float64
+	Days              []db.FunnelDay
+	AbandonedProducts []db.AbandonedProduct
+}
+
+func fillMissingFunnelDays(days []db.FunnelDay, filter db.AnalysisFilter) []db.FunnelDay {
+	daysSet := make(map[time.Time]db.FunnelDay)
+	for _, day := range days {
+		daysSet[truncateToDay(day.Day)] = day
+	}
+
+	var filled []db.FunnelDay
+	for d := filter.From; !d.After(filter.To); d = d.AddDate(0, 0, 1) {
+		day := daysSet[d]
+		day.Day = d
+		filled = append(filled, day)
+	}
+
+	return filled
+}
+
+func AnalysisFunnelHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+	filterContext.Filter.Status = ""
+	filterContext.NoStatus = true
+	filter := filterContext.Filter
+
+	sessions, err := db.GetFunnelSessions(filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	days, err := db.GetFunnelPerDay(filter)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	abandoned, err := db.GetMostAbandonedProducts(filter, abandonedProductsLimit)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	context := AnalysisFunnelTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "analysis-funnel",
+		},
+		AnalysisFilterContext: filterContext,
+		Days:                  fillMissingFunnelDays(days, filter),
+		AbandonedProducts:     abandoned,
+	}
+
+	first := sessions[db.FunnelSteps[0]]
+	for i, event := range db.FunnelSteps {
+		step := FunnelStep{Name: funnelStepNames[event], Sessions: sessions[event]}
+		if i == 0 {
+			step.StepConversion = 1
+		} else if previous := sessions[db.FunnelSteps[i-1]]; previous > 0 {
+			step.StepConversion = float64(step.Sessions) / float64(previous)
+		}
+		if first > 0 {
+			step.Conversion = float64(step.Sessions) / float64(first)
+		}
+		context.Steps = append(context.Steps, step)
+	}
+
+	if visits := max(sessions[db.FunnelCatalogView], sessions[db.FunnelProductView]); visits > 0 {
+		context.Conversion = float64(sessions[db.FunnelPaymentCompleted]) / float64(visits)
+	}
+	if added := sessions[db.FunnelAddToCart]; added > 0 {
+		context.CartAbandonment = 1 - float64(sessions[db.FunnelPaymentCompleted])/float64(added)
+	}
+
+	tmpl := template.New("analysis-funnel.gohtml")
+	_, err = tmpl.Funcs(analysisTmplFuncs).ParseFiles("templates/analysis-funnel.gohtml", "templates/layout.gohtml", "templates/analysis-filter.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, context)
+	if err != nil {
+		log.Println(err)
+	}
+}
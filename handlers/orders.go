@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -10,17 +11,25 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type OrdersListTmplContext struct {
 	utils.BaseTmplContext
 	Orders     []db.Order
+	Sort       string
 	Pagination utils.PaginationInfo
 }
 
 func OrdersListHandler(w http.ResponseWriter, r *http.Request) {
-	page, pageSize := utils.GetPageAndSize(r)
-	orders, count, err := db.GetOrders(page, pageSize)
+	_, pageSize := utils.GetPageAndSize(r)
+	sort := r.URL.Query().Get("sort")
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	orders, count, err := db.GetOrders(&cursorPage, sort)
 
 	tmpl := template.New("list.gohtml")
 	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/orders/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
@@ -34,11 +43,15 @@ func OrdersListHandler(w http.ResponseWriter, r *http.Request) {
 			Type: "orders",
 		},
 		Orders: orders,
+		Sort:   sort,
 		Pagination: utils.PaginationInfo{
-			Page:     page,
-			PageSize: pageSize,
-			Count:    count,
-			UrlPath:  "/orders",
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/orders",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
 		},
 	})
 	if err != nil {
@@ -167,6 +180,13 @@ func OrderEditHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// refreshDailySales only logs failures, the nightly rebuild fixes rollups that missed an update.
+func refreshDailySales(ctx context.Context, day time.Time) {
+	if err := db.RefreshDailySales(ctx, day); err != nil {
+		log.Printf("Failed to refresh daily sales for %s: %s\n", day.Format(time.DateOnly), err)
+	}
+}
+
 type OrderTmplContext struct {
 	utils.BaseTmplContext
 
@@ -216,6 +236,7 @@ func OrderDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		err = order.DbDelete()
 		if err == nil {
+			refreshDailySales(r.Context(), order.CreatedAt)
 			http.Redirect(w, r, backLocation, 301)
 			return
 		}
@@ -331,7 +352,20 @@ func OrderAddProductHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if prodQuantity <= 0 || prodQuantity > product.Quantity {
+	variant, err := getOrderedVariant(r, product.Id)
+	if errors.Is(err, variantRequired) {
+		http.Redirect(w, r, "/orders/"+orderIdStr, 301)
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	stock := product.Quantity
+	if variant.Id > 0 {
+		stock = variant.Quantity
+	}
+	if prodQuantity <= 0 || prodQuantity > stock {
 		http.Redirect(w, r, "/orders/"+orderIdStr, 301)
 		return
 	}
@@ -340,23 +374,36 @@ func OrderAddProductHandler(w http.ResponseWriter, r *http.Request) {
 		Id:           0,
 		OrderId:      order.Id,
 		Product:      product,
+		Variant:      variant,
 		Quantity:     prodQuantity,
-		PricePerItem: product.Price,
+		PricePerItem: variant.EffectivePrice(product),
 	}
 
-	product.Quantity -= prodQuantity
-	err = product.DbSave(r.Context(), nil)
+	if variant.Id > 0 {
+		variant.Quantity -= prodQuantity
+		err = variant.DbSave(r.Context(), nil)
+	} else {
+		product.Quantity -= prodQuantity
+		err = product.DbSave(r.Context(), nil)
+	}
 	if utils.ReturnOnDatabaseError(err, w) {
 		return
 	}
 
 	err = orderItem.DbSave(r.Context(), nil)
 	if err != nil {
-		product.Quantity += prodQuantity
-		err = product.DbSave(r.Context(), nil)
+		if variant.Id > 0 {
+			variant.Quantity += prodQuantity
+			err = variant.DbSave(r.Context(), nil)
+		} else {
+			product.Quantity += prodQuantity
+			err = product.DbSave(r.Context(), nil)
+		}
 		if utils.ReturnOnDatabaseError(err, w) {
 			return
 		}
+	} else {
+		refreshDailySales(r.Context(), order.CreatedAt)
 	}
 
 	http.Redirect(w, r, "/orders/"+orderIdStr, 301)
@@ -404,9 +451,15 @@ func OrderDeleteProductHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product := item.Product
-	product.Quantity += item.Quantity
-	err = product.DbSave(r.Context(), nil)
+	if item.Variant.Id > 0 {
+		variant := item.Variant
+		variant.Quantity += item.Quantity
+		err = variant.DbSave(r.Context(), nil)
+	} else {
+		product := item.Product
+		product.Quantity += item.Quantity
+		err = product.DbSave(r.Context(), nil)
+	}
 	if err != nil {
 		log.Println(err)
 		w.WriteHeader(500)
@@ -414,6 +467,12 @@ func OrderDeleteProductHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	order, err := db.GetOrder(orderId)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	refreshDailySales(r.Context(), order.CreatedAt)
+
 	http.Redirect(w, r, "/orders/"+orderIdStr, 301)
 }
 
@@ -446,6 +505,8 @@ func OrderFinishPaymentHandler(w http.ResponseWriter, r *http.Request) {
 		if utils.ReturnOnDatabaseError(order.DbSave(r.Context(), nil), w) {
 			return
 		}
+		refreshDailySales(r.Context(), order.CreatedAt)
+		trackFunnelEvent(r, db.FunnelEvent{SessionId: utils.GetCartId(r), Event: db.FunnelPaymentCompleted, OrderId: order.Id})
 		http.Redirect(w, r, "/orders/"+orderIdStr, 301)
 		return
 	}
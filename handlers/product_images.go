@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-lb4/db"
+	"go-lb4/storage"
+	"go-lb4/utils"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	imageSizeOriginal = "original"
+	imageSizeMedium   = "medium"
+	imageSizeThumb    = "thumb"
+
+	maxImageUploadSize = 32 << 20
+	// maxImagePixels limits the decoded size, small files may still decode into huge bitmaps
+	maxImagePixels = 40_000_000
+)
+
+var imageTooLarge = errors.New("image dimensions are too large")
+
+var imageSizes = map[string]int{
+	imageSizeMedium: 800,
+	imageSizeThumb:  300,
+}
+
+var imageStorage storage.Storage = storage.NewLocalStorage("uploads/images")
+
+func saveProductImage(productId int64, data []byte) (db.ProductImage, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return db.ProductImage{}, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return db.ProductImage{}, imageTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return db.ProductImage{}, err
+	}
+
+	extension := ".jpg"
+	if format == "png" {
+		extension = ".png"
+	}
+
+	fileName := fmt.Sprintf("%d-%s%s", productId, uuid.New().String(), extension)
+
+	if format == "gif" {
+		var buf bytes.Buffer
+		if err = utils.EncodeImage(&buf, img, format); err != nil {
+			return db.ProductImage{}, err
+		}
+		data = buf.Bytes()
+	}
+
+	if err = imageStorage.Save(imageSizeOriginal+"/"+fileName, bytes.NewReader(data)); err != nil {
+		return db.ProductImage{}, err
+	}
+
+	for size, maxSize := range imageSizes {
+		resized, err := utils.ResizeImageBytes(img, format, maxSize)
+		if err == nil {
+			err = imageStorage.Save(size+"/"+fileName, bytes.NewReader(resized))
+		}
+		if err != nil {
+			deleteProductImageFiles(fileName)
+			return db.ProductImage{}, err
+		}
+	}
+
+	productImage := db.ProductImage{
+		ProductId: productId,
+		FileName:  fileName,
+	}
+
+	if err = db.CreateProductImage(&productImage); err != nil {
+		deleteProductImageFiles(fileName)
+		return db.ProductImage{}, err
+	}
+
+	return productImage, nil
+}
+
+func deleteProductImageFiles(fileName string) {
+	for _, size := range []string{imageSizeOriginal, imageSizeMedium, imageSizeThumb} {
+		if err := imageStorage.Delete(size + "/" + fileName); err != nil {
+			log.Printf("Failed to delete image %s/%s: %s\n", size, fileName, err)
+		}
+	}
+}
+
+func ProductUploadImagesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products", 301)
+		return
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageUploadSize)
+	if err = r.ParseMultipartForm(maxImageUploadSize); err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Invalid or too large upload!"))
+		return
+	}
+
+	var failed []string
+	for _, header := range r.MultipartForm.File["images"] {
+		file, err := header.Open()
+		if err != nil {
+			log.Println(err)
+			failed = append(failed, header.Filename)
+			continue
+		}
+
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			log.Println(err)
+			failed = append(failed, header.Filename)
+			continue
+		}
+
+		if _, err = saveProductImage(product.Id, data); err != nil {
+			log.Printf("Failed to save image \"%s\": %s\n", header.Filename, err)
+			failed = append(failed, header.Filename)
+		}
+	}
+
+	location := "/products/" + productIdStr + "/edit?back=product"
+	if len(failed) > 0 {
+		location += "&upload_error=" + url.QueryEscape(strings.Join(failed, ", "))
+	}
+	http.Redirect(w, r, location, 301)
+}
+
+func getProductImageFromPath(w http.ResponseWriter, r *http.Request) (db.ProductImage, bool) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return db.ProductImage{}, false
+	}
+
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products", 301)
+		return db.ProductImage{}, false
+	}
+
+	imageId, err := strconv.ParseInt(r.PathValue("imageId"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products/"+productIdStr+"/edit?back=product", 301)
+		return db.ProductImage{}, false
+	}
+
+	productImage, err := db.GetProductImage(imageId, productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown image!"))
+		return db.ProductImage{}, false
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return db.ProductImage{}, false
+	}
+
+	return productImage, true
+}
+
+func ProductImageDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	productImage, ok := getProductImageFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(productImage.DbDelete(), w) {
+		return
+	}
+	deleteProductImageFiles(productImage.FileName)
+
+	http.Redirect(w, r, "/products/"+strconv.FormatInt(productImage.ProductId, 10)+"/edit?back=product", 301)
+}
+
+func ProductImageSetPrimaryHandler(w http.ResponseWriter, r *http.Request) {
+	productImage, ok := getProductImageFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(productImage.SetPrimary(r.Context()), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/products/"+strconv.FormatInt(productImage.ProductId, 10)+"/edit?back=product", 301)
+}
+
+func ProductImageMoveHandler(w http.ResponseWriter, r *http.Request) {
+	productImage, ok := getProductImageFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	direction := 1
+	if r.FormValue("direction") == "up" {
+		direction = -1
+	}
+
+	if utils.ReturnOnDatabaseError(productImage.Move(r.Context(), direction), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/products/"+strconv.FormatInt(productImage.ProductId, 10)+"/edit?back=product", 301)
+}
+
+func ImageHandler(w http.ResponseWriter, r *http.Request) {
+	size := r.PathValue("size")
+	if size != imageSizeOriginal && size != imageSizeMedium && size != imageSizeThumb {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown image size!"))
+		return
+	}
+
+	fileName := r.PathValue("fileName")
+	file, modTime, err := imageStorage.Open(size + "/" + fileName)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, storage.InvalidName) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown image!"))
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		w.Write([]byte("Failed to read image!"))
+		return
+	}
+	defer file.Close()
+
+	// File names are unique per upload so content behind a name never changes
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", "\""+fileName+"\"")
+	http.ServeContent(w, r, fileName, modTime, file)
+}
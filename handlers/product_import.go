@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-lb4/db"
+	"go-lb4/search"
+	"go-lb4/utils"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const maxProductImportSize = 16 << 20
+
+var productImportColumns = []string{"sku", "model", "manufacturer", "category", "price", "quantity", "warranty_days", "barcode", "image_url"}
+var productImportRequiredColumns = []string{"model", "manufacturer", "price", "quantity"}
+
+func productExportRows() ([][]string, error) {
+	products, err := db.GetAllProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	chars, err := db.GetAllProductCharacteristics()
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]string{}, productImportColumns...)
+	charColumns := make(map[int64]int)
+	productChars := make(map[int64][]db.ProductCharacteristic)
+	for _, char := range chars {
+		if _, ok := charColumns[char.Characteristic.Id]; !ok {
+			charColumns[char.Characteristic.Id] = len(header)
+			header = append(header, char.Characteristic.Name)
+		}
+		productChars[char.ProductId] = append(productChars[char.ProductId], char)
+	}
+
+	rows := [][]string{header}
+	for _, product := range products {
+		row := make([]string, len(header))
+		row[0] = product.Sku
+		row[1] = product.Model
+		row[2] = product.Manufacturer
+		row[3] = product.Category.Name
+		row[4] = strconv.FormatFloat(product.Price, 'f', -1, 64)
+		row[5] = strconv.Itoa(product.Quantity)
+		row[6] = strconv.Itoa(product.WarrantyDays)
+		row[7] = product.Barcode
+		row[8] = product.ImageUrl
+
+		for _, char := range productChars[product.Id] {
+			row[charColumns[char.Characteristic.Id]] = char.Value
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func ProductsExportHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := productExportRows()
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if r.URL.Query().Get("format") == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"products.xlsx\"")
+		if err = utils.WriteXlsx(w, "Products", rows, 4, 5, 6); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"products.csv\"")
+	writer := csv.NewWriter(w)
+	if err = writer.WriteAll(rows); err != nil {
+		log.Println(err)
+	}
+}
+
+type ProductImportRow struct {
+	Line            int
+	Update          bool
+	Product         db.Product
+	Characteristics []db.ProductCharacteristic
+	Errors          []string
+}
+
+type ProductImportTmplContext struct {
+	utils.BaseTmplContext
+
+	FileName        string
+	Characteristics []db.Characteristic
+	Rows            []ProductImportRow
+	Errors          []string
+	ErrorRows       int
+	Applied         bool
+	Created         int
+	Updated         int
+}
+
+func readProductImportFile(fileName string, data []byte) ([][]string, error) {
+	if strings.EqualFold(filepath.Ext(fileName), ".xlsx") {
+		return utils.ReadXlsx(bytes.NewReader(data), int64(len(data)))
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader.ReadAll()
+}
+
+func parseProductImportHeader(header []string, resp *ProductImportTmplContext) (map[string]int, []int, error) {
+	columns := make(map[string]int)
+	var charColumns []int
+
+	for idx, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		key := strings.ToLower(name)
+		isKnown := false
+		for _, column := range productImportColumns {
+			if key == column {
+				isKnown = true
+				break
+			}
+		}
+
+		if isKnown {
+			if _, ok := columns[key]; ok {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("Column \"%s\" is specified more than once.", name))
+				continue
+			}
+			columns[key] = idx
+			continue
+		}
+
+		char, err := db.GetCharacteristicByName(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("Column \"%s\" is not a product field or a known characteristic.", name))
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		charColumns = append(charColumns, idx)
+		resp.Characteristics = append(resp.Characteristics, char)
+	}
+
+	for _, column := range productImportRequiredColumns {
+		if _, ok := columns[column]; !ok {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("Required column \"%s\" is missing.", column))
+		}
+	}
+
+	return columns, charColumns, nil
+}
+
+func validateProductImportRows(ctx context.Context, records [][]string, resp *ProductImportTmplContext, tx *sql.Tx) error {
+	resp.Rows = nil
+	resp.Errors = nil
+	resp.Characteristics = nil
+	resp.ErrorRows = 0
+
+	if len(records) == 0 {
+		resp.Errors = append(resp.Errors, "File is empty.")
+		return nil
+	}
+
+	columns, charColumns, err := parseProductImportHeader(records[0], resp)
+	if err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return nil
+	}
+
+	categories := make(map[string]db.Category)
+	seenKeys := make(map[string]int)
+
+	for recordIdx, record := range records[1:] {
+		cell := func(column string) (string, bool) {
+			idx, ok := columns[column]
+			if !ok || idx >= len(record) {
+				return "", ok
+			}
+			return strings.TrimSpace(record[idx]), true
+		}
+
+		isEmpty := true
+		for _, value := range record {
+			if strings.TrimSpace(value) != "" {
+				isEmpty = false
+				break
+			}
+		}
+		if isEmpty {
+			continue
+		}
+
+		row := ProductImportRow{Line: recordIdx + 2}
+
+		sku, _ := cell("sku")
+		model, _ := cell("model")
+		if model == "" {
+			row.Errors = append(row.Errors, "Model is empty.")
+		}
+
+		key := "sku:" + sku
+		if sku == "" {
+			key = "model:" + strings.ToLower(model)
+		}
+		if line, ok := seenKeys[key]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("Same product is already on line %d.", line))
+		} else {
+			seenKeys[key] = row.Line
+		}
+
+		existing, err := db.GetProductsBySkuOrModel(ctx, sku, model, tx)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 1 {
+			row.Errors = append(row.Errors, "Several products have this model, specify SKU to choose one.")
+		} else if len(existing) == 1 {
+			row.Update = true
+			row.Product = existing[0]
+		}
+
+		if sku != "" {
+			row.Product.Sku = sku
+		}
+		if model != "" {
+			row.Product.Model = model
+		}
+
+		if value, _ := cell("manufacturer"); value != "" {
+			row.Product.Manufacturer = value
+		} else {
+			row.Errors = append(row.Errors, "Manufacturer is empty.")
+		}
+
+		if value, _ := cell("price"); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				row.Errors = append(row.Errors, fmt.Sprintf("Price \"%s\" is invalid.", value))
+			}
+			row.Product.Price = price
+		} else {
+			row.Errors = append(row.Errors, "Price is empty.")
+		}
+
+		if value, _ := cell("quantity"); value != "" {
+			quantity, err := strconv.Atoi(value)
+			if err != nil || quantity < 0 {
+				row.Errors = append(row.Errors, fmt.Sprintf("Quantity \"%s\" is invalid.", value))
+			}
+			row.Product.Quantity = quantity
+		} else {
+			row.Errors = append(row.Errors, "Quantity is empty.")
+		}
+
+		if value, ok := cell("warranty_days"); ok {
+			warrantyDays := 0
+			if value != "" {
+				warrantyDays, err = strconv.Atoi(value)
+				if err != nil || warrantyDays < 0 {
+					row.Errors = append(row.Errors, fmt.Sprintf("Warranty days \"%s\" is invalid.", value))
+				}
+			}
+			row.Product.WarrantyDays = warrantyDays
+		}
+
+		if value, ok := cell("category"); ok {
+			row.Product.Category = db.Category{}
+			if value != "" {
+				category, cached := categories[strings.ToLower(value)]
+				if !cached {
+					category, err = db.GetCategoryByName(value)
+					if err != nil && !errors.Is(err, sql.ErrNoRows) {
+						return err
+					}
+					categories[strings.ToLower(value)] = category
+				}
+
+				if category.Id == 0 {
+					row.Errors = append(row.Errors, fmt.Sprintf("Category \"%s\" does not exist.", value))
+				}
+				row.Product.Category = category
+			}
+		}
+
+		if value, ok := cell("barcode"); ok {
+			row.Product.Barcode = ""
+			if value != "" {
+				barcode, err := utils.NormalizeBarcode(value)
+				if err != nil {
+					row.Errors = append(row.Errors, fmt.Sprintf("Barcode \"%s\" is not a valid EAN-13 or UPC-A code.", value))
+				}
+				row.Product.Barcode = barcode
+			}
+		}
+
+		if value, ok := cell("image_url"); ok {
+			row.Product.ImageUrl = value
+		}
+
+		for charIdx, char := range resp.Characteristics {
+			var value string
+			if charColumns[charIdx] < len(record) {
+				value = strings.TrimSpace(record[charColumns[charIdx]])
+			}
+
+			if value != "" {
+				normalized, err := char.NormalizeValue(value)
+				if err != nil {
+					row.Errors = append(row.Errors, fmt.Sprintf("Invalid value \"%s\" for \"%s\": %s.", value, char.Name, err))
+				}
+				value = normalized
+			}
+
+			row.Characteristics = append(row.Characteristics, db.ProductCharacteristic{
+				ProductId:      row.Product.Id,
+				Characteristic: char,
+				Value:          value,
+			})
+		}
+
+		if len(row.Errors) > 0 {
+			resp.ErrorRows++
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+
+	if len(resp.Rows) == 0 {
+		resp.Errors = append(resp.Errors, "File does not contain any products.")
+	}
+
+	return nil
+}
+
+func applyProductImportRows(ctx context.Context, resp *ProductImportTmplContext, tx *sql.Tx) (bool, error) {
+	for idx := range resp.Rows {
+		row := &resp.Rows[idx]
+
+		err := row.Product.DbSave(ctx, tx)
+		if errors.Is(err, db.DuplicateSkuOrBarcode) {
+			row.Errors = append(row.Errors, "Other product already has this SKU or barcode.")
+			resp.ErrorRows++
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		for _, char := range row.Characteristics {
+			if char.Value == "" {
+				continue
+			}
+
+			char.ProductId = row.Product.Id
+			if err = db.SetProductCharacteristicValue(ctx, char, tx); err != nil {
+				return false, err
+			}
+		}
+
+		if err = db.PrefillProductCharacteristics(ctx, row.Product.Id, tx); err != nil {
+			return false, err
+		}
+
+		if row.Update {
+			resp.Updated++
+		} else {
+			resp.Created++
+		}
+	}
+
+	return true, nil
+}
+
+func ProductsImportHandler(w http.ResponseWriter, r *http.Request) {
+	resp := ProductImportTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "products",
+		},
+	}
+
+	if r.Method == "POST" {
+		r.Body = http.MaxBytesReader(w, r.Body, maxProductImportSize)
+		if err := r.ParseMultipartForm(maxProductImportSize); err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("Invalid or too large upload!"))
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("File is not specified!"))
+			return
+		}
+
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(400)
+			w.Write([]byte("Failed to read file!"))
+			return
+		}
+
+		resp.FileName = header.Filename
+
+		records, err := readProductImportFile(header.Filename, data)
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("Failed to read file: %s.", err))
+		} else if r.FormValue("action") == "apply" {
+			tx, err := db.BeginTx(r.Context())
+			if utils.ReturnOnDatabaseError(err, w) {
+				return
+			}
+
+			err = validateProductImportRows(r.Context(), records, &resp, tx)
+			if err != nil {
+				tx.Rollback()
+				utils.ReturnOnDatabaseError(err, w)
+				return
+			}
+
+			if len(resp.Errors) == 0 && resp.ErrorRows == 0 {
+				resp.Applied, err = applyProductImportRows(r.Context(), &resp, tx)
+				if err != nil {
+					tx.Rollback()
+					utils.ReturnOnDatabaseError(err, w)
+					return
+				}
+			}
+
+			if resp.Applied {
+				err = tx.Commit()
+				if err == nil {
+					for _, row := range resp.Rows {
+						search.ProductChanged(row.Product.Id)
+					}
+				}
+			} else {
+				resp.Created, resp.Updated = 0, 0
+				err = tx.Rollback()
+			}
+			if utils.ReturnOnDatabaseError(err, w) {
+				return
+			}
+		} else {
+			err = validateProductImportRows(r.Context(), records, &resp, nil)
+			if utils.ReturnOnDatabaseError(err, w) {
+				return
+			}
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/products/import.gohtml", "templates/layout.gohtml")
+	err := tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+func ProductAddVariantHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products", 301)
+		return
+	}
+
+	allGood := true
+	priceGood := true
+	imageGood := true
+	sku := utils.GetFormStringNonEmpty(r, "sku", nil, &allGood, nil)
+	quantity := utils.GetFormInt(r, "quantity", nil, &allGood, nil)
+	price := utils.GetFormDouble(r, "price", nil, &priceGood, nil)
+	imageUrl := utils.GetFormString(r, "image_url", nil, &imageGood, nil)
+
+	if !allGood || quantity < 0 || price < 0 {
+		http.Redirect(w, r, "/products/"+productIdStr, 301)
+		return
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	variant := db.ProductVariant{
+		Id:        0,
+		ProductId: product.Id,
+		Sku:       sku,
+		Price:     price,
+		Quantity:  quantity,
+		ImageUrl:  imageUrl,
+	}
+
+	if utils.ReturnOnDatabaseError(variant.DbSave(r.Context(), nil), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/products/"+productIdStr, 301)
+}
+
+type EditProductVariantTmplContext struct {
+	utils.BaseTmplContext
+
+	Product db.Product
+	Variant db.ProductVariant
+	Values  []db.ProductVariantValue
+
+	Sku      string
+	Price    string
+	Quantity string
+	ImageUrl string
+
+	Error string
+}
+
+func getProductVariantFromPath(w http.ResponseWriter, r *http.Request) (db.Product, db.ProductVariant, bool) {
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products", 301)
+		return db.Product{}, db.ProductVariant{}, false
+	}
+
+	variantIdStr := r.PathValue("variantId")
+	variantId, err := strconv.ParseInt(variantIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products/"+productIdStr, 301)
+		return db.Product{}, db.ProductVariant{}, false
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return db.Product{}, db.ProductVariant{}, false
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return db.Product{}, db.ProductVariant{}, false
+	}
+
+	variant, err := db.GetProductVariant(variantId, product.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product variant!"))
+		return db.Product{}, db.ProductVariant{}, false
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return db.Product{}, db.ProductVariant{}, false
+	}
+
+	return product, variant, true
+}
+
+func ProductVariantEditHandler(w http.ResponseWriter, r *http.Request) {
+	product, variant, ok := getProductVariantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	resp := EditProductVariantTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "products",
+		},
+		Product:  product,
+		Variant:  variant,
+		Sku:      variant.Sku,
+		Price:    strconv.FormatFloat(variant.Price, 'f', 2, 64),
+		Quantity: strconv.Itoa(variant.Quantity),
+		ImageUrl: variant.ImageUrl,
+	}
+
+	if r.Method == "POST" {
+		allGood := true
+
+		variant.Sku = utils.GetFormStringNonEmpty(r, "sku", &resp.Error, &allGood, &resp.Sku)
+		variant.Price = utils.GetFormDouble(r, "price", &resp.Error, &allGood, &resp.Price)
+		variant.Quantity = utils.GetFormInt(r, "quantity", &resp.Error, &allGood, &resp.Quantity)
+		variant.ImageUrl = utils.GetFormString(r, "image_url", &resp.Error, &allGood, &resp.ImageUrl)
+
+		if allGood {
+			err := variant.DbSave(r.Context(), nil)
+			if err == nil {
+				http.Redirect(w, r, "/products/"+strconv.FormatInt(product.Id, 10), 301)
+				return
+			}
+
+			log.Println(err)
+			resp.Error += "Database error occurred. "
+		}
+	}
+
+	values, _, err := db.GetProductVariantValues(variant.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	resp.Values = values
+
+	tmpl, _ := template.ParseFiles("templates/products/variant.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func ProductVariantDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	product, variant, ok := getProductVariantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(variant.DbDelete(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/products/"+strconv.FormatInt(product.Id, 10), 301)
+}
+
+func ProductVariantAddValueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	product, variant, ok := getProductVariantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	backUrl := "/products/" + strconv.FormatInt(product.Id, 10) + "/variants/" + strconv.FormatInt(variant.Id, 10) + "/edit"
+
+	allGood := true
+	charId := utils.GetFormInt64(r, "characteristic_id", nil, &allGood, nil)
+	charValue := utils.GetFormStringNonEmpty(r, "value", nil, &allGood, nil)
+
+	if !allGood {
+		http.Redirect(w, r, backUrl, 301)
+		return
+	}
+
+	characteristic, err := db.GetCharacteristic(charId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown characteristic!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	charValue, err = characteristic.NormalizeValue(charValue)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf("Invalid value for \"%s\": %s!", characteristic.Name, err)))
+		return
+	}
+
+	value := db.ProductVariantValue{
+		Id:             0,
+		VariantId:      variant.Id,
+		Characteristic: characteristic,
+		Value:          charValue,
+	}
+
+	if utils.ReturnOnDatabaseError(value.DbSave(), w) {
+		return
+	}
+
+	http.Redirect(w, r, backUrl, 301)
+}
+
+func ProductVariantDeleteValueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	product, variant, ok := getProductVariantFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	backUrl := "/products/" + strconv.FormatInt(product.Id, 10) + "/variants/" + strconv.FormatInt(variant.Id, 10) + "/edit"
+
+	valueId, err := strconv.ParseInt(r.PathValue("valueId"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, backUrl, 301)
+		return
+	}
+
+	value, err := db.GetProductVariantValue(valueId, variant.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown variant value!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(value.DbDelete(), w) {
+		return
+	}
+
+	http.Redirect(w, r, backUrl, 301)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"go-lb4/db"
+	"go-lb4/search"
 	"go-lb4/utils"
 	"html/template"
 	"log"
@@ -18,12 +19,19 @@ type ProductsListTmplContext struct {
 	utils.BaseTmplContext
 
 	Products   []db.Product
+	Sort       string
 	Pagination utils.PaginationInfo
 }
 
 func ProductsListHandler(w http.ResponseWriter, r *http.Request) {
-	page, pageSize := utils.GetPageAndSize(r)
-	products, count, err := db.GetProducts(page, pageSize)
+	_, pageSize := utils.GetPageAndSize(r)
+	sort := r.URL.Query().Get("sort")
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	products, count, err := db.GetProducts(&cursorPage, sort)
 
 	tmpl := template.New("list.gohtml")
 	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/products/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
@@ -37,11 +45,15 @@ func ProductsListHandler(w http.ResponseWriter, r *http.Request) {
 			Type: "products",
 		},
 		Products: products,
+		Sort:     sort,
 		Pagination: utils.PaginationInfo{
-			Page:     page,
-			PageSize: pageSize,
-			Count:    count,
-			UrlPath:  "/products",
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/products",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
 		},
 	})
 	if err != nil {
@@ -68,6 +80,71 @@ func ProductsSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func ProductLookupHandler(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		w.WriteHeader(400)
+		w.Write([]byte("Code is empty!"))
+		return
+	}
+
+	product, err := db.GetProductBySkuOrBarcode(code)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	productJson, _ := json.Marshal(product)
+	w.Write(productJson)
+}
+
+func ProductBarcodeHandler(w http.ResponseWriter, r *http.Request) {
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products", 301)
+		return
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if product.Barcode == "" {
+		w.WriteHeader(404)
+		w.Write([]byte("Product has no barcode!"))
+		return
+	}
+
+	var image []byte
+	if r.URL.Query().Get("format") == "svg" {
+		image, err = utils.BarcodeSvg(product.Barcode, 2, 80)
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		image, err = utils.BarcodePng(product.Barcode, 2, 80)
+		w.Header().Set("Content-Type", "image/png")
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		w.Write([]byte("Failed to generate barcode!"))
+		return
+	}
+
+	w.Write(image)
+}
+
 type CreateProductTmplContext struct {
 	utils.BaseTmplContext
 
@@ -78,6 +155,8 @@ type CreateProductTmplContext struct {
 	ImageUrl     string
 	WarrantyDays string
 	CategoryId   string
+	Sku          string
+	Barcode      string
 
 	Error string
 }
@@ -100,15 +179,28 @@ func ProductCreateHandler(w http.ResponseWriter, r *http.Request) {
 		newProduct.WarrantyDays = utils.GetFormInt(r, "warranty_days", &resp.Error, &allGood, &resp.WarrantyDays)
 		newProduct.ImageUrl = utils.GetFormString(r, "image_url", &resp.Error, &allGood, &resp.ImageUrl)
 		newProduct.Category.Id = utils.GetFormInt64(r, "category_id", &resp.Error, &allGood, &resp.CategoryId)
+		newProduct.Sku = utils.GetFormString(r, "sku", &resp.Error, &allGood, &resp.Sku)
+		newProduct.Barcode = getFormBarcode(r, &resp.Error, &allGood, &resp.Barcode)
 
 		if allGood {
 			err := newProduct.DbSave(r.Context(), nil)
-			if err != nil {
-				log.Println(err)
+			if errors.Is(err, db.DuplicateSkuOrBarcode) {
+				resp.Error += "Product with this SKU or barcode already exists. "
+			} else {
+				if err != nil {
+					log.Println(err)
+					http.Redirect(w, r, "/products", 301)
+					return
+				}
+
+				if err = db.PrefillProductCharacteristics(r.Context(), newProduct.Id, nil); err != nil {
+					log.Println(err)
+				}
+				search.ProductChanged(newProduct.Id)
+
+				http.Redirect(w, r, "/products/"+strconv.FormatInt(newProduct.Id, 10), 301)
+				return
 			}
-
-			http.Redirect(w, r, "/products", 301)
-			return
 		}
 	}
 
@@ -119,6 +211,26 @@ func ProductCreateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func getFormBarcode(r *http.Request, errorText *string, valid *bool, out *string) string {
+	value := r.FormValue("barcode")
+	if out != nil {
+		*out = value
+	}
+
+	if value == "" {
+		return ""
+	}
+
+	barcode, err := utils.NormalizeBarcode(value)
+	if err != nil {
+		*errorText += "\"barcode\" is not a valid EAN-13 or UPC-A code. "
+		*valid = false
+		return ""
+	}
+
+	return barcode
+}
+
 type EditProductTmplContext struct {
 	utils.BaseTmplContext
 
@@ -130,6 +242,11 @@ type EditProductTmplContext struct {
 	WarrantyDays string
 	CategoryId   string
 	CategoryName string
+	Sku          string
+	Barcode      string
+
+	ProductId int64
+	Images    []db.ProductImage
 
 	BackLocation string
 	Error        string
@@ -175,8 +292,14 @@ func ProductEditHandler(w http.ResponseWriter, r *http.Request) {
 		WarrantyDays: strconv.FormatInt(int64(product.WarrantyDays), 10),
 		CategoryId:   strconv.FormatInt(product.Category.Id, 10),
 		CategoryName: product.Category.Name,
+		Sku:          product.Sku,
+		Barcode:      product.Barcode,
+		ProductId:    product.Id,
 		BackLocation: backLocation,
 	}
+	if uploadError := r.URL.Query().Get("upload_error"); uploadError != "" && r.Method != "POST" {
+		resp.Error += "Failed to upload images (not an image or too large): " + uploadError + ". "
+	}
 
 	if r.Method == "POST" {
 		allGood := true
@@ -188,20 +311,35 @@ func ProductEditHandler(w http.ResponseWriter, r *http.Request) {
 		product.WarrantyDays = utils.GetFormInt(r, "warranty_days", &resp.Error, &allGood, &resp.ImageUrl)
 		product.ImageUrl = utils.GetFormString(r, "image_url", &resp.Error, &allGood, &resp.WarrantyDays)
 		product.Category.Id = utils.GetFormInt64(r, "category_id", &resp.Error, &allGood, &resp.CategoryId)
+		product.Sku = utils.GetFormString(r, "sku", &resp.Error, &allGood, &resp.Sku)
+		product.Barcode = getFormBarcode(r, &resp.Error, &allGood, &resp.Barcode)
 		resp.CategoryName = r.FormValue("_category_name")
 
 		if allGood {
 			err = product.DbSave(r.Context(), nil)
 			if err == nil {
+				if err = db.PrefillProductCharacteristics(r.Context(), product.Id, nil); err != nil {
+					log.Println(err)
+				}
+				search.ProductChanged(product.Id)
 				http.Redirect(w, r, backLocation, 301)
 				return
 			}
 
-			log.Println(err)
-			resp.Error += "Database error occurred. "
+			if errors.Is(err, db.DuplicateSkuOrBarcode) {
+				resp.Error += "Product with this SKU or barcode already exists. "
+			} else {
+				log.Println(err)
+				resp.Error += "Database error occurred. "
+			}
 		}
 	}
 
+	resp.Images, _, err = db.GetProductImages(product.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
 	tmpl, _ := template.ParseFiles("templates/products/edit.gohtml", "templates/layout.gohtml")
 	err = tmpl.Execute(w, resp)
 	if err != nil {
@@ -256,8 +394,18 @@ func ProductDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == "POST" {
+		images, _, err := db.GetProductImages(product.Id)
+		if utils.ReturnOnDatabaseError(err, w) {
+			return
+		}
+
 		err = product.DbDelete()
 		if err == nil {
+			for _, productImage := range images {
+				deleteProductImageFiles(productImage.FileName)
+			}
+			search.ProductChanged(product.Id)
+
 			http.Redirect(w, r, backLocation, 301)
 			return
 		}
@@ -273,11 +421,21 @@ func ProductDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+const recommendationsCount = 4
+
 type ProductWithCharacteristicsTmplContext struct {
 	utils.BaseTmplContext
 
 	Product         db.Product
+	CategoryPath    []db.Category
 	Characteristics []db.ProductCharacteristic
+	MissingRequired []db.Characteristic
+	Reviews         []db.ProductReview
+	ReviewMessage   string
+	AlsoBought      []db.Product
+	Variants        []db.ProductVariant
+	VariantAxes     []db.Characteristic
+	Images          []db.ProductImage
 }
 
 func ProductPageHandler(w http.ResponseWriter, r *http.Request) {
@@ -310,15 +468,65 @@ func ProductPageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	variants, _, err := db.GetProductVariants(product.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	variantAxes, err := db.GetProductVariantAxes(product.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	images, _, err := db.GetProductImages(product.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	var categoryPath []db.Category
+	if product.Category.Id != 0 {
+		categoryPath, err = db.GetCategoryPath(product.Category.Id)
+		if utils.ReturnOnDatabaseError(err, w) {
+			return
+		}
+	}
+
+	missingRequired, err := db.GetMissingRequiredCharacteristics(product.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	reviews, err := db.GetProductReviews(product.Id, db.ReviewApproved)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	alsoBought, err := db.GetRecommendedProducts([]int64{product.Id}, recommendationsCount)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	cartId := utils.GetCartId(r)
+	http.SetCookie(w, &http.Cookie{Name: "cartId", Value: cartId.String(), Path: "/", HttpOnly: true, MaxAge: 86400})
+	trackFunnelEvent(r, db.FunnelEvent{SessionId: cartId, Event: db.FunnelProductView, ProductId: product.Id})
+
 	resp := ProductWithCharacteristicsTmplContext{
 		BaseTmplContext: utils.BaseTmplContext{
 			Type: "products",
 		},
 		Product:         product,
+		CategoryPath:    categoryPath,
 		Characteristics: characteristics,
+		MissingRequired: missingRequired,
+		Reviews:         reviews,
+		ReviewMessage:   reviewMessages[r.URL.Query().Get("review")],
+		AlsoBought:      alsoBought,
+		Variants:        variants,
+		VariantAxes:     variantAxes,
+		Images:          images,
 	}
 
-	tmpl, _ := template.ParseFiles("templates/products/product.gohtml", "templates/layout.gohtml")
+	tmpl, _ := template.ParseFiles("templates/products/product.gohtml", "templates/layout.gohtml", "templates/products/recommendations.gohtml")
 	err = tmpl.Execute(w, resp)
 	if err != nil {
 		log.Println(err)
@@ -375,6 +583,13 @@ func ProductAddCharacteristicHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	charValue, err = characteristic.NormalizeValue(charValue)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf("Invalid value for \"%s\": %s!", characteristic.Name, err)))
+		return
+	}
+
 	productChar := db.ProductCharacteristic{
 		Id:             0,
 		ProductId:      product.Id,
@@ -382,6 +597,17 @@ func ProductAddCharacteristicHandler(w http.ResponseWriter, r *http.Request) {
 		Value:          charValue,
 	}
 
+	existingChars, _, err := db.GetProductCharacteristics(product.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	for _, existingChar := range existingChars {
+		if existingChar.Characteristic.Id == characteristic.Id && existingChar.Value == "" {
+			productChar.Id = existingChar.Id
+			break
+		}
+	}
+
 	err = productChar.DbSave()
 	if err != nil {
 		log.Println(err)
@@ -390,6 +616,8 @@ func ProductAddCharacteristicHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	search.ProductChanged(product.Id)
+
 	http.Redirect(w, r, "/products/"+productIdStr, 301)
 }
 
@@ -435,9 +663,45 @@ func ProductDeleteCharacteristicHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	search.ProductChanged(characteristic.ProductId)
+
 	http.Redirect(w, r, "/products/"+productIdStr, 301)
 }
 
+var variantRequired = errors.New("product variant is required")
+
+// getOrderedVariant returns the variant chosen in the variant_id form field.
+// Products with variants can only be ordered as one of their variants.
+func getOrderedVariant(r *http.Request, productId int64) (db.ProductVariant, error) {
+	variants, _, err := db.GetProductVariants(productId)
+	if err != nil || len(variants) == 0 {
+		return db.ProductVariant{}, err
+	}
+
+	variantGood := true
+	variantId := utils.GetFormInt64(r, "variant_id", nil, &variantGood, nil)
+	for _, variant := range variants {
+		if variantGood && variant.Id == variantId {
+			return variant, nil
+		}
+	}
+	return db.ProductVariant{}, variantRequired
+}
+
+func ProductVariantsSearchHandler(w http.ResponseWriter, r *http.Request) {
+	productId, _ := strconv.ParseInt(r.PathValue("productId"), 10, 64)
+	variants, _, _ := db.GetProductVariants(productId)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(variants) > 0 {
+		variantsJson, _ := json.Marshal(variants)
+		w.Write(variantsJson)
+	} else {
+		w.Write([]byte("[]"))
+	}
+}
+
 func ProductAddToCartHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		w.WriteHeader(405)
@@ -468,6 +732,16 @@ func ProductAddToCartHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	variant, err := getOrderedVariant(r, product.Id)
+	if errors.Is(err, variantRequired) {
+		w.WriteHeader(400)
+		w.Write([]byte("Choose a product variant!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
 	cartId := utils.GetCartId(r)
 	http.SetCookie(w, &http.Cookie{Name: "cartId", Value: cartId.String(), Path: "/", HttpOnly: true, MaxAge: 86400})
 	cart, err := db.GetOrCreateCart(cartId)
@@ -480,12 +754,13 @@ func ProductAddToCartHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cartProduct, err := db.GetCartProductByProductId(product.Id, cart.Id)
+	cartProduct, err := db.GetCartProductByProductId(product.Id, variant.Id, cart.Id)
 	if errors.Is(err, sql.ErrNoRows) {
 		cartProduct = db.CartProduct{
 			Id:       0,
 			CartId:   cartId,
 			Product:  product,
+			Variant:  variant,
 			Quantity: 1,
 		}
 	} else if utils.ReturnOnDatabaseError(err, w) {
@@ -495,6 +770,7 @@ func ProductAddToCartHandler(w http.ResponseWriter, r *http.Request) {
 	if utils.ReturnOnDatabaseError(cartProduct.DbSave(r.Context(), nil), w) {
 		return
 	}
+	trackFunnelEvent(r, db.FunnelEvent{SessionId: cart.Id, Event: db.FunnelAddToCart, ProductId: product.Id, Quantity: 1})
 
 	http.Redirect(w, r, backUrl, 301)
 }
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type PurchaseOrdersListTmplContext struct {
+	utils.BaseTmplContext
+
+	Orders     []db.PurchaseOrder
+	Pagination utils.PaginationInfo
+}
+
+func PurchaseOrdersListHandler(w http.ResponseWriter, r *http.Request) {
+	_, pageSize := utils.GetPageAndSize(r)
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	orders, count, err := db.GetPurchaseOrders(&cursorPage)
+
+	tmpl := template.New("list.gohtml")
+	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/purchase-orders/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, PurchaseOrdersListTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "purchase-orders",
+		},
+		Orders: orders,
+		Pagination: utils.PaginationInfo{
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/purchase-orders",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type CreatePurchaseOrderTmplContext struct {
+	utils.BaseTmplContext
+
+	SupplierId   string
+	SupplierName string
+
+	Error string
+}
+
+func PurchaseOrderCreateHandler(w http.ResponseWriter, r *http.Request) {
+	resp := CreatePurchaseOrderTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "purchase-orders",
+		},
+	}
+
+	if r.Method == "POST" {
+		allGood := true
+		var newOrder db.PurchaseOrder
+
+		newOrder.Supplier.Id = utils.GetFormInt64(r, "supplier_id", &resp.Error, &allGood, &resp.SupplierId)
+		resp.SupplierName = r.FormValue("_supplier_name")
+
+		if allGood {
+			_, err := db.GetSupplier(newOrder.Supplier.Id)
+			if errors.Is(err, sql.ErrNoRows) {
+				allGood = false
+				resp.Error += "Unknown supplier. "
+			} else if utils.ReturnOnDatabaseError(err, w) {
+				return
+			}
+		}
+
+		if allGood {
+			err := newOrder.DbSave(r.Context(), nil)
+			if err == nil {
+				http.Redirect(w, r, "/purchase-orders/"+strconv.FormatInt(newOrder.Id, 10), 301)
+				return
+			}
+
+			log.Println(err)
+			resp.Error += "Database error occurred. "
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/purchase-orders/create.gohtml", "templates/layout.gohtml")
+	err := tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type PurchaseOrderTmplContext struct {
+	utils.BaseTmplContext
+
+	Order        db.PurchaseOrder
+	BackLocation string
+	Error        string
+}
+
+func PurchaseOrderDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	backLocation := r.URL.Query().Get("back")
+	orderIdStr := r.PathValue("orderId")
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders", 301)
+		return
+	}
+
+	if backLocation == "order" {
+		backLocation = "/purchase-orders/" + orderIdStr
+	} else {
+		backLocation = "/purchase-orders"
+	}
+
+	order, err := db.GetPurchaseOrder(orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	resp := PurchaseOrderTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "purchase-orders",
+		},
+		Order:        order,
+		BackLocation: backLocation,
+		Error:        "",
+	}
+
+	if r.Method == "POST" {
+		err = order.DbDelete()
+		if err == nil {
+			http.Redirect(w, r, "/purchase-orders", 301)
+			return
+		}
+
+		log.Println(err)
+		resp.Error += "Database error occurred. "
+	}
+
+	tmpl, _ := template.ParseFiles("templates/purchase-orders/delete.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+var receiveErrors = map[string]string{
+	"invalid":  "Received quantity must be a positive number.",
+	"too_many": "Received quantity exceeds the pending quantity.",
+}
+
+type PurchaseOrderWithProductsTmplContext struct {
+	utils.BaseTmplContext
+
+	Order     db.PurchaseOrder
+	Products  []db.PurchaseOrderItem
+	OrderCost float64
+	Error     string
+}
+
+func PurchaseOrderPageHandler(w http.ResponseWriter, r *http.Request) {
+	orderIdStr := r.PathValue("orderId")
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders", 301)
+		return
+	}
+
+	order, err := db.GetPurchaseOrder(orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	items, _, err := db.GetPurchaseOrderItems(order.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	cost := 0.
+	for _, item := range items {
+		cost += float64(item.Quantity) * item.CostPerItem
+	}
+
+	tmpl, _ := template.ParseFiles("templates/purchase-orders/order.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, PurchaseOrderWithProductsTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "purchase-orders",
+		},
+		Order:     order,
+		Products:  items,
+		OrderCost: cost,
+		Error:     receiveErrors[r.URL.Query().Get("receive")],
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func PurchaseOrderAddProductHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	orderIdStr := r.PathValue("orderId")
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders", 301)
+		return
+	}
+
+	allGood := true
+	prodId := utils.GetFormInt64(r, "product_id", nil, &allGood, nil)
+	prodQuantity := utils.GetFormInt(r, "quantity", nil, &allGood, nil)
+
+	if !allGood || prodQuantity <= 0 {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+		return
+	}
+
+	order, err := db.GetPurchaseOrder(orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	if order.Status != db.PurchaseOrderDraft {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+		return
+	}
+
+	supplierProduct, err := db.GetSupplierProductByProductId(prodId, order.Supplier.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Supplier does not sell this product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	item := db.PurchaseOrderItem{
+		Id:              0,
+		PurchaseOrderId: order.Id,
+		Product:         supplierProduct.Product,
+		Quantity:        prodQuantity,
+		CostPerItem:     supplierProduct.CostPrice,
+	}
+
+	if utils.ReturnOnDatabaseError(item.DbSave(r.Context(), nil), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+}
+
+func PurchaseOrderDeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	orderIdStr := r.PathValue("orderId")
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders", 301)
+		return
+	}
+
+	itemIdStr := r.PathValue("itemId")
+	itemId, err := strconv.ParseInt(itemIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+		return
+	}
+
+	order, err := db.GetPurchaseOrder(orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	if order.Status != db.PurchaseOrderDraft {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+		return
+	}
+
+	item, err := db.GetPurchaseOrderItem(itemId, orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order item!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(item.DbDelete(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+}
+
+func PurchaseOrderSendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	orderIdStr := r.PathValue("orderId")
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders", 301)
+		return
+	}
+
+	order, err := db.GetPurchaseOrder(orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if order.Status == db.PurchaseOrderDraft {
+		order.Status = db.PurchaseOrderSent
+		if utils.ReturnOnDatabaseError(order.DbSave(r.Context(), nil), w) {
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+}
+
+func PurchaseOrderReceiveProductHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	orderIdStr := r.PathValue("orderId")
+	orderId, err := strconv.ParseInt(orderIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders", 301)
+		return
+	}
+
+	itemIdStr := r.PathValue("itemId")
+	itemId, err := strconv.ParseInt(itemIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+		return
+	}
+
+	allGood := true
+	quantity := utils.GetFormInt(r, "quantity", nil, &allGood, nil)
+	if !allGood {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr+"?receive=invalid", 301)
+		return
+	}
+
+	order, err := db.GetPurchaseOrder(orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	if order.Status != db.PurchaseOrderSent && order.Status != db.PurchaseOrderPartiallyReceived {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+		return
+	}
+
+	item, err := db.GetPurchaseOrderItem(itemId, orderId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown purchase order item!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	ctx := r.Context()
+
+	tx, err := db.BeginTx(ctx)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+	defer tx.Rollback()
+
+	err = item.Receive(ctx, quantity, tx)
+	if errors.Is(err, db.InvalidReceiveQuantity) {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr+"?receive=invalid", 301)
+		return
+	}
+	if errors.Is(err, db.TooManyReceived) {
+		http.Redirect(w, r, "/purchase-orders/"+orderIdStr+"?receive=too_many", 301)
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(order.UpdateReceivedStatus(ctx, tx), w) {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(tx.Commit(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/purchase-orders/"+orderIdStr, 301)
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-lb4/db"
+	"go-lb4/mailer"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var reportMailer = mailer.NewClient("127.0.0.1:1025", "", "", "Shop reports <reports@localhost>")
+
+type ReportEmailLegend struct {
+	Name  string
+	Color string
+}
+
+type ReportEmailChart struct {
+	Title  string
+	Src    template.URL
+	Top    float64
+	Legend []ReportEmailLegend
+}
+
+type ReportEmailTmplContext struct {
+	AnalysisReport
+
+	Schedule db.ReportSchedule
+	Filter   db.AnalysisFilter
+	Charts   []ReportEmailChart
+}
+
+type reportChartSeries struct {
+	utils.ChartSeries
+	name string
+}
+
+func buildReportEmail(schedule db.ReportSchedule, now time.Time, preview bool) (mailer.Message, error) {
+	filter := schedule.Period(now)
+	message := mailer.Message{
+		To: schedule.RecipientsList(),
+		Subject: fmt.Sprintf(
+			"%s: %s - %s", schedule.Name, filter.From.Format("02.01.2006"), filter.To.Format("02.01.2006"),
+		),
+	}
+
+	report, err := getAnalysisReport(filter)
+	if err != nil {
+		return message, err
+	}
+
+	context := ReportEmailTmplContext{
+		AnalysisReport: report,
+		Schedule:       schedule,
+		Filter:         filter,
+	}
+
+	for i, chart := range []struct {
+		title  string
+		series []reportChartSeries
+	}{
+		{"Customers per day", []reportChartSeries{
+			{ChartSeries: utils.ChartSeries{Values: statValues(report.CustomersPerDay), Color: utils.ChartBlue, Bars: true}, name: "This period"},
+			{ChartSeries: utils.ChartSeries{Values: statValues(report.PrevCustomersPerDay), Color: utils.ChartRed, Bars: true}, name: "Previous period"},
+		}},
+		{"Average order total per day", []reportChartSeries{
+			{ChartSeries: utils.ChartSeries{Values: statValues(report.AvgTotalPerDay), Color: utils.ChartBlue}, name: "This period"},
+			{ChartSeries: utils.ChartSeries{Values: statValues(report.PrevAvgTotalPerDay), Color: utils.ChartRed}, name: "Previous period"},
+		}},
+	} {
+		series := make([]utils.ChartSeries, len(chart.series))
+		legend := make([]ReportEmailLegend, len(chart.series))
+		for j, s := range chart.series {
+			series[j] = s.ChartSeries
+			legend[j] = ReportEmailLegend{Name: s.name, Color: fmt.Sprintf("#%02x%02x%02x", s.Color.R, s.Color.G, s.Color.B)}
+		}
+
+		image, err := utils.ChartPng(series, 560, 180)
+		if err != nil {
+			return message, err
+		}
+
+		src := template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(image))
+		if !preview {
+			contentId := fmt.Sprintf("chart%d@report", i)
+			message.Images = append(message.Images, mailer.InlineImage{ContentId: contentId, ContentType: "image/png", Data: image})
+			src = template.URL("cid:" + contentId)
+		}
+
+		context.Charts = append(context.Charts, ReportEmailChart{Title: chart.title, Src: src, Top: utils.ChartTop(series), Legend: legend})
+	}
+
+	tmpl, err := template.ParseFiles("templates/reports/email.gohtml")
+	if err != nil {
+		return message, err
+	}
+
+	var html bytes.Buffer
+	if err = tmpl.Execute(&html, context); err != nil {
+		return message, err
+	}
+	message.Html = html.String()
+
+	return message, nil
+}
+
+func sendReport(schedule db.ReportSchedule, now time.Time) error {
+	message, err := buildReportEmail(schedule, now, false)
+	if err == nil && len(message.To) == 0 {
+		err = errors.New("no recipients")
+	}
+	if err == nil {
+		err = reportMailer.Send(message)
+	}
+
+	delivery := db.ReportDelivery{
+		ScheduleId:   schedule.Id,
+		ScheduleName: schedule.Name,
+		Recipients:   strings.Join(message.To, ", "),
+		Subject:      message.Subject,
+		Status:       db.ReportDeliverySent,
+	}
+	if err != nil {
+		delivery.Status = db.ReportDeliveryFailed
+		delivery.Error = err.Error()
+	}
+
+	if logErr := db.CreateReportDelivery(&delivery); logErr != nil {
+		log.Printf("Failed to log report delivery: %s\n", logErr)
+	}
+
+	return err
+}
+
+func ReportSchedulesLoop(checkSec int) {
+	ticker := time.NewTicker(time.Duration(checkSec) * time.Second)
+
+	for {
+		<-ticker.C
+
+		now := time.Now()
+		schedules, err := db.GetDueReportSchedules(now)
+		if err != nil {
+			log.Printf("Failed to get due report schedules: %s\n", err)
+			continue
+		}
+
+		for _, schedule := range schedules {
+			log.Printf("Sending report \"%s\"\n", schedule.Name)
+			if err = sendReport(schedule, now); err != nil {
+				log.Printf("Failed to send report \"%s\": %s\n", schedule.Name, err)
+			}
+			if err = schedule.MarkSent(now); err != nil {
+				log.Printf("Failed to update report schedule \"%s\": %s\n", schedule.Name, err)
+			}
+		}
+	}
+}
+
+type ReportsListTmplContext struct {
+	utils.BaseTmplContext
+
+	Schedules  []db.ReportSchedule
+	Deliveries []db.ReportDelivery
+	Pagination utils.PaginationInfo
+	Message    string
+	Error      string
+}
+
+func ReportsListHandler(w http.ResponseWriter, r *http.Request) {
+	schedules, err := db.GetReportSchedules()
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	_, pageSize := utils.GetPageAndSize(r)
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	deliveries, count, err := db.GetReportDeliveries(&cursorPage)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	resp := ReportsListTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "reports",
+		},
+		Schedules:  schedules,
+		Deliveries: deliveries,
+		Pagination: utils.PaginationInfo{
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/reports",
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
+		},
+	}
+	switch r.URL.Query().Get("sent") {
+	case "ok":
+		resp.Message = "The report was sent."
+	case "failed":
+		resp.Error = "The report could not be sent, see the delivery log below."
+	}
+
+	tmpl := template.New("list.gohtml")
+	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/reports/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type ReportScheduleFormTmplContext struct {
+	utils.BaseTmplContext
+
+	Name        string
+	Frequency   string
+	Recipients  string
+	Hour        string
+	Enabled     bool
+	Frequencies []string
+
+	Error string
+}
+
+func getReportScheduleForm(r *http.Request, schedule *db.ReportSchedule, resp *ReportScheduleFormTmplContext) bool {
+	allGood := true
+
+	schedule.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
+	schedule.Recipients = utils.GetFormStringNonEmpty(r, "recipients", &resp.Error, &allGood, &resp.Recipients)
+	schedule.Hour = utils.GetFormInt(r, "hour", &resp.Error, &allGood, &resp.Hour)
+	schedule.Frequency = r.FormValue("frequency")
+	schedule.Enabled = r.FormValue("enabled") == "1"
+	resp.Frequency = schedule.Frequency
+	resp.Enabled = schedule.Enabled
+
+	if !slices.Contains(db.ReportFrequencies, schedule.Frequency) {
+		resp.Error += "Unknown frequency. "
+		allGood = false
+	}
+	if schedule.Hour < 0 || schedule.Hour > 23 {
+		resp.Error += "Hour must be between 0 and 23. "
+		allGood = false
+	}
+	for _, recipient := range schedule.RecipientsList() {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			resp.Error += "Invalid email \"" + recipient + "\". "
+			allGood = false
+		}
+	}
+
+	return allGood
+}
+
+func ReportCreateHandler(w http.ResponseWriter, r *http.Request) {
+	resp := ReportScheduleFormTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "reports",
+		},
+		Frequency:   db.ReportWeekly,
+		Hour:        "8",
+		Enabled:     true,
+		Frequencies: db.ReportFrequencies,
+	}
+
+	if r.Method == "POST" {
+		var schedule db.ReportSchedule
+		if getReportScheduleForm(r, &schedule, &resp) {
+			err := schedule.DbSave()
+			if err == nil {
+				http.Redirect(w, r, "/reports", 301)
+				return
+			}
+
+			log.Println(err)
+			resp.Error += "Database error occurred. "
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/reports/create.gohtml", "templates/layout.gohtml")
+	err := tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func getReportScheduleFromPath(w http.ResponseWriter, r *http.Request) (db.ReportSchedule, bool) {
+	scheduleId, err := strconv.ParseInt(r.PathValue("scheduleId"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/reports", 301)
+		return db.ReportSchedule{}, false
+	}
+
+	schedule, err := db.GetReportSchedule(scheduleId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown report schedule!"))
+		return schedule, false
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return schedule, false
+	}
+
+	return schedule, true
+}
+
+func ReportEditHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := getReportScheduleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	resp := ReportScheduleFormTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "reports",
+		},
+		Name:        schedule.Name,
+		Frequency:   schedule.Frequency,
+		Recipients:  schedule.Recipients,
+		Hour:        strconv.Itoa(schedule.Hour),
+		Enabled:     schedule.Enabled,
+		Frequencies: db.ReportFrequencies,
+	}
+
+	if r.Method == "POST" {
+		if getReportScheduleForm(r, &schedule, &resp) {
+			err := schedule.DbSave()
+			if err == nil {
+				http.Redirect(w, r, "/reports", 301)
+				return
+			}
+
+			log.Println(err)
+			resp.Error += "Database error occurred. "
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/reports/edit.gohtml", "templates/layout.gohtml")
+	err := tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func ReportDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	schedule, ok := getReportScheduleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(schedule.DbDelete(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/reports", 301)
+}
+
+func ReportPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, ok := getReportScheduleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	message, err := buildReportEmail(schedule, time.Now(), true)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(message.Html))
+}
+
+func ReportSendHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	schedule, ok := getReportScheduleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := sendReport(schedule, time.Now()); err != nil {
+		log.Printf("Failed to send report \"%s\": %s\n", schedule.Name, err)
+		http.Redirect(w, r, "/reports?sent=failed", 301)
+		return
+	}
+
+	http.Redirect(w, r, "/reports?sent=ok", 301)
+}
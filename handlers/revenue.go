@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+)
+
+const (
+	defaultRevenueLimit = 10
+	paretoChartLimit    = 50
+	sparklineWidth      = 120
+	sparklineHeight     = 24
+)
+
+// Products bringing the first 80% of revenue are class A, the next 15% class B and the rest class C.
+const (
+	abcClassALimit = 0.8
+	abcClassBLimit = 0.95
+)
+
+type RevenueRow struct {
+	db.RevenueGroup
+	Share     float64
+	Class     string
+	Sparkline string
+}
+
+type RevenueTable struct {
+	Grouping      string
+	SparklineSize [2]int
+	Rows          []RevenueRow
+}
+
+type AbcClass struct {
+	Class    string
+	Products int
+	Revenue  float64
+	Share    float64
+}
+
+type ParetoPoint struct {
+	Name       string
+	Revenue    float64
+	Cumulative float64
+}
+
+type AnalysisRevenueTmplContext struct {
+	utils.BaseTmplContext
+	AnalysisFilterContext
+
+	Grouping  string
+	Groupings []string
+	Limit     int
+
+	Total        db.RevenueGroup
+	GroupsCount  int
+	TopGroups    RevenueTable
+	BottomGroups RevenueTable
+	AbcClasses   []AbcClass
+	Pareto       []ParetoPoint
+}
+
+// abcClassify returns the ABC class of every product, products must be sorted by revenue descending.
+func abcClassify(products []db.RevenueGroup, total float64) map[string]string {
+	classes := make(map[string]string, len(products))
+	var cumulative float64
+	for _, product := range products {
+		// A product is classified by the share reached before it, so the top seller is always in class A
+		share := 0.0
+		if total > 0 {
+			share = cumulative / total
+		}
+		switch {
+		case share < abcClassALimit:
+			classes[product.Key] = "A"
+		case share < abcClassBLimit:
+			classes[product.Key] = "B"
+		default:
+			classes[product.Key] = "C"
+		}
+		cumulative += product.Revenue
+	}
+	return classes
+}
+
+func revenueTable(grouping string, groups []db.RevenueGroup, total float64, classes map[string]string, filter db.AnalysisFilter) RevenueTable {
+	rows := make([]RevenueRow, len(groups))
+	for i, group := range groups {
+		daily := fillMissingDates(group.Daily, filter)
+		values := make([]float64, len(daily))
+		for j, day := range daily {
+			values[j] = day.Value
+		}
+
+		rows[i] = RevenueRow{
+			RevenueGroup: group,
+			Class:        classes[group.Key],
+			Sparkline:    utils.SparklinePoints(values, sparklineWidth, sparklineHeight),
+		}
+		if total > 0 {
+			rows[i].Share = group.Revenue / total
+		}
+	}
+	return RevenueTable{Grouping: grouping, SparklineSize: [2]int{sparklineWidth, sparklineHeight}, Rows: rows}
+}
+
+func AnalysisRevenueHandler(w http.ResponseWriter, r *http.Request) {
+	filterContext, ok := getAnalysisFilterContext(w, r)
+	if !ok {
+		return
+	}
+	filter := filterContext.Filter
+
+	grouping := r.URL.Query().Get("group")
+	if !slices.Contains(db.RevenueGroupings, grouping) {
+		grouping = db.RevenueByCategory
+	}
+	limit := getIntParam(r, "limit", defaultRevenueLimit, 1, 100)
+
+	groups, err := db.GetRevenueGroups(filter, grouping)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	products := groups
+	if grouping != db.RevenueByProduct {
+		products, err = db.GetRevenueGroups(filter, db.RevenueByProduct)
+		if utils.ReturnOnDatabaseError(err, w) {
+			return
+		}
+	}
+
+	context := AnalysisRevenueTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "analysis-revenue",
+		},
+		AnalysisFilterContext: filterContext,
+		Grouping:              grouping,
+		Groupings:             db.RevenueGroupings,
+		Limit:                 limit,
+		GroupsCount:           len(groups),
+	}
+
+	for _, group := range groups {
+		context.Total.Units += group.Units
+		context.Total.Revenue += group.Revenue
+		context.Total.Cost += group.Cost
+		context.Total.CostedRevenue += group.CostedRevenue
+	}
+
+	productClasses := abcClassify(products, context.Total.Revenue)
+	var classes map[string]string
+	if grouping == db.RevenueByProduct {
+		classes = productClasses
+	}
+
+	top := groups[:min(len(groups), limit)]
+	context.TopGroups = revenueTable(grouping, top, context.Total.Revenue, classes, filter)
+	if len(groups) > limit {
+		bottom := slices.Clone(groups[max(len(groups)-limit, limit):])
+		slices.Reverse(bottom)
+		context.BottomGroups = revenueTable(grouping, bottom, context.Total.Revenue, classes, filter)
+	}
+
+	context.AbcClasses = []AbcClass{{Class: "A"}, {Class: "B"}, {Class: "C"}}
+	var cumulative float64
+	for i, product := range products {
+		class := &context.AbcClasses[slices.IndexFunc(context.AbcClasses, func(class AbcClass) bool {
+			return class.Class == productClasses[product.Key]
+		})]
+		class.Products++
+		class.Revenue += product.Revenue
+
+		cumulative += product.Revenue
+		if i < paretoChartLimit {
+			context.Pareto = append(context.Pareto, ParetoPoint{Name: product.Name, Revenue: product.Revenue, Cumulative: cumulative / context.Total.Revenue})
+		}
+	}
+	for i := range context.AbcClasses {
+		if context.Total.Revenue > 0 {
+			context.AbcClasses[i].Share = context.AbcClasses[i].Revenue / context.Total.Revenue
+		}
+	}
+
+	tmpl := template.New("analysis-revenue.gohtml")
+	_, err = tmpl.Funcs(analysisTmplFuncs).ParseFiles("templates/analysis-revenue.gohtml", "templates/layout.gohtml", "templates/analysis-filter.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, context)
+	if err != nil {
+		log.Println(err)
+	}
+}
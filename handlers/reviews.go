@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const maxReviewTitleLength = 200
+
+var reviewMessages = map[string]string{
+	"submitted":    "Thank you! Your review will be published after moderation.",
+	"not_verified": "Only customers with a completed order containing this product can review it. Check your email and order number.",
+	"duplicate":    "You have already reviewed this product.",
+	"invalid":      "Please fill in the rating (1-5), title (up to 200 characters), email and order number.",
+}
+
+func ProductAddReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	productIdStr := r.PathValue("productId")
+	productId, err := strconv.ParseInt(productIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/products", 301)
+		return
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	allGood := true
+	review := db.ProductReview{ProductId: product.Id}
+	review.Customer.Email = utils.GetFormStringNonEmpty(r, "email", nil, &allGood, nil)
+	review.OrderId = utils.GetFormInt64(r, "order_id", nil, &allGood, nil)
+	review.Rating = utils.GetFormInt(r, "rating", nil, &allGood, nil)
+	review.Title = utils.GetFormStringNonEmpty(r, "title", nil, &allGood, nil)
+	review.Text = utils.GetFormString(r, "text", nil, &allGood, nil)
+
+	result := "submitted"
+	if !allGood || review.Rating < 1 || review.Rating > 5 || len([]rune(review.Title)) > maxReviewTitleLength {
+		result = "invalid"
+	} else if err = db.CreateProductReview(&review); errors.Is(err, db.NotVerifiedPurchase) {
+		result = "not_verified"
+	} else if errors.Is(err, db.DuplicateReview) {
+		result = "duplicate"
+	} else if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	http.Redirect(w, r, "/products/"+productIdStr+"?review="+result+"#reviews", 301)
+}
+
+type ReviewsListTmplContext struct {
+	utils.BaseTmplContext
+
+	Status     string
+	Statuses   []string
+	Reviews    []db.ProductReview
+	Pagination utils.PaginationInfo
+}
+
+func ReviewsListHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if !slices.Contains(db.ReviewStatuses, status) {
+		status = db.ReviewPending
+	}
+
+	_, pageSize := utils.GetPageAndSize(r)
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	reviews, count, err := db.GetReviewsByStatus(&cursorPage, status)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	tmpl := template.New("list.gohtml")
+	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/reviews/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, ReviewsListTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "reviews",
+		},
+		Status:   status,
+		Statuses: db.ReviewStatuses,
+		Reviews:  reviews,
+		Pagination: utils.PaginationInfo{
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/reviews",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func getReviewFromPath(w http.ResponseWriter, r *http.Request) (db.ProductReview, bool) {
+	reviewId, err := strconv.ParseInt(r.PathValue("reviewId"), 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/reviews", 301)
+		return db.ProductReview{}, false
+	}
+
+	review, err := db.GetProductReview(reviewId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown review!"))
+		return review, false
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return review, false
+	}
+
+	return review, true
+}
+
+func ReviewSetStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	review, ok := getReviewFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.FormValue("status")
+	if !slices.Contains(db.ReviewStatuses, status) {
+		w.WriteHeader(400)
+		w.Write([]byte("Unknown review status!"))
+		return
+	}
+
+	previousStatus := review.Status
+	if utils.ReturnOnDatabaseError(review.SetStatus(r.Context(), status), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/reviews?status="+previousStatus, 301)
+}
+
+func ReviewDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	review, ok := getReviewFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(review.DbDelete(r.Context()), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/reviews?status="+review.Status, 301)
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-lb4/db"
+	"go-lb4/utils"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type SuppliersListTmplContext struct {
+	utils.BaseTmplContext
+
+	Suppliers  []db.Supplier
+	Pagination utils.PaginationInfo
+}
+
+func SuppliersListHandler(w http.ResponseWriter, r *http.Request) {
+	_, pageSize := utils.GetPageAndSize(r)
+	cursorPage := db.CursorPage{
+		PageSize: pageSize,
+		After:    r.URL.Query().Get("after"),
+		Before:   r.URL.Query().Get("before"),
+	}
+	suppliers, count, err := db.GetSuppliers(&cursorPage)
+
+	tmpl := template.New("list.gohtml")
+	_, err = tmpl.Funcs(utils.TmplPaginationFuncs).ParseFiles("templates/suppliers/list.gohtml", "templates/layout.gohtml", "templates/pagination.gohtml")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = tmpl.Execute(w, SuppliersListTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "suppliers",
+		},
+		Suppliers: suppliers,
+		Pagination: utils.PaginationInfo{
+			PageSize:   pageSize,
+			Count:      count,
+			UrlPath:    "/suppliers",
+			Query:      r.URL.RawQuery,
+			CursorMode: true,
+			PrevCursor: cursorPage.PrevCursor,
+			NextCursor: cursorPage.NextCursor,
+		},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func SuppliersSearchHandler(w http.ResponseWriter, r *http.Request) {
+	var suppliers []db.Supplier
+
+	namePart := r.URL.Query().Get("name")
+	if namePart != "" {
+		_, pageSize := utils.GetPageAndSize(r)
+		suppliers, _ = db.SearchSuppliers(namePart, pageSize)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(suppliers) > 0 {
+		suppliersJson, _ := json.Marshal(suppliers)
+		w.Write(suppliersJson)
+	} else {
+		w.Write([]byte("[]"))
+	}
+}
+
+type CreateSupplierTmplContext struct {
+	utils.BaseTmplContext
+
+	Name  string
+	Email string
+	Phone string
+
+	Error string
+}
+
+func SupplierCreateHandler(w http.ResponseWriter, r *http.Request) {
+	resp := CreateSupplierTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "suppliers",
+		},
+	}
+
+	if r.Method == "POST" {
+		allGood := true
+		var newSupplier db.Supplier
+
+		newSupplier.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
+		newSupplier.Email = utils.GetFormString(r, "email", &resp.Error, &allGood, &resp.Email)
+		newSupplier.Phone = utils.GetFormString(r, "phone", &resp.Error, &allGood, &resp.Phone)
+
+		if allGood {
+			err := newSupplier.DbSave()
+			if err != nil {
+				log.Println(err)
+			}
+
+			http.Redirect(w, r, "/suppliers", 301)
+			return
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/suppliers/create.gohtml", "templates/layout.gohtml")
+	err := tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type EditSupplierTmplContext struct {
+	utils.BaseTmplContext
+
+	Name  string
+	Email string
+	Phone string
+
+	BackLocation string
+	Error        string
+}
+
+func SupplierEditHandler(w http.ResponseWriter, r *http.Request) {
+	backLocation := r.URL.Query().Get("back")
+	supplierIdStr := r.PathValue("supplierId")
+	supplierId, err := strconv.ParseInt(supplierIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/suppliers", 301)
+		return
+	}
+
+	if backLocation == "supplier" {
+		backLocation = "/suppliers/" + supplierIdStr
+	} else {
+		backLocation = "/suppliers"
+	}
+
+	supplier, err := db.GetSupplier(supplierId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown supplier!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	resp := EditSupplierTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "suppliers",
+		},
+		Name:         supplier.Name,
+		Email:        supplier.Email,
+		Phone:        supplier.Phone,
+		BackLocation: backLocation,
+	}
+
+	if r.Method == "POST" {
+		allGood := true
+
+		supplier.Name = utils.GetFormStringNonEmpty(r, "name", &resp.Error, &allGood, &resp.Name)
+		supplier.Email = utils.GetFormString(r, "email", &resp.Error, &allGood, &resp.Email)
+		supplier.Phone = utils.GetFormString(r, "phone", &resp.Error, &allGood, &resp.Phone)
+
+		if allGood {
+			err = supplier.DbSave()
+			if err == nil {
+				http.Redirect(w, r, backLocation, 301)
+				return
+			}
+
+			log.Println(err)
+			resp.Error += "Database error occurred. "
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/suppliers/edit.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type SupplierTmplContext struct {
+	utils.BaseTmplContext
+
+	Supplier     db.Supplier
+	BackLocation string
+	Error        string
+}
+
+func SupplierDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	supplierIdStr := r.PathValue("supplierId")
+	supplierId, err := strconv.ParseInt(supplierIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/suppliers", 301)
+		return
+	}
+
+	supplier, err := db.GetSupplier(supplierId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown supplier!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	resp := SupplierTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "suppliers",
+		},
+		Supplier:     supplier,
+		BackLocation: "/suppliers",
+		Error:        "",
+	}
+
+	if r.Method == "POST" {
+		err = supplier.DbDelete()
+		if err == nil {
+			http.Redirect(w, r, "/suppliers", 301)
+			return
+		}
+
+		if errors.Is(err, db.SupplierHasPurchaseOrders) {
+			resp.Error += "Supplier has purchase orders, delete them first. "
+		} else {
+			log.Println(err)
+			resp.Error += "Database error occurred. "
+		}
+	}
+
+	tmpl, _ := template.ParseFiles("templates/suppliers/delete.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, resp)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+type SupplierWithProductsTmplContext struct {
+	utils.BaseTmplContext
+
+	Supplier db.Supplier
+	Products []db.SupplierProduct
+}
+
+func SupplierPageHandler(w http.ResponseWriter, r *http.Request) {
+	supplierIdStr := r.PathValue("supplierId")
+	supplierId, err := strconv.ParseInt(supplierIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/suppliers", 301)
+		return
+	}
+
+	supplier, err := db.GetSupplier(supplierId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown supplier!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	products, _, err := db.GetSupplierProducts(supplier.Id)
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	tmpl, _ := template.ParseFiles("templates/suppliers/supplier.gohtml", "templates/layout.gohtml")
+	err = tmpl.Execute(w, SupplierWithProductsTmplContext{
+		BaseTmplContext: utils.BaseTmplContext{
+			Type: "suppliers",
+		},
+		Supplier: supplier,
+		Products: products,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func SupplierAddProductHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	supplierIdStr := r.PathValue("supplierId")
+	supplierId, err := strconv.ParseInt(supplierIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/suppliers", 301)
+		return
+	}
+
+	allGood := true
+	productId := utils.GetFormInt64(r, "product_id", nil, &allGood, nil)
+	costPrice := utils.GetFormDouble(r, "cost_price", nil, &allGood, nil)
+
+	if !allGood || costPrice < 0 {
+		http.Redirect(w, r, "/suppliers/"+supplierIdStr, 301)
+		return
+	}
+
+	supplier, err := db.GetSupplier(supplierId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown supplier!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	product, err := db.GetProduct(productId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	item := db.SupplierProduct{
+		Id:         0,
+		SupplierId: supplier.Id,
+		Product:    product,
+		CostPrice:  costPrice,
+	}
+
+	if utils.ReturnOnDatabaseError(item.DbSave(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/suppliers/"+supplierIdStr, 301)
+}
+
+func SupplierDeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		w.Write([]byte("Method is not allowed!"))
+		return
+	}
+
+	supplierIdStr := r.PathValue("supplierId")
+	supplierId, err := strconv.ParseInt(supplierIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/suppliers", 301)
+		return
+	}
+
+	itemIdStr := r.PathValue("itemId")
+	itemId, err := strconv.ParseInt(itemIdStr, 10, 64)
+	if err != nil {
+		http.Redirect(w, r, "/suppliers/"+supplierIdStr, 301)
+		return
+	}
+
+	item, err := db.GetSupplierProduct(itemId, supplierId)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(404)
+		w.Write([]byte("Unknown supplier product!"))
+		return
+	}
+	if utils.ReturnOnDatabaseError(err, w) {
+		return
+	}
+
+	if utils.ReturnOnDatabaseError(item.DbDelete(), w) {
+		return
+	}
+
+	http.Redirect(w, r, "/suppliers/"+supplierIdStr, 301)
+}
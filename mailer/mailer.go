@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	addr     string
+	username string
+	password string
+	from     string
+}
+
+func NewClient(addr, username, password, from string) Client {
+	return Client{
+		addr:     addr,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+type InlineImage struct {
+	ContentId   string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	To      []string
+	Subject string
+	Html    string
+	Images  []InlineImage
+}
+
+func writeBase64Lines(buffer *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buffer.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buffer.WriteString(encoded + "\r\n")
+}
+
+// Build renders the message as multipart/related MIME, so the HTML can reference images as "cid:<ContentId>".
+func (client *Client) Build(message Message) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	htmlPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	htmlWriter := quotedprintable.NewWriter(htmlPart)
+	if _, err = htmlWriter.Write([]byte(message.Html)); err != nil {
+		return nil, err
+	}
+	if err = htmlWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, image := range message.Images {
+		imagePart, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {image.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Id":                {"<" + image.ContentId + ">"},
+			"Content-Disposition":       {"inline; filename=\"" + image.ContentId + "\""},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var encoded bytes.Buffer
+		writeBase64Lines(&encoded, image.Data)
+		if _, err = imagePart.Write(encoded.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	fmt.Fprintf(&data, "From: %s\r\n", client.from)
+	fmt.Fprintf(&data, "To: %s\r\n", strings.Join(message.To, ", "))
+	fmt.Fprintf(&data, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&data, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&data, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&data, "Content-Type: multipart/related; boundary=%q; type=\"text/html\"\r\n\r\n", writer.Boundary())
+	data.Write(body.Bytes())
+
+	return data.Bytes(), nil
+}
+
+func (client *Client) Send(message Message) error {
+	data, err := client.Build(message)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if client.username != "" {
+		host, _, _ := net.SplitHostPort(client.addr)
+		auth = smtp.PlainAuth("", client.username, client.password, host)
+	}
+
+	return smtp.SendMail(client.addr, auth, client.from, message.To, data)
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"go-lb4/db"
 	"go-lb4/handlers"
+	"go-lb4/search"
+	"go-lb4/seed"
+	"log"
 	"net/http"
+	"os"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -36,33 +43,103 @@ import (
   - комбінації товарів, що найчастіше зустрічаються,
   - найрідше зустрічаються комбінації товарів
 */
+
+// seedCommand generates the data set for analysis, e.g. "go run . seed -days 180 -seed 42".
+func seedCommand(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	seedValue := flags.Uint64("seed", 1, "random seed, the same seed generates the same data")
+	to := flags.String("to", time.Now().Format(time.DateOnly), "last day of generated orders")
+	days := flags.Int("days", 90, "number of days with orders")
+	products := flags.Int("products", 8, "number of products per category")
+	customers := flags.Int("customers", 150, "number of customers")
+	ordersPerDay := flags.Float64("orders-per-day", 6, "average number of orders per day")
+	_ = flags.Parse(args)
+
+	toDate, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		log.Fatalf("Invalid -to date: %s\n", err)
+	}
+
+	result, err := seed.Generate(context.Background(), seed.Options{
+		Seed:         *seedValue,
+		To:           toDate,
+		Days:         *days,
+		Products:     *products,
+		Customers:    *customers,
+		OrdersPerDay: *ordersPerDay,
+	})
+	fmt.Printf(
+		"Created %d categories, %d characteristics, %d products, %d customers, %d orders with %d items\n",
+		result.Categories, result.Characteristics, result.Products, result.Customers, result.Orders, result.OrderItems,
+	)
+	if err != nil {
+		log.Fatalf("Failed to generate data: %s\n", err)
+	}
+}
+
 func main() {
 	db.InitDatabase("mysql", "nure_golang_pz3:123456789@tcp(127.0.0.1:3306)/nure_golang_pz3?parseTime=true")
 	defer db.CloseDatabase()
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		seedCommand(os.Args[2:])
+		return
+	}
 	go func() {
 		db.CleanOldCartsLoop(10)
 	}()
+	go func() {
+		search.IndexLoop(600)
+	}()
+	go func() {
+		db.RecommendationsLoop(3600)
+	}()
+	go func() {
+		handlers.ReportSchedulesLoop(60)
+	}()
+	go func() {
+		db.DailySalesLoop(3)
+	}()
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/catalog", 301)
 	})
 
 	http.HandleFunc("/catalog", handlers.ProductCatalogHandler)
+	http.HandleFunc("/suggest", handlers.SuggestHandler)
+	http.HandleFunc("/images/{size}/{fileName}", handlers.ImageHandler)
 
 	http.HandleFunc("/products", handlers.ProductsListHandler)
 	http.HandleFunc("/products/create", handlers.ProductCreateHandler)
 	http.HandleFunc("/products/search", handlers.ProductsSearchHandler)
+	http.HandleFunc("/products/lookup", handlers.ProductLookupHandler)
+	http.HandleFunc("/products/export", handlers.ProductsExportHandler)
+	http.HandleFunc("/products/import", handlers.ProductsImportHandler)
 	http.HandleFunc("/products/{productId}/edit", handlers.ProductEditHandler)
 	http.HandleFunc("/products/{productId}/delete", handlers.ProductDeleteHandler)
 	http.HandleFunc("/products/{productId}", handlers.ProductPageHandler)
 	http.HandleFunc("/products/{productId}/characteristics", handlers.ProductAddCharacteristicHandler)
 	http.HandleFunc("/products/{productId}/characteristics/{characteristicId}/delete", handlers.ProductDeleteCharacteristicHandler)
 	http.HandleFunc("/products/{productId}/add-to-cart", handlers.ProductAddToCartHandler)
+	http.HandleFunc("/products/{productId}/barcode", handlers.ProductBarcodeHandler)
+	http.HandleFunc("/products/{productId}/reviews", handlers.ProductAddReviewHandler)
+	http.HandleFunc("/products/{productId}/images", handlers.ProductUploadImagesHandler)
+	http.HandleFunc("/products/{productId}/images/{imageId}/delete", handlers.ProductImageDeleteHandler)
+	http.HandleFunc("/products/{productId}/images/{imageId}/primary", handlers.ProductImageSetPrimaryHandler)
+	http.HandleFunc("/products/{productId}/images/{imageId}/move", handlers.ProductImageMoveHandler)
+	http.HandleFunc("/products/{productId}/variants", handlers.ProductAddVariantHandler)
+	http.HandleFunc("/products/{productId}/variants/search", handlers.ProductVariantsSearchHandler)
+	http.HandleFunc("/products/{productId}/variants/{variantId}/edit", handlers.ProductVariantEditHandler)
+	http.HandleFunc("/products/{productId}/variants/{variantId}/delete", handlers.ProductVariantDeleteHandler)
+	http.HandleFunc("/products/{productId}/variants/{variantId}/values", handlers.ProductVariantAddValueHandler)
+	http.HandleFunc("/products/{productId}/variants/{variantId}/values/{valueId}/delete", handlers.ProductVariantDeleteValueHandler)
 
 	http.HandleFunc("/categories", handlers.CategoriesListHandler)
 	http.HandleFunc("/categories/create", handlers.CategoryCreateHandler)
 	http.HandleFunc("/categories/{categoryId}/edit", handlers.CategoryEditHandler)
 	http.HandleFunc("/categories/{categoryId}/delete", handlers.CategoryDeleteHandler)
+	http.HandleFunc("/categories/{categoryId}/characteristics", handlers.CategoryAddCharacteristicHandler)
+	http.HandleFunc("/categories/{categoryId}/characteristics/prefill", handlers.CategoryPrefillCharacteristicsHandler)
+	http.HandleFunc("/categories/{categoryId}/characteristics/{characteristicId}/delete", handlers.CategoryDeleteCharacteristicHandler)
 	http.HandleFunc("/categories/search", handlers.CategoriesSearchHandler)
 
 	http.HandleFunc("/characteristics", handlers.CharacteristicsListHandler)
@@ -86,7 +163,44 @@ func main() {
 	http.HandleFunc("/orders/{orderId}/products/{itemId}/delete", handlers.OrderDeleteProductHandler)
 	http.HandleFunc("/orders/{orderId}/finish-payment", handlers.OrderFinishPaymentHandler)
 
+	http.HandleFunc("/suppliers", handlers.SuppliersListHandler)
+	http.HandleFunc("/suppliers/create", handlers.SupplierCreateHandler)
+	http.HandleFunc("/suppliers/search", handlers.SuppliersSearchHandler)
+	http.HandleFunc("/suppliers/{supplierId}", handlers.SupplierPageHandler)
+	http.HandleFunc("/suppliers/{supplierId}/edit", handlers.SupplierEditHandler)
+	http.HandleFunc("/suppliers/{supplierId}/delete", handlers.SupplierDeleteHandler)
+	http.HandleFunc("/suppliers/{supplierId}/products", handlers.SupplierAddProductHandler)
+	http.HandleFunc("/suppliers/{supplierId}/products/{itemId}/delete", handlers.SupplierDeleteProductHandler)
+
+	http.HandleFunc("/purchase-orders", handlers.PurchaseOrdersListHandler)
+	http.HandleFunc("/purchase-orders/create", handlers.PurchaseOrderCreateHandler)
+	http.HandleFunc("/purchase-orders/{orderId}", handlers.PurchaseOrderPageHandler)
+	http.HandleFunc("/purchase-orders/{orderId}/delete", handlers.PurchaseOrderDeleteHandler)
+	http.HandleFunc("/purchase-orders/{orderId}/send", handlers.PurchaseOrderSendHandler)
+	http.HandleFunc("/purchase-orders/{orderId}/products", handlers.PurchaseOrderAddProductHandler)
+	http.HandleFunc("/purchase-orders/{orderId}/products/{itemId}/delete", handlers.PurchaseOrderDeleteProductHandler)
+	http.HandleFunc("/purchase-orders/{orderId}/products/{itemId}/receive", handlers.PurchaseOrderReceiveProductHandler)
+
+	http.HandleFunc("/reviews", handlers.ReviewsListHandler)
+	http.HandleFunc("/reviews/{reviewId}/status", handlers.ReviewSetStatusHandler)
+	http.HandleFunc("/reviews/{reviewId}/delete", handlers.ReviewDeleteHandler)
+
 	http.HandleFunc("/analysis", handlers.ProductsAnalysisHandler)
+	http.HandleFunc("/analysis/rules", handlers.AnalysisRulesHandler)
+	http.HandleFunc("/analysis/export", handlers.AnalysisExportHandler)
+	http.HandleFunc("/analysis/customers", handlers.AnalysisCustomersHandler)
+	http.HandleFunc("/analysis/customers/rfm", handlers.AnalysisRfmHandler)
+	http.HandleFunc("/analysis/customers/cohorts", handlers.AnalysisCohortsHandler)
+	http.HandleFunc("/analysis/forecast", handlers.AnalysisForecastHandler)
+	http.HandleFunc("/analysis/revenue", handlers.AnalysisRevenueHandler)
+	http.HandleFunc("/analysis/funnel", handlers.AnalysisFunnelHandler)
+
+	http.HandleFunc("/reports", handlers.ReportsListHandler)
+	http.HandleFunc("/reports/create", handlers.ReportCreateHandler)
+	http.HandleFunc("/reports/{scheduleId}/edit", handlers.ReportEditHandler)
+	http.HandleFunc("/reports/{scheduleId}/delete", handlers.ReportDeleteHandler)
+	http.HandleFunc("/reports/{scheduleId}/preview", handlers.ReportPreviewHandler)
+	http.HandleFunc("/reports/{scheduleId}/send", handlers.ReportSendHandler)
 
 	http.HandleFunc("/cart", handlers.CartProductsListHandler)
 	http.HandleFunc("/cart/{itemId}/edit", handlers.CartProductEditHandler)
@@ -94,6 +208,11 @@ func main() {
 	http.HandleFunc("/cart/payment", handlers.CartPaymentHandler)
 	http.HandleFunc("/cart/remove-old", handlers.RemoveOldCartsHandler)
 
+	http.HandleFunc("/compare", handlers.CompareHandler)
+	http.HandleFunc("/compare/clear", handlers.CompareClearHandler)
+	http.HandleFunc("/compare/{productId}/add", handlers.CompareAddHandler)
+	http.HandleFunc("/compare/{productId}/remove", handlers.CompareRemoveHandler)
+
 	fmt.Println("Server is listening on port 8081 (http://127.0.0.1:8081)")
 	err := http.ListenAndServe("127.0.0.1:8081", nil)
 	if err != nil {
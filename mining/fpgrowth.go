@@ -0,0 +1,275 @@
+package mining
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Every subset of a frequent itemset is frequent too, so the number of itemsets grows exponentially with their size.
+// Mining is limited to keep a single large order with a low support from exhausting time and memory.
+const (
+	MaxItemsetSize = 6
+	MaxItemsets    = 100000
+)
+
+var ItemsetTooLarge = errors.New("itemset size is limited to " + strconv.Itoa(MaxItemsetSize) + " items")
+var TooManyItemsets = errors.New("too many frequent itemsets, raise the minimum support or lower the itemset size")
+
+type Itemset struct {
+	Items   []int64
+	Count   int
+	Support float64
+}
+
+type Rule struct {
+	Antecedent []int64
+	Consequent []int64
+	Count      int
+	Support    float64
+	Confidence float64
+	Lift       float64
+}
+
+type fpNode struct {
+	item     int64
+	count    int
+	parent   *fpNode
+	children map[int64]*fpNode
+	next     *fpNode
+}
+
+type fpTree struct {
+	root   *fpNode
+	heads  map[int64]*fpNode
+	counts map[int64]int
+	order  []int64
+}
+
+type weightedTransaction struct {
+	items []int64
+	count int
+}
+
+func newFpTree(transactions []weightedTransaction, minCount int) *fpTree {
+	counts := make(map[int64]int)
+	for _, transaction := range transactions {
+		for _, item := range transaction.items {
+			counts[item] += transaction.count
+		}
+	}
+
+	tree := &fpTree{
+		root:   &fpNode{children: make(map[int64]*fpNode)},
+		heads:  make(map[int64]*fpNode),
+		counts: make(map[int64]int),
+	}
+	for item, count := range counts {
+		if count >= minCount {
+			tree.counts[item] = count
+			tree.order = append(tree.order, item)
+		}
+	}
+
+	rank := func(a, b int64) int {
+		if tree.counts[a] != tree.counts[b] {
+			return tree.counts[b] - tree.counts[a]
+		}
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+		return 0
+	}
+	slices.SortFunc(tree.order, rank)
+
+	for _, transaction := range transactions {
+		var items []int64
+		for _, item := range transaction.items {
+			if _, ok := tree.counts[item]; ok {
+				items = append(items, item)
+			}
+		}
+		slices.SortFunc(items, rank)
+		tree.insert(items, transaction.count)
+	}
+
+	return tree
+}
+
+func (tree *fpTree) insert(items []int64, count int) {
+	node := tree.root
+	for _, item := range items {
+		child, ok := node.children[item]
+		if !ok {
+			child = &fpNode{item: item, parent: node, children: make(map[int64]*fpNode), next: tree.heads[item]}
+			tree.heads[item] = child
+			node.children[item] = child
+		}
+		child.count += count
+		node = child
+	}
+}
+
+func (tree *fpTree) mine(suffix []int64, minCount, maxSize int, found func(items []int64, count int) error) error {
+	for i := len(tree.order) - 1; i >= 0; i-- {
+		item := tree.order[i]
+		itemset := append(slices.Clone(suffix), item)
+		if err := found(itemset, tree.counts[item]); err != nil {
+			return err
+		}
+
+		if len(itemset) >= maxSize {
+			continue
+		}
+
+		var base []weightedTransaction
+		for node := tree.heads[item]; node != nil; node = node.next {
+			var path []int64
+			for parent := node.parent; parent != tree.root; parent = parent.parent {
+				path = append(path, parent.item)
+			}
+			if len(path) > 0 {
+				base = append(base, weightedTransaction{items: path, count: node.count})
+			}
+		}
+
+		if len(base) > 0 {
+			if err := newFpTree(base, minCount).mine(itemset, minCount, maxSize, found); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func itemsetKey(items []int64) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = strconv.FormatInt(item, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// FrequentItemsets returns all itemsets that occur in at least minSupport (0..1] of transactions.
+// maxSize limits the itemset size, 0 means MaxItemsetSize. Mining stops with TooManyItemsets after MaxItemsets itemsets.
+func FrequentItemsets(transactions [][]int64, minSupport float64, maxSize int) ([]Itemset, error) {
+	if maxSize > MaxItemsetSize {
+		return nil, ItemsetTooLarge
+	}
+	if maxSize <= 0 {
+		maxSize = MaxItemsetSize
+	}
+	if len(transactions) == 0 {
+		return nil, nil
+	}
+
+	// The epsilon keeps products like 0.1 * 30 from rounding up past the exact count
+	minCount := max(int(math.Ceil(minSupport*float64(len(transactions))-1e-9)), 1)
+
+	weighted := make([]weightedTransaction, 0, len(transactions))
+	for _, transaction := range transactions {
+		items := slices.Clone(transaction)
+		slices.Sort(items)
+		weighted = append(weighted, weightedTransaction{items: slices.Compact(items), count: 1})
+	}
+
+	var itemsets []Itemset
+	err := newFpTree(weighted, minCount).mine(nil, minCount, maxSize, func(items []int64, count int) error {
+		if len(itemsets) >= MaxItemsets {
+			return TooManyItemsets
+		}
+		items = slices.Clone(items)
+		slices.Sort(items)
+		itemsets = append(itemsets, Itemset{
+			Items:   items,
+			Count:   count,
+			Support: float64(count) / float64(len(transactions)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(itemsets, func(i, j int) bool {
+		if itemsets[i].Count != itemsets[j].Count {
+			return itemsets[i].Count > itemsets[j].Count
+		}
+		if len(itemsets[i].Items) != len(itemsets[j].Items) {
+			return len(itemsets[i].Items) > len(itemsets[j].Items)
+		}
+		return slices.Compare(itemsets[i].Items, itemsets[j].Items) < 0
+	})
+
+	return itemsets, nil
+}
+
+// AssociationRules builds rules "antecedent => consequent" from every frequent itemset of 2+ items.
+// Because every subset of a frequent itemset is frequent, supports of both sides are always known.
+func AssociationRules(itemsets []Itemset, minConfidence float64) []Rule {
+	supports := make(map[string]float64, len(itemsets))
+	for _, itemset := range itemsets {
+		supports[itemsetKey(itemset.Items)] = itemset.Support
+	}
+
+	var rules []Rule
+	for _, itemset := range itemsets {
+		size := len(itemset.Items)
+		if size < 2 || size > MaxItemsetSize {
+			continue
+		}
+
+		for mask := 1; mask < 1<<size-1; mask++ {
+			var antecedent, consequent []int64
+			for i, item := range itemset.Items {
+				if mask&(1<<i) != 0 {
+					antecedent = append(antecedent, item)
+				} else {
+					consequent = append(consequent, item)
+				}
+			}
+
+			antecedentSupport, ok := supports[itemsetKey(antecedent)]
+			if !ok || antecedentSupport == 0 {
+				continue
+			}
+			confidence := itemset.Support / antecedentSupport
+			if confidence < minConfidence {
+				continue
+			}
+
+			consequentSupport := supports[itemsetKey(consequent)]
+			var lift float64
+			if consequentSupport > 0 {
+				lift = confidence / consequentSupport
+			}
+
+			rules = append(rules, Rule{
+				Antecedent: antecedent,
+				Consequent: consequent,
+				Count:      itemset.Count,
+				Support:    itemset.Support,
+				Confidence: confidence,
+				Lift:       lift,
+			})
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Lift != rules[j].Lift {
+			return rules[i].Lift > rules[j].Lift
+		}
+		if rules[i].Confidence != rules[j].Confidence {
+			return rules[i].Confidence > rules[j].Confidence
+		}
+		return rules[i].Count > rules[j].Count
+	})
+
+	return rules
+}
@@ -0,0 +1,178 @@
+package mining
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// Transactions of the FP-growth example from Han, Kamber and Pei, "Data Mining: Concepts and Techniques"
+var textbookTransactions = [][]int64{
+	{1, 2, 5},
+	{2, 4},
+	{2, 3},
+	{1, 2, 4},
+	{1, 3},
+	{2, 3},
+	{1, 3},
+	{1, 2, 3, 5},
+	{1, 2, 3},
+}
+
+func itemsetCounts(itemsets []Itemset) map[string]int {
+	counts := make(map[string]int, len(itemsets))
+	for _, itemset := range itemsets {
+		counts[itemsetKey(itemset.Items)] = itemset.Count
+	}
+	return counts
+}
+
+func TestFrequentItemsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int
+		want    map[string]int
+	}{
+		{
+			name:    "single items",
+			maxSize: 1,
+			want:    map[string]int{"1": 6, "2": 7, "3": 6, "4": 2, "5": 2},
+		},
+		{
+			name:    "pairs",
+			maxSize: 2,
+			want: map[string]int{
+				"1": 6, "2": 7, "3": 6, "4": 2, "5": 2,
+				"1,2": 4, "1,3": 4, "1,5": 2, "2,3": 4, "2,4": 2, "2,5": 2,
+			},
+		},
+		{
+			name:    "default size",
+			maxSize: 0,
+			want: map[string]int{
+				"1": 6, "2": 7, "3": 6, "4": 2, "5": 2,
+				"1,2": 4, "1,3": 4, "1,5": 2, "2,3": 4, "2,4": 2, "2,5": 2,
+				"1,2,3": 2, "1,2,5": 2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			itemsets, err := FrequentItemsets(textbookTransactions, 2.0/9, test.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := itemsetCounts(itemsets)
+			if len(got) != len(test.want) {
+				t.Errorf("got %d itemsets %v, want %d", len(got), got, len(test.want))
+			}
+			for key, count := range test.want {
+				if got[key] != count {
+					t.Errorf("itemset %s: got count %d, want %d", key, got[key], count)
+				}
+			}
+		})
+	}
+}
+
+func TestFrequentItemsetsMinSupport(t *testing.T) {
+	tests := []struct {
+		name       string
+		minSupport float64
+		wantItems  int
+	}{
+		{name: "exactly two transactions", minSupport: 2.0 / 9, wantItems: 5},
+		{name: "slightly more than two transactions", minSupport: 2.0/9 + 1e-7, wantItems: 3},
+		{name: "exactly six transactions", minSupport: 6.0 / 9, wantItems: 3},
+		{name: "no support", minSupport: 0, wantItems: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			itemsets, err := FrequentItemsets(textbookTransactions, test.minSupport, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(itemsets) != test.wantItems {
+				t.Errorf("got %d items %v, want %d", len(itemsets), itemsetCounts(itemsets), test.wantItems)
+			}
+		})
+	}
+}
+
+func TestFrequentItemsetsLimits(t *testing.T) {
+	// One large order makes every combination of its items frequent when the support is low
+	var transactions [][]int64
+	for i := range 99 {
+		transactions = append(transactions, []int64{int64(100 + i%5), int64(200 + i%7)})
+	}
+	var large []int64
+	for i := range 22 {
+		large = append(large, int64(i+1))
+	}
+	transactions = append(transactions, large)
+
+	tests := []struct {
+		name    string
+		maxSize int
+		wantErr error
+	}{
+		{name: "size above limit", maxSize: MaxItemsetSize + 1, wantErr: ItemsetTooLarge},
+		{name: "too many itemsets", maxSize: MaxItemsetSize, wantErr: TooManyItemsets},
+		{name: "small itemsets", maxSize: 3, wantErr: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := FrequentItemsets(transactions, 0.001, test.maxSize)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssociationRules(t *testing.T) {
+	itemsets, err := FrequentItemsets(textbookTransactions, 2.0/9, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		antecedent []int64
+		consequent []int64
+		confidence float64
+		lift       float64
+		found      bool
+	}{
+		{antecedent: []int64{1, 5}, consequent: []int64{2}, confidence: 1, lift: 9.0 / 7, found: true},
+		{antecedent: []int64{2, 5}, consequent: []int64{1}, confidence: 1, lift: 1.5, found: true},
+		{antecedent: []int64{5}, consequent: []int64{1, 2}, confidence: 1, lift: 2.25, found: true},
+		{antecedent: []int64{4}, consequent: []int64{2}, confidence: 1, lift: 9.0 / 7, found: true},
+		// Confidence 50% and 33% are below the minimum
+		{antecedent: []int64{1, 2}, consequent: []int64{5}, found: false},
+		{antecedent: []int64{1}, consequent: []int64{2, 5}, found: false},
+	}
+
+	rules := AssociationRules(itemsets, 0.7)
+	for _, test := range tests {
+		index := slices.IndexFunc(rules, func(rule Rule) bool {
+			return slices.Equal(rule.Antecedent, test.antecedent) && slices.Equal(rule.Consequent, test.consequent)
+		})
+		if (index >= 0) != test.found {
+			t.Errorf("rule %v => %v: found %t, want %t", test.antecedent, test.consequent, index >= 0, test.found)
+			continue
+		}
+		if !test.found {
+			continue
+		}
+
+		rule := rules[index]
+		if math.Abs(rule.Confidence-test.confidence) > 1e-9 || math.Abs(rule.Lift-test.lift) > 1e-9 {
+			t.Errorf("rule %v => %v: got confidence %f lift %f, want %f %f", test.antecedent, test.consequent, rule.Confidence, rule.Lift, test.confidence, test.lift)
+		}
+	}
+}
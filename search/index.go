@@ -0,0 +1,308 @@
+package search
+
+import (
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	exactMatchWeight  = 1.0
+	prefixMatchWeight = 0.7
+	typoMatchWeight   = 0.5
+)
+
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+type Result struct {
+	Id    int64
+	Score float64
+}
+
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[int64]float64
+	docTerms map[int64]map[string]float64
+	ready    bool
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[int64]float64),
+		docTerms: make(map[int64]map[string]float64),
+	}
+}
+
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+	})
+}
+
+func documentTerms(fields []Field) map[string]float64 {
+	terms := make(map[string]float64)
+	for _, field := range fields {
+		for _, term := range Tokenize(field.Text) {
+			terms[term] += field.Weight
+		}
+	}
+	return terms
+}
+
+func (index *Index) removeLocked(id int64) {
+	for term := range index.docTerms[id] {
+		delete(index.postings[term], id)
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+	delete(index.docTerms, id)
+}
+
+func (index *Index) setLocked(id int64, terms map[string]float64) {
+	index.removeLocked(id)
+	if len(terms) == 0 {
+		return
+	}
+
+	index.docTerms[id] = terms
+	for term, weight := range terms {
+		if index.postings[term] == nil {
+			index.postings[term] = make(map[int64]float64)
+		}
+		index.postings[term][id] = weight
+	}
+}
+
+func (index *Index) Set(id int64, fields []Field) {
+	terms := documentTerms(fields)
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.setLocked(id, terms)
+}
+
+func (index *Index) Remove(id int64) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.removeLocked(id)
+}
+
+func (index *Index) Replace(documents map[int64][]Field) {
+	terms := make(map[int64]map[string]float64, len(documents))
+	for id, fields := range documents {
+		terms[id] = documentTerms(fields)
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	index.postings = make(map[string]map[int64]float64)
+	index.docTerms = make(map[int64]map[string]float64)
+	for id, docTerms := range terms {
+		index.setLocked(id, docTerms)
+	}
+	index.ready = true
+}
+
+func (index *Index) Ready() bool {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return index.ready
+}
+
+func maxTypos(term string) int {
+	length := len([]rune(term))
+	if length >= 8 {
+		return 2
+	} else if length >= 4 {
+		return 1
+	}
+	return 0
+}
+
+func editDistance(a, b []rune, limit int) int {
+	if abs(len(a)-len(b)) > limit {
+		return limit + 1
+	}
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func (index *Index) matchingTerms(token string) map[string]float64 {
+	matches := make(map[string]float64)
+	if _, ok := index.postings[token]; ok {
+		matches[token] = exactMatchWeight
+	}
+
+	tokenRunes := []rune(token)
+	typos := maxTypos(token)
+	for term := range index.postings {
+		if term == token {
+			continue
+		}
+
+		if strings.HasPrefix(term, token) {
+			matches[term] = prefixMatchWeight
+			continue
+		}
+
+		if typos == 0 {
+			continue
+		}
+
+		termRunes := []rune(term)
+		if distance := editDistance(tokenRunes, termRunes, typos); distance <= typos {
+			matches[term] = typoMatchWeight / float64(distance)
+		} else if len(termRunes) > len(tokenRunes) {
+			if distance = editDistance(tokenRunes, termRunes[:len(tokenRunes)], typos); distance <= typos {
+				matches[term] = prefixMatchWeight * typoMatchWeight / float64(distance)
+			}
+		}
+	}
+
+	return matches
+}
+
+// Search returns up to limit best matching documents, 0 means no limit.
+func (index *Index) Search(query string, limit int) ([]Result, []string) {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	docCount := float64(len(index.docTerms))
+	var scores map[int64]float64
+	matchedTerms := make(map[string]bool)
+
+	for _, token := range tokens {
+		tokenScores := make(map[int64]float64)
+
+		for term, matchWeight := range index.matchingTerms(token) {
+			docs := index.postings[term]
+			idf := math.Log(1 + docCount/float64(len(docs)))
+
+			for id, weight := range docs {
+				if scores != nil {
+					if _, ok := scores[id]; !ok {
+						continue
+					}
+				}
+
+				score := idf * matchWeight * math.Log1p(weight)
+				if score > tokenScores[id] {
+					tokenScores[id] = score
+				}
+				matchedTerms[term] = true
+			}
+		}
+
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+
+		for id := range scores {
+			if tokenScore, ok := tokenScores[id]; ok {
+				scores[id] += tokenScore
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{Id: id, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Id < results[j].Id
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	terms := make([]string, 0, len(matchedTerms))
+	for term := range matchedTerms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	return results, terms
+}
+
+func Highlight(text string, terms []string) template.HTML {
+	if len(terms) == 0 {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	highlighted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		highlighted[term] = true
+	}
+
+	var builder strings.Builder
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start
+		isWord := unicode.IsLetter(runes[start]) || unicode.IsDigit(runes[start])
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) == isWord {
+			end++
+		}
+
+		part := string(runes[start:end])
+		if isWord && highlighted[strings.ToLower(part)] {
+			builder.WriteString("<mark>")
+			builder.WriteString(template.HTMLEscapeString(part))
+			builder.WriteString("</mark>")
+		} else {
+			builder.WriteString(template.HTMLEscapeString(part))
+		}
+
+		start = end
+	}
+
+	return template.HTML(builder.String())
+}
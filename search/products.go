@@ -0,0 +1,100 @@
+package search
+
+import (
+	"go-lb4/db"
+	"log"
+	"time"
+)
+
+var Products = NewIndex()
+
+var productChangedChan = make(chan int64, 256)
+var rebuildChan = make(chan bool, 1)
+
+func productFields(text db.ProductSearchText) []Field {
+	return []Field{
+		{Text: text.Model, Weight: 3},
+		{Text: text.Sku, Weight: 3},
+		{Text: text.Manufacturer, Weight: 2},
+		{Text: text.Category, Weight: 1.5},
+		{Text: text.Characteristics, Weight: 1},
+	}
+}
+
+func RebuildProducts() error {
+	texts, err := db.GetProductSearchTexts(0)
+	if err != nil {
+		return err
+	}
+
+	documents := make(map[int64][]Field, len(texts))
+	for _, text := range texts {
+		documents[text.ProductId] = productFields(text)
+	}
+
+	Products.Replace(documents)
+	log.Printf("Indexed %d products for search\n", len(documents))
+	return nil
+}
+
+func reindexProduct(productId int64) error {
+	texts, err := db.GetProductSearchTexts(productId)
+	if err != nil {
+		return err
+	}
+
+	if len(texts) == 0 {
+		Products.Remove(productId)
+		return nil
+	}
+
+	Products.Set(productId, productFields(texts[0]))
+	return nil
+}
+
+func ProductChanged(productId int64) {
+	select {
+	case productChangedChan <- productId:
+	default:
+		RequestRebuild()
+	}
+}
+
+func RequestRebuild() {
+	select {
+	case rebuildChan <- true:
+	default:
+	}
+}
+
+func IndexLoop(rebuildSec int) {
+	duration := time.Duration(rebuildSec) * time.Second
+	timer := time.NewTimer(0)
+
+	for {
+		select {
+		case productId := <-productChangedChan:
+			if err := reindexProduct(productId); err != nil {
+				log.Printf("Failed to reindex product %d: %s\n", productId, err)
+			}
+			if len(productChangedChan) == 0 {
+				if err := RebuildSuggestions(); err != nil {
+					log.Printf("Failed to rebuild suggestions: %s\n", err)
+				}
+			}
+			continue
+		case <-rebuildChan:
+			log.Println("Rebuilding search index because of rebuildChan")
+		case <-timer.C:
+			log.Println("Rebuilding search index because of timer")
+		}
+
+		timer.Reset(duration)
+		if err := RebuildProducts(); err != nil {
+			log.Printf("Failed to rebuild search index: %s\n", err)
+		}
+		if err := RebuildSuggestions(); err != nil {
+			log.Printf("Failed to rebuild suggestions: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"go-lb4/db"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	SuggestionProduct      = "product"
+	SuggestionCategory     = "category"
+	SuggestionManufacturer = "manufacturer"
+	SuggestionQuery        = "query"
+
+	popularQueriesCount = 1000
+)
+
+type Suggestion struct {
+	Type       string
+	Text       string
+	Url        string
+	Popularity int
+}
+
+type suggestEntry struct {
+	key        string
+	firstWord  bool
+	suggestion *Suggestion
+}
+
+type SuggestIndex struct {
+	mu      sync.RWMutex
+	entries []suggestEntry
+}
+
+var Suggestions = &SuggestIndex{}
+
+func (index *SuggestIndex) Replace(suggestions []Suggestion) {
+	var entries []suggestEntry
+	for idx := range suggestions {
+		words := Tokenize(suggestions[idx].Text)
+		for wordIdx := range words {
+			entries = append(entries, suggestEntry{
+				key:        strings.Join(words[wordIdx:], " "),
+				firstWord:  wordIdx == 0,
+				suggestion: &suggestions[idx],
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.entries = entries
+}
+
+func (entry suggestEntry) score() float64 {
+	score := math.Log1p(float64(entry.suggestion.Popularity))
+	if entry.firstWord {
+		score += 1
+	}
+	return score
+}
+
+func (index *SuggestIndex) Suggest(prefix string, limit int) []Suggestion {
+	prefix = strings.Join(Tokenize(prefix), " ")
+	if prefix == "" || limit <= 0 {
+		return nil
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	best := make(map[*Suggestion]float64)
+	start := sort.Search(len(index.entries), func(i int) bool {
+		return index.entries[i].key >= prefix
+	})
+	for i := start; i < len(index.entries) && strings.HasPrefix(index.entries[i].key, prefix); i++ {
+		entry := index.entries[i]
+		if score := entry.score(); score > best[entry.suggestion] {
+			best[entry.suggestion] = score
+		}
+	}
+
+	candidates := make([]*Suggestion, 0, len(best))
+	for suggestion := range best {
+		candidates = append(candidates, suggestion)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if best[a] != best[b] {
+			return best[a] > best[b]
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+
+	perType := make(map[string]int)
+	maxPerType := max((limit+1)/2, 1)
+	suggestions := make([]Suggestion, 0, limit)
+	for _, suggestion := range candidates {
+		if perType[suggestion.Type] >= maxPerType {
+			continue
+		}
+
+		perType[suggestion.Type]++
+		suggestions = append(suggestions, *suggestion)
+		if len(suggestions) >= limit {
+			break
+		}
+	}
+
+	return suggestions
+}
+
+func RebuildSuggestions() error {
+	products, err := db.GetProductsPopularity()
+	if err != nil {
+		return err
+	}
+
+	categories, err := db.GetCategoriesPopularity()
+	if err != nil {
+		return err
+	}
+
+	queries, err := db.GetPopularSearchQueries(popularQueriesCount)
+	if err != nil {
+		return err
+	}
+
+	var suggestions []Suggestion
+	manufacturers := make(map[string]int)
+	for _, product := range products {
+		suggestions = append(suggestions, Suggestion{
+			Type:       SuggestionProduct,
+			Text:       product.Product.Model,
+			Url:        "/products/" + strconv.FormatInt(product.Product.Id, 10),
+			Popularity: product.Sold,
+		})
+		manufacturers[product.Product.Manufacturer] += product.Sold
+	}
+
+	for manufacturer, sold := range manufacturers {
+		suggestions = append(suggestions, Suggestion{
+			Type:       SuggestionManufacturer,
+			Text:       manufacturer,
+			Url:        "/catalog?manufacturer=" + url.QueryEscape(manufacturer),
+			Popularity: sold,
+		})
+	}
+
+	for _, category := range categories {
+		suggestions = append(suggestions, Suggestion{
+			Type:       SuggestionCategory,
+			Text:       category.Category.Name,
+			Url:        "/catalog?category_id=" + strconv.FormatInt(category.Category.Id, 10),
+			Popularity: category.Sold,
+		})
+	}
+
+	for _, query := range queries {
+		suggestions = append(suggestions, Suggestion{
+			Type:       SuggestionQuery,
+			Text:       query.Query,
+			Url:        "/catalog?query=" + url.QueryEscape(query.Query),
+			Popularity: query.Count,
+		})
+	}
+
+	Suggestions.Replace(suggestions)
+	return nil
+}
@@ -0,0 +1,143 @@
+package seed
+
+import "go-lb4/db"
+
+type characteristicTemplate struct {
+	db.Characteristic
+	// Number values are Min + Step * n, up to Max
+	Min  float64
+	Max  float64
+	Step float64
+}
+
+type categoryTemplate struct {
+	Name        string
+	Parent      string
+	Description string
+	// BasePrice is the median price, actual prices are spread log-normally around it
+	BasePrice     float64
+	WarrantyDays  int
+	Manufacturers []string
+	Series        []string
+	// Characteristics are names of characteristicTemplates, the first one is required
+	Characteristics []string
+	// Bundles are categories whose products are often bought together with products of this category
+	Bundles []string
+}
+
+var characteristicTemplates = []characteristicTemplate{
+	{Characteristic: db.Characteristic{Name: "Color", ValueType: db.CharacteristicEnum, AllowedValues: []string{"Black", "White", "Silver", "Gray", "Blue", "Red"}}},
+	{Characteristic: db.Characteristic{Name: "Weight", Unit: "g", ValueType: db.CharacteristicNumber}, Min: 50, Max: 2500, Step: 10},
+	{Characteristic: db.Characteristic{Name: "Screen size", Unit: "in", ValueType: db.CharacteristicNumber}, Min: 6, Max: 17.3, Step: 0.1},
+	{Characteristic: db.Characteristic{Name: "RAM", Unit: "GB", ValueType: db.CharacteristicEnum, AllowedValues: []string{"4", "8", "16", "32", "64"}}},
+	{Characteristic: db.Characteristic{Name: "Storage", Unit: "GB", ValueType: db.CharacteristicEnum, AllowedValues: []string{"64", "128", "256", "512", "1024", "2048"}}},
+	{Characteristic: db.Characteristic{Name: "Battery", Unit: "mAh", ValueType: db.CharacteristicNumber}, Min: 300, Max: 10000, Step: 100},
+	{Characteristic: db.Characteristic{Name: "Wireless", ValueType: db.CharacteristicBoolean}},
+	{Characteristic: db.Characteristic{Name: "Noise cancelling", ValueType: db.CharacteristicBoolean}},
+	{Characteristic: db.Characteristic{Name: "Refresh rate", Unit: "Hz", ValueType: db.CharacteristicEnum, AllowedValues: []string{"60", "75", "120", "144", "165", "240"}}},
+	{Characteristic: db.Characteristic{Name: "Power", Unit: "W", ValueType: db.CharacteristicNumber}, Min: 10, Max: 140, Step: 5},
+	{Characteristic: db.Characteristic{Name: "Material", ValueType: db.CharacteristicText}},
+}
+
+var materials = []string{"Polyester", "Nylon", "Leather", "Silicone", "Polycarbonate", "Aluminium"}
+
+var categoryTemplates = []categoryTemplate{
+	{Name: "Computers", Description: "Laptops, monitors and peripherals"},
+	{Name: "Phones and tablets", Description: "Smartphones, tablets and their accessories"},
+	{Name: "Audio", Description: "Headphones and speakers"},
+	{Name: "Accessories", Description: "Bags, cases and chargers"},
+	{
+		Name: "Laptops", Parent: "Computers", BasePrice: 32000, WarrantyDays: 730,
+		Manufacturers:   []string{"Lenovo", "ASUS", "Acer", "HP", "Dell", "Apple"},
+		Series:          []string{"ProBook", "ThinkPad", "Vivobook", "Aspire", "Inspiron", "Zenbook", "IdeaPad"},
+		Characteristics: []string{"Screen size", "RAM", "Storage", "Weight", "Color"},
+		Bundles:         []string{"Laptop bags", "Mice"},
+	},
+	{
+		Name: "Monitors", Parent: "Computers", BasePrice: 9000, WarrantyDays: 1095,
+		Manufacturers:   []string{"Samsung", "LG", "Dell", "AOC", "ASUS"},
+		Series:          []string{"UltraSharp", "Odyssey", "UltraGear", "ProArt", "Gaming"},
+		Characteristics: []string{"Screen size", "Refresh rate", "Color"},
+		Bundles:         []string{"Keyboards"},
+	},
+	{
+		Name: "Mice", Parent: "Computers", BasePrice: 900, WarrantyDays: 365,
+		Manufacturers:   []string{"Logitech", "Razer", "A4Tech", "HyperX"},
+		Series:          []string{"MX", "Pro", "DeathAdder", "Pulsefire", "Bloody"},
+		Characteristics: []string{"Wireless", "Weight", "Color"},
+		Bundles:         []string{"Keyboards"},
+	},
+	{
+		Name: "Keyboards", Parent: "Computers", BasePrice: 1800, WarrantyDays: 365,
+		Manufacturers:   []string{"Logitech", "Razer", "HyperX", "Keychron"},
+		Series:          []string{"MX Keys", "BlackWidow", "Alloy", "K"},
+		Characteristics: []string{"Wireless", "Color"},
+	},
+	{
+		Name: "Smartphones", Parent: "Phones and tablets", BasePrice: 14000, WarrantyDays: 365,
+		Manufacturers:   []string{"Samsung", "Apple", "Xiaomi", "Motorola", "Google"},
+		Series:          []string{"Galaxy", "iPhone", "Redmi Note", "Moto G", "Pixel"},
+		Characteristics: []string{"Screen size", "Storage", "RAM", "Battery", "Color"},
+		Bundles:         []string{"Phone cases", "Chargers"},
+	},
+	{
+		Name: "Tablets", Parent: "Phones and tablets", BasePrice: 16000, WarrantyDays: 365,
+		Manufacturers:   []string{"Samsung", "Apple", "Lenovo", "Xiaomi"},
+		Series:          []string{"Galaxy Tab", "iPad", "Tab", "Pad"},
+		Characteristics: []string{"Screen size", "Storage", "Battery", "Color"},
+		Bundles:         []string{"Chargers"},
+	},
+	{
+		Name: "Headphones", Parent: "Audio", BasePrice: 2500, WarrantyDays: 365,
+		Manufacturers:   []string{"Sony", "JBL", "Sennheiser", "Apple", "Marshall"},
+		Series:          []string{"WH", "Tune", "Momentum", "AirPods", "Major"},
+		Characteristics: []string{"Wireless", "Noise cancelling", "Battery", "Color"},
+	},
+	{
+		Name: "Speakers", Parent: "Audio", BasePrice: 3000, WarrantyDays: 365,
+		Manufacturers:   []string{"JBL", "Sony", "Marshall", "Xiaomi"},
+		Series:          []string{"Flip", "Charge", "Go", "Emberton"},
+		Characteristics: []string{"Wireless", "Battery", "Weight", "Color"},
+	},
+	{
+		Name: "Laptop bags", Parent: "Accessories", BasePrice: 1200, WarrantyDays: 180,
+		Manufacturers:   []string{"Targus", "Samsonite", "Thule", "2E"},
+		Series:          []string{"Backpack", "Sleeve", "Messenger", "Case"},
+		Characteristics: []string{"Material", "Color"},
+	},
+	{
+		Name: "Phone cases", Parent: "Accessories", BasePrice: 400, WarrantyDays: 90,
+		Manufacturers:   []string{"Spigen", "OtterBox", "UAG", "ArmorStandart"},
+		Series:          []string{"Tough Armor", "Defender", "Monarch", "Icon"},
+		Characteristics: []string{"Material", "Color"},
+	},
+	{
+		Name: "Chargers", Parent: "Accessories", BasePrice: 700, WarrantyDays: 365,
+		Manufacturers:   []string{"Anker", "Baseus", "Ugreen", "Xiaomi"},
+		Series:          []string{"PowerPort", "Nano", "GaN", "Nexode"},
+		Characteristics: []string{"Power", "Wireless", "Color"},
+	},
+}
+
+var firstNames = []string{
+	"Olena", "Andrii", "Iryna", "Dmytro", "Kateryna", "Oleksandr", "Natalia", "Serhii", "Yulia", "Maksym",
+	"Oksana", "Taras", "Sofiia", "Bohdan", "Anna", "Viktor", "Daria", "Roman", "Marta", "Ihor",
+}
+
+var lastNames = []string{
+	"Shevchenko", "Kovalenko", "Bondarenko", "Tkachenko", "Kravchenko", "Melnyk", "Boiko", "Oliinyk", "Lysenko", "Moroz",
+	"Marchenko", "Savchenko", "Rudenko", "Petrenko", "Klymenko", "Pavlenko", "Levchenko", "Kharchenko", "Polishchuk", "Tkachuk",
+}
+
+var cities = []string{"Kharkiv", "Kyiv", "Lviv", "Odesa", "Dnipro", "Poltava", "Vinnytsia", "Zaporizhzhia"}
+
+var streets = []string{"Nauky Ave", "Sumska St", "Shevchenka St", "Hrushevskoho St", "Peremohy Ave", "Soborna St", "Franka St"}
+
+// Relative order volume by weekday starting from Sunday
+var weekdayFactors = [7]float64{1.15, 0.85, 0.9, 0.95, 1.0, 1.2, 1.3}
+
+// Relative order volume by hour of the day
+var hourFactors = [24]float64{
+	0.2, 0.1, 0.05, 0.05, 0.05, 0.1, 0.3, 0.6, 0.9, 1.1, 1.2, 1.2,
+	1.3, 1.3, 1.2, 1.2, 1.3, 1.5, 1.8, 2.0, 2.1, 1.8, 1.2, 0.6,
+}
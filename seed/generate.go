@@ -0,0 +1,440 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-lb4/db"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options of the generated data. The same options and seed always generate the same data.
+type Options struct {
+	Seed uint64
+	// Orders are generated for Days days up to To inclusive
+	To   time.Time
+	Days int
+	// Products is the number of products per category
+	Products     int
+	Customers    int
+	OrdersPerDay float64
+}
+
+type Result struct {
+	Categories      int
+	Characteristics int
+	Products        int
+	Customers       int
+	Orders          int
+	OrderItems      int
+}
+
+const (
+	// Popularity of the n-th most popular product is proportional to 1/n^zipfExponent
+	zipfExponent = 1.1
+	// Standard deviation of the log price around the category base price
+	priceSpread = 0.45
+	// Order volume grows by this share over the period
+	periodGrowth = 0.3
+	// Share of customers who registered before the period, the rest join uniformly during it
+	existingCustomersShare = 0.4
+
+	extraItemProbability = 0.35
+	maxOrderProducts     = 5
+	bundleProbability    = 0.4
+	discountProbability  = 0.1
+	createdProbability   = 0.05
+	paymentProbability   = 0.03
+)
+
+type seedProduct struct {
+	db.Product
+	Template *categoryTemplate
+	// Bundles are indexes of products often bought together with this one
+	Bundles []int
+}
+
+type generator struct {
+	ctx     context.Context
+	rng     *rand.Rand
+	options Options
+	result  Result
+
+	characteristics map[string]db.Characteristic
+	categories      map[string]db.Category
+
+	products           []seedProduct
+	productsCumulative []float64
+
+	customers           []db.Customer
+	addresses           []string
+	customerJoinDays    []int
+	customersCumulative []float64
+}
+
+func cumulative(weights []float64) []float64 {
+	result := make([]float64, len(weights))
+	var sum float64
+	for i, weight := range weights {
+		sum += weight
+		result[i] = sum
+	}
+	return result
+}
+
+// pick returns a random index weighted by the cumulative weights.
+func (g *generator) pick(cumulativeWeights []float64) int {
+	value := g.rng.Float64() * cumulativeWeights[len(cumulativeWeights)-1]
+	return min(sort.SearchFloat64s(cumulativeWeights, value), len(cumulativeWeights)-1)
+}
+
+func (g *generator) choice(values []string) string {
+	return values[g.rng.IntN(len(values))]
+}
+
+func (g *generator) poisson(lambda float64) int {
+	if lambda > 30 {
+		return max(0, int(math.Round(lambda+g.rng.NormFloat64()*math.Sqrt(lambda))))
+	}
+
+	limit := math.Exp(-lambda)
+	count := 0
+	for p := g.rng.Float64(); p > limit; p *= g.rng.Float64() {
+		count++
+	}
+	return count
+}
+
+// roundPrice rounds prices to whole numbers ending with 9, like shops do.
+func roundPrice(price float64) float64 {
+	if price < 100 {
+		return max(1, math.Round(price))
+	}
+	return math.Round(price/10)*10 - 1
+}
+
+func (g *generator) createCharacteristics() error {
+	for _, template := range characteristicTemplates {
+		characteristic, err := db.GetCharacteristicByName(template.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			characteristic = template.Characteristic
+			if err = characteristic.DbSave(); err != nil {
+				return err
+			}
+			g.result.Characteristics++
+			characteristic, err = db.GetCharacteristicByName(template.Name)
+		}
+		if err != nil {
+			return err
+		}
+		g.characteristics[template.Name] = characteristic
+	}
+	return nil
+}
+
+func (g *generator) createCategories() error {
+	for _, template := range categoryTemplates {
+		category, err := db.GetCategoryByName(template.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			category = db.Category{Name: template.Name, Description: template.Description, ParentId: g.categories[template.Parent].Id}
+			if err = category.DbSave(); err != nil {
+				return err
+			}
+			g.result.Categories++
+			category, err = db.GetCategoryByName(template.Name)
+		}
+		if err != nil {
+			return err
+		}
+		g.categories[template.Name] = category
+
+		for i, name := range template.Characteristics {
+			categoryCharacteristic := db.CategoryCharacteristic{
+				CategoryId:     category.Id,
+				Characteristic: g.characteristics[name],
+				Required:       i == 0,
+				DisplayOrder:   i,
+			}
+			if err = categoryCharacteristic.DbSave(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) characteristicValue(name string) (string, error) {
+	index := slices.IndexFunc(characteristicTemplates, func(template characteristicTemplate) bool {
+		return template.Name == name
+	})
+	template := characteristicTemplates[index]
+
+	var value string
+	switch template.ValueType {
+	case db.CharacteristicNumber:
+		steps := int((template.Max - template.Min) / template.Step)
+		value = fmt.Sprintf("%g", math.Round((template.Min+float64(g.rng.IntN(steps+1))*template.Step)*10)/10)
+	case db.CharacteristicBoolean:
+		value = g.choice([]string{"Yes", "No"})
+	case db.CharacteristicEnum:
+		value = g.choice(template.AllowedValues)
+	default:
+		value = g.choice(materials)
+	}
+
+	// Existing characteristics with the same name may have another type
+	return g.characteristics[name].NormalizeValue(value)
+}
+
+func (g *generator) createProducts() error {
+	for i := range categoryTemplates {
+		template := &categoryTemplates[i]
+		if template.Parent == "" {
+			continue
+		}
+
+		for range g.options.Products {
+			product := seedProduct{
+				Product: db.Product{
+					Category:     g.categories[template.Name],
+					Manufacturer: g.choice(template.Manufacturers),
+					Model:        fmt.Sprintf("%s %d", g.choice(template.Series), 10+g.rng.IntN(990)),
+					Price:        roundPrice(template.BasePrice * math.Exp(g.rng.NormFloat64()*priceSpread)),
+					Quantity:     5 + g.rng.IntN(150),
+					WarrantyDays: template.WarrantyDays,
+				},
+				Template: template,
+			}
+			if err := product.DbSave(g.ctx, nil); err != nil {
+				return err
+			}
+
+			for _, name := range template.Characteristics {
+				value, err := g.characteristicValue(name)
+				if err != nil {
+					continue
+				}
+				err = db.SetProductCharacteristicValue(g.ctx, db.ProductCharacteristic{
+					ProductId:      product.Id,
+					Characteristic: g.characteristics[name],
+					Value:          value,
+				}, nil)
+				if err != nil {
+					return err
+				}
+			}
+
+			g.products = append(g.products, product)
+			g.result.Products++
+		}
+	}
+
+	// Every product gets a matching product from each bundle category, e.g. a case for a smartphone
+	for i := range g.products {
+		for _, bundle := range g.products[i].Template.Bundles {
+			var candidates []int
+			for j, product := range g.products {
+				if product.Template.Name == bundle {
+					candidates = append(candidates, j)
+				}
+			}
+			if len(candidates) > 0 {
+				g.products[i].Bundles = append(g.products[i].Bundles, candidates[g.rng.IntN(len(candidates))])
+			}
+		}
+	}
+
+	// A few products are bestsellers and cheaper products of a category sell better
+	weights := make([]float64, len(g.products))
+	for rank, i := range g.rng.Perm(len(g.products)) {
+		product := g.products[i]
+		weights[i] = math.Pow(float64(rank+1), -zipfExponent) * math.Sqrt(product.Template.BasePrice/product.Price)
+	}
+	g.productsCumulative = cumulative(weights)
+
+	return nil
+}
+
+func (g *generator) createCustomers() error {
+	g.customerJoinDays = make([]int, g.options.Customers)
+	for i := range g.customerJoinDays {
+		if g.rng.Float64() >= existingCustomersShare {
+			g.customerJoinDays[i] = g.rng.IntN(g.options.Days)
+		}
+	}
+	// At least one customer must be able to order on the first day
+	g.customerJoinDays[0] = 0
+	slices.Sort(g.customerJoinDays)
+
+	// Order frequency of customers is spread log-normally, so there are both loyal and one-time customers
+	weights := make([]float64, g.options.Customers)
+	for i := range g.options.Customers {
+		firstName, lastName := g.choice(firstNames), g.choice(lastNames)
+		customer := db.Customer{
+			FirstName: firstName,
+			LastName:  lastName,
+			Email:     fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(firstName), strings.ToLower(lastName), i+1),
+		}
+		if err := customer.DbSave(g.ctx, nil); err != nil {
+			return err
+		}
+		if customer.Id == 0 {
+			created, err := db.GetCustomerByEmail(customer.Email)
+			if err != nil {
+				return err
+			}
+			customer = created
+			g.result.Customers++
+		}
+
+		g.customers = append(g.customers, customer)
+		g.addresses = append(g.addresses, fmt.Sprintf("%s, %s %d, apt. %d", g.choice(cities), g.choice(streets), 1+g.rng.IntN(120), 1+g.rng.IntN(200)))
+		weights[i] = math.Exp(g.rng.NormFloat64())
+	}
+	g.customersCumulative = cumulative(weights)
+
+	return nil
+}
+
+// pickCustomer picks one of the customers who joined by the day.
+func (g *generator) pickCustomer(day int) int {
+	joined := max(1, sort.SearchInts(g.customerJoinDays, day+1))
+	return g.pick(g.customersCumulative[:joined])
+}
+
+func (g *generator) orderStatus() string {
+	if r := g.rng.Float64(); r < createdProbability {
+		return db.OrderStatusCreated
+	} else if r < createdProbability+paymentProbability {
+		return db.OrderStatusPayment
+	}
+	return db.OrderStatusComplete
+}
+
+func (g *generator) orderItems() []db.OrderItem {
+	var indexes []int
+	add := func(index int) {
+		if !slices.Contains(indexes, index) {
+			indexes = append(indexes, index)
+		}
+	}
+
+	add(g.pick(g.productsCumulative))
+	for len(indexes) < maxOrderProducts && g.rng.Float64() < extraItemProbability {
+		add(g.pick(g.productsCumulative))
+	}
+	for _, index := range slices.Clone(indexes) {
+		for _, bundle := range g.products[index].Bundles {
+			if g.rng.Float64() < bundleProbability {
+				add(bundle)
+			}
+		}
+	}
+
+	items := make([]db.OrderItem, len(indexes))
+	for i, index := range indexes {
+		product := g.products[index].Product
+
+		quantity := 1
+		if r := g.rng.Float64(); r < 0.03 {
+			quantity = 3
+		} else if r < 0.12 {
+			quantity = 2
+		}
+
+		price := product.Price
+		if g.rng.Float64() < discountProbability {
+			price = roundPrice(price * (0.85 + g.rng.Float64()*0.1))
+		}
+
+		items[i] = db.OrderItem{Product: product, Quantity: quantity, PricePerItem: price}
+	}
+	return items
+}
+
+func (g *generator) createOrder(order db.Order, items []db.OrderItem) error {
+	tx, err := db.BeginTx(g.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = order.DbSave(g.ctx, tx); err != nil {
+		return err
+	}
+	for _, item := range items {
+		item.OrderId = order.Id
+		if err = item.DbSave(g.ctx, tx); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	g.result.Orders++
+	g.result.OrderItems += len(items)
+	return nil
+}
+
+func (g *generator) createOrders() error {
+	hoursCumulative := cumulative(hourFactors[:])
+	start := g.options.To.AddDate(0, 0, 1-g.options.Days)
+	now := time.Now()
+
+	for day := range g.options.Days {
+		date := start.AddDate(0, 0, day)
+		lambda := g.options.OrdersPerDay * weekdayFactors[date.Weekday()] * (1 + periodGrowth*float64(day)/float64(g.options.Days))
+
+		for range g.poisson(lambda) {
+			createdAt := date.Add(time.Duration(g.pick(hoursCumulative))*time.Hour + time.Duration(g.rng.IntN(3600))*time.Second)
+			customer := g.pickCustomer(day)
+			order := db.Order{
+				Customer:  g.customers[customer],
+				CreatedAt: createdAt,
+				Address:   g.addresses[customer],
+				Status:    g.orderStatus(),
+			}
+			items := g.orderItems()
+
+			// Random values are drawn for skipped orders too, so the past stays the same whenever the data is generated
+			if createdAt.After(now) {
+				continue
+			}
+			if err := g.createOrder(order, items); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Generate writes categories, products with characteristics, customers and orders to the database.
+// Categories and characteristics with existing names are reused, customers are matched by email.
+func Generate(ctx context.Context, options Options) (Result, error) {
+	if options.Days < 1 || options.Products < 1 || options.Customers < 1 {
+		return Result{}, errors.New("days, products and customers must be positive")
+	}
+
+	g := &generator{
+		ctx:             ctx,
+		rng:             rand.New(rand.NewPCG(options.Seed, options.Seed)),
+		options:         options,
+		characteristics: make(map[string]db.Characteristic),
+		categories:      make(map[string]db.Category),
+	}
+
+	for _, step := range []func() error{g.createCharacteristics, g.createCategories, g.createProducts, g.createCustomers, g.createOrders} {
+		if err := step(); err != nil {
+			return g.result, err
+		}
+	}
+
+	return g.result, db.RebuildDailySales(ctx)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type File interface {
+	io.ReadSeekCloser
+}
+
+type Storage interface {
+	Save(name string, data io.Reader) error
+	Open(name string) (File, time.Time, error)
+	Delete(name string) error
+}
+
+var InvalidName = errors.New("invalid file name")
+
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) LocalStorage {
+	return LocalStorage{
+		root: root,
+	}
+}
+
+func (s LocalStorage) path(name string) (string, error) {
+	cleaned := filepath.Clean("/" + name)
+	if cleaned == "/" || strings.Contains(name, "..") {
+		return "", InvalidName
+	}
+
+	return filepath.Join(s.root, cleaned), nil
+}
+
+func (s LocalStorage) Save(name string, data io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	return err
+}
+
+func (s LocalStorage) Open(name string) (File, time.Time, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, time.Time{}, err
+	}
+
+	return file, info.ModTime(), nil
+}
+
+func (s LocalStorage) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
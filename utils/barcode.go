@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+var InvalidBarcode = errors.New("barcode must be a valid EAN-13 or UPC-A code")
+
+var ean13LCodes = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+var ean13GCodes = [10]string{
+	"0100111", "0110011", "0011011", "0100001", "0011101",
+	"0111001", "0000101", "0010001", "0001001", "0010111",
+}
+
+var ean13RCodes = [10]string{
+	"1110010", "1100110", "1101100", "1000010", "1011100",
+	"1001110", "1010000", "1000100", "1001000", "1110100",
+}
+
+// ean13Parity holds L/G pattern of left half digits, selected by first digit
+var ean13Parity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+func barcodeDigits(code string) ([]int, error) {
+	digits := make([]int, len(code))
+	for i, c := range code {
+		if c < '0' || c > '9' {
+			return nil, InvalidBarcode
+		}
+		digits[i] = int(c - '0')
+	}
+	return digits, nil
+}
+
+// BarcodeCheckDigit calculates GTIN check digit for code without check digit
+func BarcodeCheckDigit(digits []int) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		if (len(digits)-1-i)%2 == 0 {
+			sum += digits[i] * 3
+		} else {
+			sum += digits[i]
+		}
+	}
+	return (10 - sum%10) % 10
+}
+
+// NormalizeBarcode validates EAN-13 or UPC-A code and returns it in EAN-13 form
+func NormalizeBarcode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 13 {
+		return "", InvalidBarcode
+	}
+
+	digits, err := barcodeDigits(code)
+	if err != nil {
+		return "", err
+	}
+
+	if BarcodeCheckDigit(digits[:12]) != digits[12] {
+		return "", InvalidBarcode
+	}
+
+	return code, nil
+}
+
+// encodeEan13 returns 95 modules of EAN-13 barcode, true meaning a bar
+func encodeEan13(code string) ([]bool, error) {
+	code, err := NormalizeBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	digits, _ := barcodeDigits(code)
+	parity := ean13Parity[digits[0]]
+
+	var pattern strings.Builder
+	pattern.WriteString("101")
+	for i := 1; i <= 6; i++ {
+		if parity[i-1] == 'L' {
+			pattern.WriteString(ean13LCodes[digits[i]])
+		} else {
+			pattern.WriteString(ean13GCodes[digits[i]])
+		}
+	}
+	pattern.WriteString("01010")
+	for i := 7; i <= 12; i++ {
+		pattern.WriteString(ean13RCodes[digits[i]])
+	}
+	pattern.WriteString("101")
+
+	modules := make([]bool, pattern.Len())
+	for i, c := range pattern.String() {
+		modules[i] = c == '1'
+	}
+
+	return modules, nil
+}
+
+const barcodeQuietZone = 11
+
+func BarcodePng(code string, moduleWidth, height int) ([]byte, error) {
+	modules, err := encodeEan13(code)
+	if err != nil {
+		return nil, err
+	}
+
+	width := (len(modules) + barcodeQuietZone*2) * moduleWidth
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	for i, bar := range modules {
+		if !bar {
+			continue
+		}
+		for x := (barcodeQuietZone + i) * moduleWidth; x < (barcodeQuietZone+i+1)*moduleWidth; x++ {
+			for y := 0; y < height; y++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func BarcodeSvg(code string, moduleWidth, height int) ([]byte, error) {
+	modules, err := encodeEan13(code)
+	if err != nil {
+		return nil, err
+	}
+	code, _ = NormalizeBarcode(code)
+
+	width := (len(modules) + barcodeQuietZone*2) * moduleWidth
+	textHeight := moduleWidth * 10
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height+textHeight, width, height+textHeight)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`, width, height+textHeight)
+	for i := 0; i < len(modules); i++ {
+		if !modules[i] {
+			continue
+		}
+		start := i
+		for i+1 < len(modules) && modules[i+1] {
+			i++
+		}
+		fmt.Fprintf(&buf, `<rect x="%d" y="0" width="%d" height="%d" fill="#000"/>`, (barcodeQuietZone+start)*moduleWidth, (i-start+1)*moduleWidth, height)
+	}
+	fmt.Fprintf(
+		&buf,
+		`<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle">%s</text>`,
+		width/2, height+textHeight-moduleWidth, textHeight-moduleWidth, code,
+	)
+	buf.WriteString(`</svg>`)
+
+	return buf.Bytes(), nil
+}
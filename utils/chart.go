@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+)
+
+type ChartSeries struct {
+	Values []float64
+	Color  color.RGBA
+	Bars   bool
+}
+
+var (
+	ChartBlue = color.RGBA{R: 54, G: 162, B: 235, A: 255}
+	ChartRed  = color.RGBA{R: 255, G: 99, B: 132, A: 255}
+)
+
+// ChartAxisStep picks a round grid step so that about four lines cover maxValue.
+func ChartAxisStep(maxValue float64) float64 {
+	if maxValue <= 0 {
+		return 1
+	}
+	raw := maxValue / 4
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if step*magnitude >= raw {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// ChartTop returns the value at the top of the chart for the given series.
+func ChartTop(series []ChartSeries) float64 {
+	var maxValue float64
+	for _, s := range series {
+		for _, value := range s.Values {
+			maxValue = max(maxValue, value)
+		}
+	}
+	step := ChartAxisStep(maxValue)
+	return math.Max(step*math.Ceil(maxValue/step), step)
+}
+
+func drawChartLine(img *image.RGBA, x1, y1, x2, y2 float64, c color.RGBA) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		x := int(math.Round(x1 + (x2-x1)*t))
+		y := int(math.Round(y1 + (y2-y1)*t))
+		img.SetRGBA(x, y, c)
+		img.SetRGBA(x, y+1, c)
+		img.SetRGBA(x+1, y, c)
+	}
+}
+
+// ChartPng draws bar and line series without any text, labels are expected around the image.
+func ChartPng(series []ChartSeries, width, height int) ([]byte, error) {
+	const padding = 4
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	top := ChartTop(series)
+	step := ChartAxisStep(top)
+	plotHeight := float64(height - 2*padding)
+	bottom := float64(height - padding)
+	gridColor := color.RGBA{R: 230, G: 230, B: 230, A: 255}
+	for value := 0.0; value <= top+step/2; value += step {
+		y := int(bottom - value/top*plotHeight)
+		draw.Draw(img, image.Rect(0, y, width, y+1), &image.Uniform{C: gridColor}, image.Point{}, draw.Src)
+	}
+
+	points := 0
+	bars := 0
+	for _, s := range series {
+		points = max(points, len(s.Values))
+		if s.Bars {
+			bars++
+		}
+	}
+	if points == 0 {
+		return encodeChartPng(img)
+	}
+
+	slot := float64(width) / float64(points)
+	barIndex := 0
+	for _, s := range series {
+		if s.Bars {
+			barWidth := slot * 0.8 / float64(bars)
+			for i, value := range s.Values {
+				x := slot*float64(i) + slot*0.1 + barWidth*float64(barIndex)
+				y := bottom - value/top*plotHeight
+				rect := image.Rect(int(x), int(y), int(math.Max(x+barWidth-1, x+1)), int(bottom))
+				draw.Draw(img, rect, &image.Uniform{C: s.Color}, image.Point{}, draw.Src)
+			}
+			barIndex++
+			continue
+		}
+
+		for i := 1; i < len(s.Values); i++ {
+			drawChartLine(
+				img,
+				slot*(float64(i)-0.5), bottom-s.Values[i-1]/top*plotHeight,
+				slot*(float64(i)+0.5), bottom-s.Values[i]/top*plotHeight,
+				s.Color,
+			)
+		}
+	}
+
+	return encodeChartPng(img)
+}
+
+func encodeChartPng(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := png.Encode(&buffer, img)
+	return buffer.Bytes(), err
+}
+
+// SparklinePoints returns the "points" attribute of an SVG polyline drawing values in a width x height box.
+func SparklinePoints(values []float64, width, height float64) string {
+	var maxValue float64
+	for _, value := range values {
+		maxValue = max(maxValue, value)
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+
+	points := make([]string, len(values))
+	for i, value := range values {
+		x := 0.0
+		if len(values) > 1 {
+			x = width * float64(i) / float64(len(values)-1)
+		}
+		y := height - 1 - (height-2)*value/maxValue
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	return strings.Join(points, " ")
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// ResizeImage scales image down (never up) so it fits into maxSize x maxSize box, averaging source pixels
+func ResizeImage(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSize && srcH <= maxSize {
+		return src
+	}
+
+	dstW, dstH := maxSize, maxSize
+	if srcW > srcH {
+		dstH = max(1, srcH*maxSize/srcW)
+	} else {
+		dstW = max(1, srcW*maxSize/srcH)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := y * srcH / dstH
+		y1 := max(y0+1, (y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := x * srcW / dstW
+			x1 := max(x0+1, (x+1)*srcW/dstW)
+
+			var r, g, b, a, count int
+			for sy := y0; sy < y1; sy++ {
+				offset := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(rgba.Pix[offset])
+					g += int(rgba.Pix[offset+1])
+					b += int(rgba.Pix[offset+2])
+					a += int(rgba.Pix[offset+3])
+					offset += 4
+					count++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = uint8(a / count)
+		}
+	}
+
+	return dst
+}
+
+// EncodeImage writes image as png if format is "png", as jpeg otherwise
+func EncodeImage(w io.Writer, img image.Image, format string) error {
+	if format == "png" {
+		return png.Encode(w, img)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
+
+func ResizeImageBytes(img image.Image, format string, maxSize int) ([]byte, error) {
+	var buf bytes.Buffer
+	err := EncodeImage(&buf, ResizeImage(img, maxSize), format)
+	return buf.Bytes(), err
+}
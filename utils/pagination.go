@@ -12,6 +12,10 @@ type PaginationInfo struct {
 
 	UrlPath string
 	Query   string
+
+	CursorMode bool
+	PrevCursor string
+	NextCursor string
 }
 
 type PaginationResult struct {
@@ -29,7 +33,7 @@ var TmplPaginationFuncs = template.FuncMap{
 	"calculatePagination": func(pagination PaginationInfo) PaginationResult {
 		var queryParams []string
 		for _, param := range strings.Split(pagination.Query, "&") {
-			if strings.HasPrefix(param, "page=") || strings.HasPrefix(param, "pageSize=") {
+			if strings.HasPrefix(param, "page=") || strings.HasPrefix(param, "pageSize=") || strings.HasPrefix(param, "after=") || strings.HasPrefix(param, "before=") {
 				continue
 			}
 			queryParams = append(queryParams, param)
@@ -40,6 +44,10 @@ var TmplPaginationFuncs = template.FuncMap{
 			Query:   template.URL(strings.Join(queryParams, "&")),
 		}
 
+		if pagination.CursorMode {
+			return result
+		}
+
 		totalPages := (pagination.Count + pagination.PageSize - 1) / pagination.PageSize
 
 		if pagination.Page == 1 {
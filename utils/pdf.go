@@ -0,0 +1,373 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	PdfPageWidth  = 595.0
+	PdfPageHeight = 842.0
+	PdfMargin     = 40.0
+)
+
+var pdfWinAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88, '‰': 0x89,
+	'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// pdfTransliteration spells Cyrillic letters with Latin ones, the standard fonts have no Cyrillic glyphs
+var pdfTransliteration = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ie", 'ж': "zh", 'з': "z",
+	'и': "y", 'і': "i", 'ї': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p",
+	'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ь': "", 'ю': "iu", 'я': "ia", 'ё': "io", 'ы': "y", 'э': "e", 'ъ': "",
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "H", 'Ґ': "G", 'Д': "D", 'Е': "E", 'Є': "Ye", 'Ж': "Zh", 'З': "Z",
+	'И': "Y", 'І': "I", 'Ї': "Yi", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O", 'П': "P",
+	'Р': "R", 'С': "S", 'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh", 'Щ': "Shch",
+	'Ь': "", 'Ю': "Yu", 'Я': "Ya", 'Ё': "Yo", 'Ы': "Y", 'Э': "E", 'Ъ': "",
+}
+
+type PdfColor struct {
+	R, G, B float64
+}
+
+var (
+	PdfBlack     = PdfColor{0, 0, 0}
+	PdfGray      = PdfColor{0.55, 0.55, 0.55}
+	PdfLightGray = PdfColor{0.9, 0.9, 0.9}
+	PdfBlue      = PdfColor{0.21, 0.64, 0.92}
+	PdfRed       = PdfColor{1, 0.39, 0.52}
+	PdfGreen     = PdfColor{0.1, 0.53, 0.33}
+)
+
+// Pdf is a minimal PDF 1.4 writer with the standard Helvetica fonts.
+// Coordinates are in points with the origin in the top left corner of the page.
+type Pdf struct {
+	pages []*bytes.Buffer
+	Y     float64
+}
+
+func NewPdf() *Pdf {
+	pdf := &Pdf{}
+	pdf.AddPage()
+	return pdf
+}
+
+func (pdf *Pdf) page() *bytes.Buffer {
+	return pdf.pages[len(pdf.pages)-1]
+}
+
+func (pdf *Pdf) AddPage() {
+	pdf.pages = append(pdf.pages, &bytes.Buffer{})
+	pdf.Y = PdfMargin
+}
+
+// EnsureSpace starts a new page if less than height points are left on the current one.
+func (pdf *Pdf) EnsureSpace(height float64) {
+	if pdf.Y+height > PdfPageHeight-PdfMargin {
+		pdf.AddPage()
+	}
+}
+
+func pdfNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// pdfEscape encodes the text for the standard fonts, which only support WinAnsi (Latin-1) characters.
+// Cyrillic is transliterated to Latin, other characters outside of WinAnsi are replaced with "?".
+func pdfEscape(text string) string {
+	var builder strings.Builder
+	writeByte := func(b byte) {
+		if b == '\\' || b == '(' || b == ')' {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(b)
+	}
+
+	for _, char := range text {
+		switch {
+		case char < 0x80 && char >= 0x20:
+			writeByte(byte(char))
+		case char >= 0xa0 && char <= 0xff:
+			writeByte(byte(char))
+		default:
+			if b, ok := pdfWinAnsiExtra[char]; ok {
+				writeByte(b)
+			} else if latin, ok := pdfTransliteration[char]; ok {
+				for i := 0; i < len(latin); i++ {
+					writeByte(latin[i])
+				}
+			} else {
+				writeByte('?')
+			}
+		}
+	}
+	return builder.String()
+}
+
+// TextWidth approximates the width of the text, Helvetica glyphs are about half of the font size wide.
+func TextWidth(text string, size float64) float64 {
+	return float64(len([]rune(text))) * size * 0.5
+}
+
+// FitText shortens the text with an ellipsis so it fits into width.
+func FitText(text string, size, width float64) string {
+	runes := []rune(text)
+	maxChars := int(width / (size * 0.5))
+	if len(runes) <= maxChars {
+		return text
+	}
+	if maxChars <= 1 {
+		return ""
+	}
+	return string(runes[:maxChars-1]) + "…"
+}
+
+func (pdf *Pdf) Text(x, y, size float64, bold bool, color PdfColor, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(
+		pdf.page(), "BT %s %s %s rg /%s %s Tf %s %s Td (%s) Tj ET\n",
+		pdfNumber(color.R), pdfNumber(color.G), pdfNumber(color.B), font, pdfNumber(size),
+		pdfNumber(x), pdfNumber(PdfPageHeight-y), pdfEscape(text),
+	)
+}
+
+func (pdf *Pdf) TextRight(x, y, size float64, bold bool, color PdfColor, text string) {
+	pdf.Text(x-TextWidth(text, size), y, size, bold, color, text)
+}
+
+func (pdf *Pdf) Line(x1, y1, x2, y2, width float64, color PdfColor) {
+	fmt.Fprintf(
+		pdf.page(), "%s %s %s RG %s w %s %s m %s %s l S\n",
+		pdfNumber(color.R), pdfNumber(color.G), pdfNumber(color.B), pdfNumber(width),
+		pdfNumber(x1), pdfNumber(PdfPageHeight-y1), pdfNumber(x2), pdfNumber(PdfPageHeight-y2),
+	)
+}
+
+func (pdf *Pdf) Polyline(xs, ys []float64, width float64, color PdfColor) {
+	if len(xs) < 2 {
+		return
+	}
+
+	page := pdf.page()
+	fmt.Fprintf(page, "%s %s %s RG %s w ", pdfNumber(color.R), pdfNumber(color.G), pdfNumber(color.B), pdfNumber(width))
+	for i := range xs {
+		operator := "l"
+		if i == 0 {
+			operator = "m"
+		}
+		fmt.Fprintf(page, "%s %s %s ", pdfNumber(xs[i]), pdfNumber(PdfPageHeight-ys[i]), operator)
+	}
+	page.WriteString("S\n")
+}
+
+func (pdf *Pdf) FillRect(x, y, width, height float64, color PdfColor) {
+	fmt.Fprintf(
+		pdf.page(), "%s %s %s rg %s %s %s %s re f\n",
+		pdfNumber(color.R), pdfNumber(color.G), pdfNumber(color.B),
+		pdfNumber(x), pdfNumber(PdfPageHeight-y-height), pdfNumber(width), pdfNumber(height),
+	)
+}
+
+func (pdf *Pdf) Heading(text string, size float64) {
+	pdf.EnsureSpace(size * 2)
+	pdf.Y += size
+	pdf.Text(PdfMargin, pdf.Y, size, true, PdfBlack, text)
+	pdf.Y += size * 0.6
+}
+
+func (pdf *Pdf) Paragraph(text string, size float64) {
+	pdf.EnsureSpace(size * 1.5)
+	pdf.Y += size * 1.2
+	pdf.Text(PdfMargin, pdf.Y, size, false, PdfBlack, text)
+	pdf.Y += size * 0.3
+}
+
+// Table draws rows with a bold header and repeats the header after page breaks.
+// Columns listed in rightAligned are aligned to the right edge, which suits numbers.
+func (pdf *Pdf) Table(header []string, widths []float64, rows [][]string, rightAligned ...int) {
+	const size = 9.0
+	const rowHeight = size * 1.8
+
+	isRight := make(map[int]bool)
+	for _, column := range rightAligned {
+		isRight[column] = true
+	}
+
+	drawRow := func(cells []string, bold bool) {
+		x := PdfMargin
+		for i, width := range widths {
+			if i >= len(cells) {
+				break
+			}
+			text := FitText(cells[i], size, width-6)
+			if isRight[i] {
+				pdf.TextRight(x+width-3, pdf.Y+size*1.25, size, bold, PdfBlack, text)
+			} else {
+				pdf.Text(x+3, pdf.Y+size*1.25, size, bold, PdfBlack, text)
+			}
+			x += width
+		}
+		pdf.Y += rowHeight
+
+		var total float64
+		for _, width := range widths {
+			total += width
+		}
+		pdf.Line(PdfMargin, pdf.Y, PdfMargin+total, pdf.Y, 0.5, PdfLightGray)
+	}
+
+	pdf.EnsureSpace(rowHeight * 2)
+	drawRow(header, true)
+	for _, row := range rows {
+		if pdf.Y+rowHeight > PdfPageHeight-PdfMargin {
+			pdf.AddPage()
+			drawRow(header, true)
+		}
+		drawRow(row, false)
+	}
+	pdf.Y += size
+}
+
+type PdfSeries struct {
+	Name   string
+	Values []float64
+	Color  PdfColor
+	Bars   bool
+}
+
+// Chart draws bar and line series over shared labels with a value axis and a legend.
+func (pdf *Pdf) Chart(title string, labels []string, series []PdfSeries, height float64) {
+	const size = 8.0
+
+	pdf.EnsureSpace(height + 40)
+	pdf.Heading(title, 11)
+
+	var maxValue float64
+	bars := 0
+	for _, s := range series {
+		if s.Bars {
+			bars++
+		}
+		for _, value := range s.Values {
+			maxValue = max(maxValue, value)
+		}
+	}
+	step := ChartAxisStep(maxValue)
+	top := math.Max(step*math.Ceil(maxValue/step), step)
+
+	left := PdfMargin + 40
+	right := PdfPageWidth - PdfMargin
+	chartTop := pdf.Y + 6
+	bottom := chartTop + height
+	width := right - left
+
+	for value := 0.0; value <= top+step/2; value += step {
+		y := bottom - value/top*height
+		pdf.Line(left, y, right, y, 0.4, PdfLightGray)
+		pdf.TextRight(left-4, y+size/3, size, false, PdfGray, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	pdf.Line(left, bottom, right, bottom, 0.8, PdfGray)
+
+	if len(labels) > 0 {
+		slot := width / float64(len(labels))
+		labelEvery := max(1, int(math.Ceil(float64(len(labels))*TextWidth(labels[0], size)/(width*0.8))))
+		for i, label := range labels {
+			if i%labelEvery == 0 {
+				x := left + slot*(float64(i)+0.5)
+				pdf.Text(x-TextWidth(label, size)/2, bottom+size+2, size, false, PdfGray, label)
+			}
+		}
+
+		barIndex := 0
+		for _, s := range series {
+			if s.Bars {
+				barWidth := slot * 0.8 / float64(bars)
+				for i, value := range s.Values {
+					if i >= len(labels) || value <= 0 {
+						continue
+					}
+					barHeight := value / top * height
+					x := left + slot*float64(i) + slot*0.1 + barWidth*float64(barIndex)
+					pdf.FillRect(x, bottom-barHeight, barWidth, barHeight, s.Color)
+				}
+				barIndex++
+				continue
+			}
+
+			var xs, ys []float64
+			for i, value := range s.Values {
+				if i >= len(labels) {
+					break
+				}
+				xs = append(xs, left+slot*(float64(i)+0.5))
+				ys = append(ys, bottom-value/top*height)
+			}
+			pdf.Polyline(xs, ys, 1.2, s.Color)
+		}
+	}
+
+	x := left
+	legendY := bottom + size*3
+	for _, s := range series {
+		pdf.FillRect(x, legendY-size+1, size, size-2, s.Color)
+		pdf.Text(x+size+3, legendY, size, false, PdfBlack, s.Name)
+		x += size + 3 + TextWidth(s.Name, size) + 12
+	}
+
+	pdf.Y = legendY + size
+}
+
+func (pdf *Pdf) WriteTo(w io.Writer) (int64, error) {
+	buffer := bufio.NewWriter(w)
+	var written int64
+	var offsets []int64
+
+	write := func(format string, args ...any) {
+		n, _ := fmt.Fprintf(buffer, format, args...)
+		written += int64(n)
+	}
+	object := func(body string) {
+		offsets = append(offsets, written)
+		write("%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	write("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(pdf.pages))
+	for i := range pdf.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pdf.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range pdf.pages {
+		object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(PdfPageWidth), pdfNumber(PdfPageHeight), 6+i*2,
+		))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xrefOffset := written
+	write("xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		write("%010d 00000 n \n", offset)
+	}
+	write("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return written, buffer.Flush()
+}
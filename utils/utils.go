@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -150,3 +152,35 @@ func GetCartId(r *http.Request) uuid.UUID {
 
 	return cartId
 }
+
+const MaxCompareProducts = 4
+
+func GetCompareIds(r *http.Request) []int64 {
+	compareCookie, err := r.Cookie("compare")
+	if err != nil {
+		return nil
+	}
+
+	var ids []int64
+	for _, idStr := range strings.Split(compareCookie.Value, ".") {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil || slices.Contains(ids, id) {
+			continue
+		}
+		ids = append(ids, id)
+		if len(ids) >= MaxCompareProducts {
+			break
+		}
+	}
+
+	return ids
+}
+
+func SetCompareIds(w http.ResponseWriter, ids []int64) {
+	idStrs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idStrs = append(idStrs, strconv.FormatInt(id, 10))
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "compare", Value: strings.Join(idStrs, "."), Path: "/", HttpOnly: true, MaxAge: 86400 * 7})
+}
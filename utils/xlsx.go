@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const maxXlsxPartSize = 64 << 20
+
+var InvalidXlsx = errors.New("invalid xlsx file")
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (text xlsxRichText) String() string {
+	if len(text.Runs) == 0 {
+		return text.Text
+	}
+
+	var builder strings.Builder
+	for _, run := range text.Runs {
+		builder.WriteString(run.Text)
+	}
+	return builder.String()
+}
+
+type xlsxCell struct {
+	Ref    string       `xml:"r,attr"`
+	Type   string       `xml:"t,attr"`
+	Value  string       `xml:"v"`
+	Inline xlsxRichText `xml:"is"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+func readXlsxPart(archive *zip.Reader, name string, out any) (bool, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return true, err
+		}
+		defer reader.Close()
+
+		return true, xml.NewDecoder(io.LimitReader(reader, maxXlsxPartSize)).Decode(out)
+	}
+
+	return false, nil
+}
+
+func xlsxFirstSheetPath(archive *zip.Reader) string {
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+
+	found, err := readXlsxPart(archive, "xl/workbook.xml", &workbook)
+	if !found || err != nil || len(workbook.Sheets) == 0 {
+		return "xl/worksheets/sheet1.xml"
+	}
+
+	found, err = readXlsxPart(archive, "xl/_rels/workbook.xml.rels", &rels)
+	if !found || err != nil {
+		return "xl/worksheets/sheet1.xml"
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.Id != workbook.Sheets[0].RelId {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+
+	return "xl/worksheets/sheet1.xml"
+}
+
+// xlsxMaxColumns is the number of columns in a worksheet, the last one is XFD
+const xlsxMaxColumns = 16384
+
+// xlsxColumnIndex returns -1 for references beyond the last column.
+func xlsxColumnIndex(ref string) int {
+	index := 0
+	for _, char := range ref {
+		if char < 'A' || char > 'Z' {
+			break
+		}
+		index = index*26 + int(char-'A'+1)
+		if index > xlsxMaxColumns {
+			return -1
+		}
+	}
+	return index - 1
+}
+
+func xlsxColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+func ReadXlsx(reader io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, InvalidXlsx
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if _, err = readXlsxPart(archive, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+		return nil, err
+	}
+
+	var sheet xlsxWorksheet
+	found, err := readXlsxPart(archive, xlsxFirstSheetPath(archive), &sheet)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, InvalidXlsx
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, sheetRow := range sheet.Rows {
+		var row []string
+
+		for _, cell := range sheetRow.Cells {
+			column := len(row)
+			if cell.Ref != "" {
+				column = xlsxColumnIndex(cell.Ref)
+			}
+			if column < 0 || column < len(row) || column >= xlsxMaxColumns {
+				return nil, InvalidXlsx
+			}
+
+			for len(row) < column {
+				row = append(row, "")
+			}
+
+			var value string
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, InvalidXlsx
+				}
+				value = sharedStrings.Items[index].String()
+			case "inlineStr":
+				value = cell.Inline.String()
+			case "b":
+				if cell.Value == "1" {
+					value = "TRUE"
+				} else {
+					value = "FALSE"
+				}
+			default:
+				value = cell.Value
+			}
+
+			row = append(row, value)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func writeXlsxPart(archive *zip.Writer, name, content string) error {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(writer, xml.Header+content)
+	return err
+}
+
+func WriteXlsx(w io.Writer, sheetName string, rows [][]string, numericColumns ...int) error {
+	numeric := make(map[int]bool)
+	for _, column := range numericColumns {
+		numeric[column] = true
+	}
+
+	var sheet strings.Builder
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for rowIdx, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, rowIdx+1)
+		for column, value := range row {
+			ref := xlsxColumnName(column) + strconv.Itoa(rowIdx+1)
+
+			if _, err := strconv.ParseFloat(value, 64); err == nil && rowIdx > 0 && numeric[column] {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, value)
+				continue
+			}
+
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			if err := xml.EscapeText(&sheet, []byte(value)); err != nil {
+				return err
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	var escapedName strings.Builder
+	if err := xml.EscapeText(&escapedName, []byte(sheetName)); err != nil {
+		return err
+	}
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{
+			"[Content_Types].xml",
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+				`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+				`<Default Extension="xml" ContentType="application/xml"/>` +
+				`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+				`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+				`</Types>`,
+		},
+		{
+			"_rels/.rels",
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+				`</Relationships>`,
+		},
+		{
+			"xl/workbook.xml",
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+				`<sheets><sheet name="` + escapedName.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+				`</workbook>`,
+		},
+		{
+			"xl/_rels/workbook.xml.rels",
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+				`</Relationships>`,
+		},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		if err := writeXlsxPart(archive, part.name, part.content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}